@@ -0,0 +1,57 @@
+package cache
+
+import "testing"
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("a = %d, %v", v, ok)
+	}
+
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("c = %d, %v", v, ok)
+	}
+}
+
+func TestLRUAddReplacesValue(t *testing.T) {
+	c := NewLRU[string, int](2)
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+
+	if v, _ := c.Get("a"); v != 2 {
+		t.Errorf("a = %d, want 2", v)
+	}
+
+	if c.Len() != 1 {
+		t.Errorf("len = %d, want 1", c.Len())
+	}
+}
+
+func TestLRURemoveAndPurge(t *testing.T) {
+	c := NewLRU[int, string](4)
+
+	c.Add(1, "one")
+	c.Add(2, "two")
+	c.Remove(1)
+
+	if _, ok := c.Get(1); ok {
+		t.Error("1 should have been removed")
+	}
+
+	c.Purge()
+
+	if c.Len() != 0 {
+		t.Errorf("len = %d after purge", c.Len())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"example.com/rest-api/db"
+	"github.com/spf13/cobra"
+)
+
+// newRootCommand builds the command line. Without a subcommand the API is
+// served, as it always was; the other commands do what used to need the
+// sqlite3 shell, through the models package so the same rules apply.
+func newRootCommand() *cobra.Command {
+	var dbPath string
+
+	root := &cobra.Command{
+		Use:          "rest-api",
+		Short:        "Events API server and admin tools",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return configure()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(dbPath)
+		},
+	}
+	root.PersistentFlags().StringVar(&dbPath, "db", "api.db", "path of the SQLite database")
+
+	// openDB opens the database for the admin commands, which close it
+	// when they are done.
+	openDB := func() func() {
+		db.InitDB(dbPath)
+		return func() { db.DB.Close() }
+	}
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Serve the API (the default)",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return serve(dbPath)
+			},
+		},
+		newUserCommand(openDB),
+		newEventCommand(openDB),
+		newSeedCommand(openDB),
+		newTokenCommand(openDB),
+	)
+
+	return root
+}
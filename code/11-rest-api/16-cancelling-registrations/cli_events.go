@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/spf13/cobra"
+)
+
+func newEventCommand(openDB func() func()) *cobra.Command {
+	event := &cobra.Command{
+		Use:   "event",
+		Short: "Manage events",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List all events, including unlisted and private ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer openDB()()
+
+			events, err := models.FindEvents(cmd.Context(), models.EventFilter{AllVisibilities: true})
+
+			if err != nil {
+				return fmt.Errorf("could not fetch events: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tDATE\tNAME\tLOCATION\tOWNER\tVISIBILITY")
+
+			for _, event := range events {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n",
+					event.ID, event.DateTime.Format(time.DateTime), event.Name, event.Location, event.UserID, event.Visibility)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	remove := &cobra.Command{
+		Use:   "delete ID",
+		Short: "Delete an event with its registrations",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			eventId, err := strconv.ParseInt(args[0], 10, 64)
+
+			if err != nil {
+				return fmt.Errorf("invalid event id %q", args[0])
+			}
+
+			defer openDB()()
+
+			event, err := models.GetEventByID(cmd.Context(), eventId)
+
+			if err != nil {
+				return fmt.Errorf("could not fetch event: %w", err)
+			}
+
+			err = event.Delete(cmd.Context())
+
+			if err != nil {
+				return fmt.Errorf("could not delete event: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted event %d (%s).\n", event.ID, event.Name)
+			return nil
+		},
+	}
+
+	event.AddCommand(list, remove)
+	return event
+}
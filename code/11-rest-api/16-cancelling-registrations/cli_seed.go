@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/spf13/cobra"
+)
+
+// fakeTags are created by the seeder unless tags with their names exist.
+var fakeTags = []models.Tag{
+	{Name: "tech", Category: "topic"},
+	{Name: "music", Category: "topic"},
+	{Name: "food", Category: "topic"},
+	{Name: "sports", Category: "topic"},
+	{Name: "online", Category: "format"},
+	{Name: "workshop", Category: "format"},
+}
+
+var fakeEventKinds = []string{"Meetup", "Workshop", "Conference", "Social", "Hackathon", "Night", "Festival", "Book Club"}
+
+func newSeedCommand(openDB func() func()) *cobra.Command {
+	var fake bool
+	var users, events int
+	var seed int64
+	var password string
+
+	seedCmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Fill the database with data for local development",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !fake {
+				return errors.New("only fake data can be seeded for now, pass --fake")
+			}
+
+			if users < 1 {
+				return errors.New("--users must be at least 1, someone has to own the events")
+			}
+
+			defer openDB()()
+
+			faker := gofakeit.New(seed)
+			ctx := cmd.Context()
+
+			tags, err := seedTags(cmd)
+
+			if err != nil {
+				return err
+			}
+
+			userIds := make([]int64, users)
+
+			for i := range userIds {
+				first, last := faker.FirstName(), faker.LastName()
+				email := strings.ToLower(fmt.Sprintf("%s.%s.%d@example.com", first, last, faker.Number(1, 9999)))
+				err := models.User{Email: email, Password: password}.Save(ctx)
+
+				if err != nil {
+					return fmt.Errorf("could not create user %s: %w", email, err)
+				}
+
+				user, err := models.GetUserByEmail(ctx, email)
+
+				if err != nil {
+					return err
+				}
+
+				userIds[i] = user.ID
+			}
+
+			fakeEvents := make([]models.Event, events)
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+
+			for i := range fakeEvents {
+				city := faker.City()
+				day := faker.DateRange(today.AddDate(0, 0, -30), today.AddDate(0, 6, 0))
+				event := models.Event{
+					Name:        fmt.Sprintf("%s %s %s", city, faker.Hobby(), faker.RandomString(fakeEventKinds)),
+					Description: faker.Paragraph(1, 3, 12, " "),
+					Location:    city,
+					DateTime:    time.Date(day.Year(), day.Month(), day.Day(), faker.Number(9, 20), 30*faker.Number(0, 1), 0, 0, time.UTC),
+					UserID:      userIds[faker.Number(0, len(userIds)-1)],
+					Tags:        []string{},
+				}
+
+				// A few events are unlisted, the rest public.
+				if faker.Number(1, 10) == 1 {
+					event.Visibility = models.VisibilityUnlisted
+				}
+
+				for _, tag := range tags {
+					if faker.Number(1, 4) == 1 {
+						event.Tags = append(event.Tags, tag)
+					}
+				}
+
+				fakeEvents[i] = event
+			}
+
+			err = models.SaveAll(ctx, fakeEvents)
+
+			if err != nil {
+				return fmt.Errorf("could not create events: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Created %d users with the password %q and %d events.\n", users, password, events)
+			return nil
+		},
+	}
+	seedCmd.Flags().BoolVar(&fake, "fake", false, "generate random users and events")
+	seedCmd.Flags().IntVar(&users, "users", 10, "number of users to create")
+	seedCmd.Flags().IntVar(&events, "events", 30, "number of events to create")
+	seedCmd.Flags().Int64Var(&seed, "seed", 0, "random seed for repeatable data, 0 for a random one")
+	seedCmd.Flags().StringVar(&password, "password", "password", "password of the created users")
+
+	return seedCmd
+}
+
+// seedTags creates the missing fakeTags and returns the names of all of
+// them.
+func seedTags(cmd *cobra.Command) ([]string, error) {
+	existing, err := models.GetAllTags(cmd.Context())
+
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch tags: %w", err)
+	}
+
+	names := make([]string, len(fakeTags))
+
+	for i, tag := range fakeTags {
+		names[i] = tag.Name
+
+		if slices.ContainsFunc(existing, func(t models.Tag) bool { return t.Name == tag.Name }) {
+			continue
+		}
+
+		err := tag.Save(cmd.Context())
+
+		if err != nil {
+			return nil, fmt.Errorf("could not create tag %s: %w", tag.Name, err)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+)
+
+// run runs the command line on the database at dbPath and returns what it
+// printed. The database is closed again when it returns, like after a real
+// run.
+func run(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(append([]string{"--db", dbPath}, args...))
+
+	err := root.Execute()
+	return out.String(), err
+}
+
+func mustRun(t *testing.T, dbPath string, args ...string) string {
+	t.Helper()
+
+	out, err := run(t, dbPath, args...)
+
+	if err != nil {
+		t.Fatalf("%v: %v\n%s", args, err, out)
+	}
+
+	return out
+}
+
+// inspect opens the database for checking what a command did.
+func inspect(t *testing.T, dbPath string) {
+	t.Helper()
+
+	db.InitDB(dbPath)
+	t.Cleanup(func() { db.DB.Close() })
+}
+
+func TestUserCommands(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "api.db")
+
+	out := mustRun(t, dbPath, "user", "create", "admin@example.com", "--role", "admin")
+
+	if !strings.Contains(out, "Password: ") {
+		t.Errorf("got %q, want a generated password", out)
+	}
+
+	mustRun(t, dbPath, "user", "create", "user@example.com", "--password", "secret")
+
+	if _, err := run(t, dbPath, "user", "create", "user@example.com", "--password", "secret"); err == nil {
+		t.Error("creating a duplicate user succeeded")
+	}
+
+	if _, err := run(t, dbPath, "user", "set-role", "user@example.com", "root"); err == nil {
+		t.Error("setting an unknown role succeeded")
+	}
+
+	mustRun(t, dbPath, "user", "set-role", "user@example.com", "admin")
+	token := strings.TrimSpace(mustRun(t, dbPath, "token", "mint", "user@example.com", "--scope", "events:read"))
+
+	if !models.IsAPIToken(token) {
+		t.Errorf("got token %q, want an API token", token)
+	}
+
+	mustRun(t, dbPath, "user", "disable", "user@example.com")
+
+	if _, err := run(t, dbPath, "token", "mint", "user@example.com"); err == nil {
+		t.Error("minting a token for a disabled user succeeded")
+	}
+
+	inspect(t, dbPath)
+	ctx := context.Background()
+
+	user, err := models.GetUserByEmail(ctx, "user@example.com")
+
+	if err != nil || user.Role != models.RoleAdmin {
+		t.Errorf("got %+v, %v, want an admin", user, err)
+	}
+
+	credentials := models.User{Email: "user@example.com", Password: "secret"}
+
+	if err := credentials.ValidateCredentials(ctx); !errors.Is(err, models.ErrUserDisabled) {
+		t.Errorf("login of a disabled user: got %v, want ErrUserDisabled", err)
+	}
+
+	if _, err := models.AuthenticateAPIToken(ctx, token); !errors.Is(err, models.ErrInvalidAPIToken) {
+		t.Errorf("API token of a disabled user: got %v, want ErrInvalidAPIToken", err)
+	}
+}
+
+func TestUserCreateIsAllOrNothing(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "api.db")
+
+	db.InitDB(dbPath)
+	_, err := db.DB.Exec("CREATE TRIGGER inject_failure BEFORE UPDATE ON users BEGIN SELECT RAISE(ABORT, 'injected failure'); END")
+	db.DB.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(t, dbPath, "user", "create", "admin@example.com", "--role", "admin"); err == nil {
+		t.Fatal("creating an admin succeeded although setting the role failed")
+	}
+
+	inspect(t, dbPath)
+
+	if _, err := models.GetUserByEmail(context.Background(), "admin@example.com"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("got error %v, want no user left behind", err)
+	}
+}
+
+func TestSeedAndEventCommands(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "api.db")
+
+	if _, err := run(t, dbPath, "seed"); err == nil {
+		t.Error("seed without --fake succeeded")
+	}
+
+	mustRun(t, dbPath, "seed", "--fake", "--users", "3", "--events", "5", "--seed", "1")
+
+	out := mustRun(t, dbPath, "event", "list")
+
+	if lines := strings.Count(out, "\n"); lines != 6 {
+		t.Errorf("got %d lines, want a header and 5 events:\n%s", lines, out)
+	}
+
+	mustRun(t, dbPath, "event", "delete", "1")
+
+	if _, err := run(t, dbPath, "event", "delete", "1"); err == nil {
+		t.Error("deleting a missing event succeeded")
+	}
+
+	inspect(t, dbPath)
+
+	events, err := models.FindEvents(context.Background(), models.EventFilter{AllVisibilities: true})
+
+	if err != nil || len(events) != 4 {
+		t.Errorf("got %d events, %v, want 4", len(events), err)
+	}
+
+	users, err := models.GetUsersByIDs(context.Background(), []int64{1, 2, 3})
+
+	if err != nil || len(users) != 3 {
+		t.Errorf("got %d users, %v, want 3", len(users), err)
+	}
+}
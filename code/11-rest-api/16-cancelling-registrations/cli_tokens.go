@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"example.com/rest-api/models"
+	"example.com/rest-api/utils"
+	"github.com/spf13/cobra"
+)
+
+func newTokenCommand(openDB func() func()) *cobra.Command {
+	token := &cobra.Command{
+		Use:   "token",
+		Short: "Issue tokens",
+	}
+
+	var scopes []string
+	var name string
+	var expiresIn time.Duration
+
+	mint := &cobra.Command{
+		Use:   "mint EMAIL",
+		Short: "Print a login token for a user, or an API token with --scope",
+		Long: "Prints a login token for the user, valid for " + utils.TokenTTL.String() + ". With --scope a personal\n" +
+			"access token is created instead, as if the user created it, and listed among their tokens.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer openDB()()
+
+			user, err := models.GetUserByEmail(cmd.Context(), args[0])
+
+			if err != nil {
+				return fmt.Errorf("could not find user: %w", err)
+			}
+
+			err = models.CheckUserActive(cmd.Context(), user.ID)
+
+			if err != nil {
+				return fmt.Errorf("could not mint a token: %w", err)
+			}
+
+			if len(scopes) == 0 {
+				secret, err := utils.GenerateToken(user.Email, user.ID)
+
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), secret)
+				return nil
+			}
+
+			apiToken := models.APIToken{UserID: user.ID, Name: name}
+
+			for _, scope := range scopes {
+				apiToken.Scopes = append(apiToken.Scopes, models.Scope(scope))
+			}
+
+			if expiresIn > 0 {
+				expiresAt := time.Now().Add(expiresIn).UTC()
+				apiToken.ExpiresAt = &expiresAt
+			}
+
+			secret, err := apiToken.Create(cmd.Context())
+
+			if err != nil {
+				return fmt.Errorf("could not create API token: %w", err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), secret)
+			return nil
+		},
+	}
+	mint.Flags().StringSliceVar(&scopes, "scope", nil, "scopes of an API token, e.g. events:read (repeatable)")
+	mint.Flags().StringVar(&name, "name", "cli", "name of the API token")
+	mint.Flags().DurationVar(&expiresIn, "expires-in", 0, "lifetime of the API token, 0 until revoked")
+
+	token.AddCommand(mint)
+	return token
+}
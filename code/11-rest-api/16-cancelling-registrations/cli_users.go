@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/utils"
+	"github.com/spf13/cobra"
+)
+
+func newUserCommand(openDB func() func()) *cobra.Command {
+	user := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+
+	var password, role string
+
+	create := &cobra.Command{
+		Use:   "create EMAIL",
+		Short: "Create a user, with a random password unless --password is given",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if role != models.RoleUser && role != models.RoleAdmin {
+				return models.ErrInvalidRole
+			}
+
+			defer openDB()()
+
+			generated := password == ""
+
+			if generated {
+				var err error
+				password, _, err = utils.GenerateRandomToken()
+
+				if err != nil {
+					return err
+				}
+			}
+
+			// Creating the user and setting the role is one step, so a
+			// failure doesn't leave a plain user blocking the email.
+			err := db.WithTx(cmd.Context(), func(ctx context.Context, tx *sql.Tx) error {
+				err := models.User{Email: args[0], Password: password}.Save(ctx)
+
+				if err != nil {
+					return fmt.Errorf("could not create user: %w", err)
+				}
+
+				if role != models.RoleUser {
+					err = models.SetUserRole(ctx, args[0], role)
+
+					if err != nil {
+						return fmt.Errorf("could not set role: %w", err)
+					}
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Created %s %s.\n", role, args[0])
+
+			if generated {
+				fmt.Fprintf(cmd.OutOrStdout(), "Password: %s\n", password)
+			}
+
+			return nil
+		},
+	}
+	create.Flags().StringVar(&password, "password", "", "the user's password")
+	create.Flags().StringVar(&role, "role", models.RoleUser, "user or admin")
+
+	disable := &cobra.Command{
+		Use:   "disable EMAIL",
+		Short: "Stop a user from signing in and revoke their tokens",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer openDB()()
+
+			err := models.DisableUser(cmd.Context(), args[0])
+
+			if err != nil {
+				return fmt.Errorf("could not disable user: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Disabled %s.\n", args[0])
+			return nil
+		},
+	}
+
+	setRole := &cobra.Command{
+		Use:   "set-role EMAIL ROLE",
+		Short: "Make a user an admin or a regular user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer openDB()()
+
+			err := models.SetUserRole(cmd.Context(), args[0], args[1])
+
+			if err != nil {
+				return fmt.Errorf("could not set role: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is now %s.\n", args[0], args[1])
+			return nil
+		},
+	}
+
+	user.AddCommand(create, disable, setRole)
+	return user
+}
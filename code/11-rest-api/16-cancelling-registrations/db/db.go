@@ -1,16 +1,21 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
-func InitDB() {
+func InitDB(path string) {
 	var err error
-	DB, err = sql.Open("sqlite3", "api.db")
+	// Write transactions take the lock up front (BEGIN IMMEDIATE) and wait
+	// for it a while, instead of failing with SQLITE_BUSY when they upgrade
+	// from reading to writing.
+	DB, err = sql.Open("sqlite3", path+"?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on")
 
 	if err != nil {
 		panic("Could not connect to database.")
@@ -19,55 +24,82 @@ func InitDB() {
 	DB.SetMaxOpenConns(10)
 	DB.SetMaxIdleConns(5)
 
-	createTables()
+	migrate()
 }
 
-func createTables() {
-	createUsersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL
-	)
-	`
+// Ready reports whether the database is reachable and every migration has
+// been applied.
+func Ready(ctx context.Context) error {
+	err := DB.PingContext(ctx)
 
-	_, err := DB.Exec(createUsersTable)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = DB.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
 
 	if err != nil {
-		panic("Could not create users table.")
+		return err
+	}
+
+	if version < len(migrations) {
+		return fmt.Errorf("database is at schema version %d, want %d", version, len(migrations))
 	}
 
-	createEventsTable := `
-	CREATE TABLE IF NOT EXISTS events (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT NOT NULL,
-		location TEXT NOT NULL,
-		dateTime DATETIME NOT NULL,
-		user_id INTEGER,
-		FOREIGN KEY(user_id) REFERENCES users(id)
+	return nil
+}
+
+func migrate() {
+	createMigrationsTable := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY
 	)
 	`
 
-	_, err = DB.Exec(createEventsTable)
+	_, err := DB.Exec(createMigrationsTable)
 
 	if err != nil {
-		panic("Could not create events table.")
+		panic("Could not create schema_migrations table.")
 	}
 
-	createRegistrationsTable := `
-	CREATE TABLE IF NOT EXISTS registrations (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		event_id INTEGER,
-		user_id INTEGER,
-		FOREIGN KEY(event_id) REFERENCES events(id),
-		FOREIGN KEY(user_id) REFERENCES users(id)
-	)
-	`
+	for i, migration := range migrations {
+		version := i + 1
+		err := applyMigration(version, migration)
+
+		if err != nil {
+			panic(fmt.Sprintf("Could not apply migration %d: %v", version, err))
+		}
+	}
+}
 
-	_, err = DB.Exec(createRegistrationsTable)
+func applyMigration(version int, migration string) error {
+	tx, err := DB.Begin()
 
 	if err != nil {
-		panic("Could not create registrations table.")
+		return err
 	}
+
+	defer tx.Rollback()
+
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&applied)
+
+	if err != nil || applied {
+		return err
+	}
+
+	_, err = tx.Exec(migration)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations(version) VALUES (?)", version)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestForeignKeysAreEnforced(t *testing.T) {
+	setupDB(t)
+
+	_, err := DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (999, 999)")
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintForeignKey {
+		t.Errorf("dangling registration: got %v, want a foreign key violation", err)
+	}
+}
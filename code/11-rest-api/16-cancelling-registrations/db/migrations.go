@@ -0,0 +1,304 @@
+package db
+
+// migrations are applied in order and recorded in schema_migrations, so a
+// migration must never change once released. Append new ones at the end.
+var migrations = []string{
+	`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL,
+		location TEXT NOT NULL,
+		dateTime DATETIME NOT NULL,
+		user_id INTEGER,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS registrations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER,
+		user_id INTEGER,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`,
+	`
+	ALTER TABLE events ADD COLUMN updated_at DATETIME;
+	UPDATE events SET updated_at = CURRENT_TIMESTAMP;
+	`,
+	// collection_changes tracks when a table last changed, including deletes,
+	// which a MAX(updated_at) can't see.
+	`
+	CREATE TABLE IF NOT EXISTS collection_changes (
+		name TEXT PRIMARY KEY,
+		changed_at DATETIME NOT NULL
+	);
+	INSERT OR IGNORE INTO collection_changes(name, changed_at) VALUES ('events', CURRENT_TIMESTAMP);
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_insert AFTER INSERT ON events BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_update AFTER UPDATE ON events BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_delete AFTER DELETE ON events BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	`,
+	`
+	ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		category TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS event_tags (
+		event_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY(event_id, tag_id),
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(tag_id) REFERENCES tags(id)
+	);
+	CREATE INDEX IF NOT EXISTS event_tags_tag_id ON event_tags(tag_id);
+	`,
+	`
+	ALTER TABLE events ADD COLUMN venue_address TEXT;
+	ALTER TABLE events ADD COLUMN venue_lat REAL;
+	ALTER TABLE events ADD COLUMN venue_lng REAL;
+	CREATE INDEX IF NOT EXISTS events_venue_location ON events(venue_lat, venue_lng);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS organizations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS org_members (
+		org_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		PRIMARY KEY(org_id, user_id),
+		FOREIGN KEY(org_id) REFERENCES organizations(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS org_members_user_id ON org_members(user_id);
+	CREATE TABLE IF NOT EXISTS org_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		role TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		invited_by INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		accepted_at DATETIME,
+		FOREIGN KEY(org_id) REFERENCES organizations(id),
+		FOREIGN KEY(invited_by) REFERENCES users(id)
+	);
+	ALTER TABLE events ADD COLUMN org_id INTEGER REFERENCES organizations(id);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS event_hosts (
+		event_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		permissions TEXT NOT NULL,
+		PRIMARY KEY(event_id, user_id),
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	`,
+	`
+	ALTER TABLE events ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+	CREATE TABLE IF NOT EXISTS event_invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		email TEXT,
+		max_uses INTEGER NOT NULL DEFAULT 0,
+		uses INTEGER NOT NULL DEFAULT 0,
+		last_used_at DATETIME,
+		created_by INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		revoked_at DATETIME,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(created_by) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS event_invites_event_id ON event_invites(event_id);
+	CREATE TABLE IF NOT EXISTS event_invite_uses (
+		invite_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		used_at DATETIME NOT NULL,
+		FOREIGN KEY(invite_id) REFERENCES event_invites(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS ticket_types (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		price INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);
+	CREATE INDEX IF NOT EXISTS ticket_types_event_id ON ticket_types(event_id);
+	CREATE TABLE IF NOT EXISTS orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		ticket_type_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		payment_session_id TEXT UNIQUE,
+		payment_id TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(ticket_type_id) REFERENCES ticket_types(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS orders_ticket_type_status ON orders(ticket_type_id, status);
+	ALTER TABLE registrations ADD COLUMN order_id INTEGER REFERENCES orders(id);
+	`,
+	`
+	ALTER TABLE registrations ADD COLUMN checked_in_at DATETIME;
+	CREATE INDEX IF NOT EXISTS registrations_event_id ON registrations(event_id);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		payload BLOB,
+		unique_key TEXT UNIQUE,
+		status TEXT NOT NULL,
+		run_at DATETIME NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		last_error TEXT,
+		lock_token TEXT,
+		locked_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS jobs_status_run_at ON jobs(status, run_at);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS api_tokens_user_id ON api_tokens(user_id);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(provider, subject),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE TABLE IF NOT EXISTS sso_logins (
+		state_hash TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		verifier TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS user_totp (
+		user_id INTEGER PRIMARY KEY,
+		key_url TEXT NOT NULL,
+		enabled_at DATETIME,
+		last_used_step INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS recovery_codes_user_id ON recovery_codes(user_id);
+	CREATE TABLE IF NOT EXISTS login_challenges (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	`,
+	`
+	ALTER TABLE users ADD COLUMN disabled_at DATETIME;
+	`,
+	`
+	ALTER TABLE user_totp ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE user_totp ADD COLUMN locked_until DATETIME;
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS reminder_deliveries (
+		registration_id INTEGER NOT NULL,
+		lead INTEGER NOT NULL,
+		starts_at INTEGER NOT NULL,
+		sent_at DATETIME NOT NULL,
+		PRIMARY KEY(registration_id, lead, starts_at),
+		FOREIGN KEY(registration_id) REFERENCES registrations(id)
+	);
+	`,
+	`
+	ALTER TABLE users ADD COLUMN sessions_valid_after DATETIME;
+	`,
+	// Older versions could register a user twice. The first registration
+	// is kept.
+	`
+	DELETE FROM reminder_deliveries WHERE registration_id IN (
+		SELECT id FROM registrations WHERE id NOT IN (SELECT MIN(id) FROM registrations GROUP BY event_id, user_id)
+	);
+	DELETE FROM registrations WHERE id NOT IN (SELECT MIN(id) FROM registrations GROUP BY event_id, user_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS registrations_event_user ON registrations(event_id, user_id);
+	`,
+	// Emails are stored trimmed and in lower case. Users whose emails only
+	// differ in case can't be merged here, so the index fails until they
+	// are merged by hand.
+	`
+	UPDATE users SET email = lower(trim(email)) WHERE NOT EXISTS (
+		SELECT 1 FROM users other WHERE other.id <> users.id AND lower(trim(other.email)) = lower(trim(users.email))
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_nocase ON users(email COLLATE NOCASE);
+	`,
+	// The event list shows the tags, so tag writes change it too.
+	`
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_tag_update AFTER UPDATE ON tags BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_tag_delete AFTER DELETE ON tags BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_event_tag_insert AFTER INSERT ON event_tags BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changed_on_event_tag_delete AFTER DELETE ON event_tags BEGIN
+		UPDATE collection_changes SET changed_at = CURRENT_TIMESTAMP WHERE name = 'events';
+	END;
+	`,
+}
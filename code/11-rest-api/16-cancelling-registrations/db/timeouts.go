@@ -0,0 +1,27 @@
+package db
+
+import "time"
+
+// DefaultQueryTimeout bounds every model operation without an entry in
+// QueryTimeouts.
+var DefaultQueryTimeout = 5 * time.Second
+
+// QueryTimeouts overrides DefaultQueryTimeout for individual operations,
+// keyed by the operation name used by the models package (e.g.
+// "FindEvents" or "Event.Save"). The timeout of EachEvent bounds each
+// batch it reads, not the whole export.
+var QueryTimeouts = map[string]time.Duration{
+	"FindEvents": 10 * time.Second,
+	"EachEvent":  30 * time.Second,
+}
+
+// QueryTimeout returns the timeout configured for operation.
+func QueryTimeout(operation string) time.Duration {
+	timeout, ok := QueryTimeouts[operation]
+
+	if !ok {
+		return DefaultQueryTimeout
+	}
+
+	return timeout
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Querier is implemented by both *sql.DB and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxMaxAttempts is how often WithTx runs a transaction that keeps failing
+// because the database is busy.
+var TxMaxAttempts = 5
+
+// txRetryDelay is the backoff before the first retry; it doubles after
+// every further attempt.
+var txRetryDelay = 10 * time.Millisecond
+
+type txKey struct{}
+
+// txState is stored in the context of a running transaction.
+type txState struct {
+	tx          *sql.Tx
+	afterCommit []func()
+}
+
+// Conn returns the transaction started by WithTx for ctx, or DB outside of
+// a transaction. Model methods query through it so they join the caller's
+// transaction.
+func Conn(ctx context.Context) Querier {
+	state, ok := ctx.Value(txKey{}).(*txState)
+
+	if ok {
+		return state.tx
+	}
+
+	return DB
+}
+
+// InTx reports whether ctx carries a transaction started by WithTx.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction joined by ctx has committed, or
+// right away outside of a transaction. Nothing runs if it rolls back.
+func AfterCommit(ctx context.Context, fn func()) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+
+	if !ok {
+		fn()
+		return
+	}
+
+	state.afterCommit = append(state.afterCommit, fn)
+}
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. The
+// context passed to fn carries the transaction, so model methods called
+// with it join the transaction. Nested calls join the outer transaction.
+//
+// If the transaction fails because the database is busy it is retried from
+// the start, so fn must not have side effects outside the database.
+func WithTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx, state.tx)
+	}
+
+	delay := txRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, fn)
+
+		if err == nil || !IsBusy(err) || attempt >= TxMaxAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		delay *= 2
+	}
+}
+
+func runTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	tx, err := DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	state := &txState{tx: tx}
+	err = fn(context.WithValue(ctx, txKey{}, state), tx)
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range state.afterCommit {
+		fn()
+	}
+
+	return nil
+}
+
+// IsBusy reports whether err means SQLite couldn't get a lock in time.
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// IsUniqueViolation reports whether err means an insert or update broke a
+// UNIQUE constraint.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
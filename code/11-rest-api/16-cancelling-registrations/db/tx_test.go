@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { DB.Close() })
+
+	_, err := DB.Exec("CREATE TABLE items (name TEXT NOT NULL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countItems(t *testing.T) int {
+	t.Helper()
+
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM items").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func insertItem(ctx context.Context, name string) error {
+	_, err := Conn(ctx).ExecContext(ctx, "INSERT INTO items(name) VALUES (?)", name)
+	return err
+}
+
+func TestWithTxCommitsAndRollsBack(t *testing.T) {
+	setupDB(t)
+	ctx := context.Background()
+
+	err := WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return insertItem(ctx, "kept")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injected := errors.New("injected failure")
+	err = WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := insertItem(ctx, "dropped"); err != nil {
+			return err
+		}
+		return injected
+	})
+	if !errors.Is(err, injected) {
+		t.Fatalf("got error %v, want %v", err, injected)
+	}
+
+	if got := countItems(t); got != 1 {
+		t.Errorf("got %d items, want 1", got)
+	}
+}
+
+func TestWithTxNestedCallsJoinOuterTransaction(t *testing.T) {
+	setupDB(t)
+
+	injected := errors.New("injected failure")
+	err := WithTx(context.Background(), func(ctx context.Context, outer *sql.Tx) error {
+		err := WithTx(ctx, func(ctx context.Context, inner *sql.Tx) error {
+			if inner != outer {
+				t.Error("nested WithTx started a new transaction")
+			}
+			return insertItem(ctx, "inner")
+		})
+		if err != nil {
+			return err
+		}
+		return injected
+	})
+	if !errors.Is(err, injected) {
+		t.Fatalf("got error %v, want %v", err, injected)
+	}
+
+	if got := countItems(t); got != 0 {
+		t.Errorf("inner work survived the outer rollback: %d items", got)
+	}
+}
+
+func TestWithTxRetriesWhenBusy(t *testing.T) {
+	setupDB(t)
+
+	delay := txRetryDelay
+	txRetryDelay = time.Millisecond
+	t.Cleanup(func() { txRetryDelay = delay })
+
+	attempts := 0
+	err := WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		if err := insertItem(ctx, "item"); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3", attempts)
+	}
+
+	if got := countItems(t); got != 1 {
+		t.Errorf("got %d items, want 1", got)
+	}
+
+	attempts = 0
+	err = WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	if !IsBusy(err) {
+		t.Fatalf("got error %v, want busy error", err)
+	}
+
+	if attempts != TxMaxAttempts {
+		t.Errorf("got %d attempts, want %d", attempts, TxMaxAttempts)
+	}
+}
+
+func TestWithTxDoesNotRetryOtherErrors(t *testing.T) {
+	setupDB(t)
+
+	attempts := 0
+	WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		return errors.New("not busy")
+	})
+
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+}
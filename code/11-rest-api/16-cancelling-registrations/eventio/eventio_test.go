@@ -0,0 +1,141 @@
+package eventio
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/models"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := `Name,Location,Description,dateTime,extra
+Meetup,Berlin,A meetup,2025-01-01T15:30:00Z,ignored
+,Berlin,No name,2025-01-01T15:30:00Z,
+Workshop,Online,Bad date,tomorrow,
+`
+
+	rows, err := ReadCSV(strings.NewReader(input), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	first := rows[0]
+	if first.Err != nil || first.Line != 2 || first.Event.Name != "Meetup" || first.Event.Location != "Berlin" {
+		t.Errorf("unexpected first row %+v", first)
+	}
+
+	if !first.Event.DateTime.Equal(time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC)) {
+		t.Errorf("dateTime = %v", first.Event.DateTime)
+	}
+
+	errs := Errors(rows)
+	if len(errs) != 2 || errs[0].Line != 3 || errs[1].Line != 4 {
+		t.Errorf("unexpected errors %+v", errs)
+	}
+}
+
+func TestReadCSVRejectsBadInput(t *testing.T) {
+	if _, err := ReadCSV(strings.NewReader("name,location\nMeetup,Berlin\n"), 10); err == nil {
+		t.Error("missing columns were accepted")
+	}
+
+	input := "name,description,location,dateTime\n" + strings.Repeat("a,b,c,2025-01-01T15:30:00Z\n", 3)
+	if _, err := ReadCSV(strings.NewReader(input), 2); err == nil {
+		t.Error("row limit was not enforced")
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"name": "Meetup", "description": "A meetup", "location": "Berlin", "dateTime": "2025-01-01T15:30:00Z", "UserID": 99}
+
+{"name": "Broken"
+{"name": "Incomplete", "dateTime": "2025-01-01T15:30:00Z"}
+`
+
+	rows, err := ReadNDJSON(strings.NewReader(input), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	if rows[0].Err != nil || rows[0].Event.Name != "Meetup" || rows[0].Event.UserID != 0 {
+		t.Errorf("unexpected first row %+v", rows[0])
+	}
+
+	errs := Errors(rows)
+	if len(errs) != 2 || errs[0].Line != 3 || errs[1].Line != 4 {
+		t.Errorf("unexpected errors %+v", errs)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	event := models.Event{
+		ID:          7,
+		Name:        "Meetup, with comma",
+		Description: "Line one\nline two",
+		Location:    "Berlin",
+		DateTime:    time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC),
+		UserID:      3,
+	}
+
+	var buf bytes.Buffer
+	writer := NewCSVWriter(&buf)
+
+	if err := writer.Write(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadCSV(&buf, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+
+	got := rows[0].Event
+	if got.Name != event.Name || got.Description != event.Description || !got.DateTime.Equal(event.DateTime) {
+		t.Errorf("got %+v, want %+v", got, event)
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	for _, count := range []int{0, 1, 3} {
+		var buf bytes.Buffer
+		writer := NewJSONWriter(&buf)
+
+		for i := 0; i < count; i++ {
+			if err := writer.Write(models.Event{ID: int64(i + 1)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var events []models.Event
+		if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+			t.Fatalf("%d events: invalid JSON %s: %v", count, buf.Bytes(), err)
+		}
+
+		if len(events) != count {
+			t.Errorf("got %d events, want %d", len(events), count)
+		}
+	}
+}
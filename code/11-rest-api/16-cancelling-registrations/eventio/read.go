@@ -0,0 +1,172 @@
+package eventio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Row is one decoded line of an import. Err is set if the line could not be
+// turned into a valid event.
+type Row struct {
+	Line  int
+	Event models.Event
+	Err   error
+}
+
+// RowError describes why a line of an import was rejected.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// csvColumns are the columns an import must have. Other columns (like the
+// id and user_id of an export) are ignored.
+var csvColumns = []string{"name", "description", "location", "dateTime"}
+
+// ReadCSV decodes events from CSV with a header row naming the columns.
+// Lines are numbered like in a spreadsheet, so the first event is line 2.
+// The returned error is only set if the input can't be read at all.
+func ReadCSV(r io.Reader, maxRows int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+
+	index := map[string]int{}
+
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, column := range csvColumns {
+		if _, ok := index[strings.ToLower(column)]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	var rows []Row
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+
+			rows = append(rows, Row{Line: line, Err: parseErr.Err})
+			continue
+		}
+
+		field := func(column string) string {
+			i := index[strings.ToLower(column)]
+
+			if i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		row := Row{Line: line}
+		row.Event.Name = field("name")
+		row.Event.Description = field("description")
+		row.Event.Location = field("location")
+
+		dateTime := field("dateTime")
+		row.Event.DateTime, row.Err = time.Parse(time.RFC3339, dateTime)
+
+		if row.Err != nil {
+			row.Err = fmt.Errorf("dateTime %q is not an RFC 3339 timestamp", dateTime)
+		} else {
+			row.Err = validate(row.Event)
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+// ReadNDJSON decodes events from newline delimited JSON, one event object
+// per line. Blank lines are skipped.
+func ReadNDJSON(r io.Reader, maxRows int) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []Row
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+
+		row := Row{Line: line}
+		err := json.Unmarshal(data, &row.Event)
+
+		if err != nil {
+			row.Err = fmt.Errorf("invalid JSON: %w", err)
+		} else {
+			// Only the event's content is imported; ownership and ids are
+			// assigned by the server.
+			row.Event = models.Event{
+				Name:        row.Event.Name,
+				Description: row.Event.Description,
+				Location:    row.Event.Location,
+				DateTime:    row.Event.DateTime,
+			}
+			row.Err = validate(row.Event)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+// validate applies the same binding rules as the JSON endpoints.
+func validate(event models.Event) error {
+	return binding.Validator.ValidateStruct(&event)
+}
+
+// Errors collects the errors of rows.
+func Errors(rows []Row) []RowError {
+	rowErrors := []RowError{}
+
+	for _, row := range rows {
+		if row.Err != nil {
+			rowErrors = append(rowErrors, RowError{Line: row.Line, Message: row.Err.Error()})
+		}
+	}
+
+	return rowErrors
+}
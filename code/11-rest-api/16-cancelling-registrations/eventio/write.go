@@ -0,0 +1,111 @@
+package eventio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"example.com/rest-api/models"
+)
+
+// Writer encodes a stream of events. Close must be called after the last
+// event to complete the output.
+type Writer interface {
+	Write(event models.Event) error
+	Close() error
+}
+
+// exportColumns extend the import columns with the fields assigned by the
+// server.
+var exportColumns = []string{"id", "name", "description", "location", "dateTime", "user_id", "updated_at"}
+
+// CSVWriter writes events as CSV that ReadCSV can import again.
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) Write(event models.Event) error {
+	err := c.writeHeader()
+
+	if err != nil {
+		return err
+	}
+
+	return c.w.Write([]string{
+		strconv.FormatInt(event.ID, 10),
+		event.Name,
+		event.Description,
+		event.Location,
+		event.DateTime.Format(time.RFC3339),
+		strconv.FormatInt(event.UserID, 10),
+		event.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// Close writes the header if there were no events and flushes the output.
+func (c *CSVWriter) Close() error {
+	err := c.writeHeader()
+
+	if err != nil {
+		return err
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+
+	c.headerWritten = true
+	return c.w.Write(exportColumns)
+}
+
+// JSONWriter writes events as a JSON array, one element at a time.
+type JSONWriter struct {
+	w       io.Writer
+	encoder *json.Encoder
+	count   int
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w, encoder: json.NewEncoder(w)}
+}
+
+func (j *JSONWriter) Write(event models.Event) error {
+	separator := ","
+
+	if j.count == 0 {
+		separator = "["
+	}
+
+	j.count++
+
+	_, err := io.WriteString(j.w, separator)
+
+	if err != nil {
+		return err
+	}
+
+	return j.encoder.Encode(event)
+}
+
+// Close terminates the array.
+func (j *JSONWriter) Close() error {
+	if j.count == 0 {
+		_, err := io.WriteString(j.w, "[]\n")
+		return err
+	}
+
+	_, err := io.WriteString(j.w, "]\n")
+	return err
+}
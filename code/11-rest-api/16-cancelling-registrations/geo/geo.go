@@ -0,0 +1,156 @@
+// Package geo geocodes venue addresses and does the distance math for
+// nearby searches.
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius of the earth.
+const EarthRadiusKm = 6371.0
+
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// ErrNotFound is returned by a Geocoder that doesn't know an address.
+var ErrNotFound = errors.New("Address not found")
+
+// Geocoder turns an address into coordinates.
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Point, error)
+}
+
+// StaticGeocoder looks addresses up in a fixed table. Lookups ignore case
+// and surrounding whitespace. It needs no network, which makes it suitable
+// for tests and offline setups.
+type StaticGeocoder map[string]Point
+
+func (s StaticGeocoder) Geocode(ctx context.Context, address string) (Point, error) {
+	point, ok := s[normalizeAddress(address)]
+
+	if !ok {
+		return Point{}, ErrNotFound
+	}
+
+	return point, nil
+}
+
+// LoadStatic reads a StaticGeocoder from a JSON file mapping addresses to
+// {"lat": ..., "lng": ...} objects.
+func LoadStatic(path string) (StaticGeocoder, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var table map[string]Point
+	err = json.Unmarshal(data, &table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	geocoder := StaticGeocoder{}
+
+	for address, point := range table {
+		geocoder[normalizeAddress(address)] = point
+	}
+
+	return geocoder, nil
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.Join(strings.Fields(address), " "))
+}
+
+// Distance returns the great-circle distance between a and b in kilometres,
+// using the haversine formula.
+func Distance(a, b Point) float64 {
+	lat1 := radians(a.Lat)
+	lat2 := radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Box is a latitude/longitude rectangle. When MinLng > MaxLng the box
+// crosses the antimeridian and covers longitudes >= MinLng or <= MaxLng.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoundingBox returns a box containing every point within radiusKm of
+// center. It is cheap to check in SQL and is used to narrow down the
+// candidates before computing exact distances.
+func BoundingBox(center Point, radiusKm float64) Box {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	box := Box{
+		MinLat: center.Lat - dLat,
+		MaxLat: center.Lat + dLat,
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	// A circle around a pole covers every longitude.
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	// The widest point of the circle is not at the centre latitude but where
+	// a meridian touches it tangentially.
+	dLng := degrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(center.Lat))))
+
+	if dLng >= 180 {
+		return box
+	}
+
+	box.MinLng = wrapLng(center.Lng - dLng)
+	box.MaxLng = wrapLng(center.Lng + dLng)
+	return box
+}
+
+// Contains reports whether p lies within the box.
+func (b Box) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+
+	if b.MinLng <= b.MaxLng {
+		return p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+	}
+
+	return p.Lng >= b.MinLng || p.Lng <= b.MaxLng
+}
+
+func wrapLng(lng float64) float64 {
+	if lng < -180 {
+		return lng + 360
+	}
+
+	if lng > 180 {
+		return lng - 360
+	}
+
+	return lng
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+var (
+	berlin = Point{Lat: 52.5200, Lng: 13.4050}
+	paris  = Point{Lat: 48.8566, Lng: 2.3522}
+)
+
+func TestDistance(t *testing.T) {
+	if d := Distance(berlin, paris); math.Abs(d-878) > 2 {
+		t.Errorf("Berlin to Paris: got %.1f km, want about 878 km", d)
+	}
+
+	if d := Distance(berlin, berlin); d != 0 {
+		t.Errorf("distance to itself: got %f", d)
+	}
+}
+
+func TestBoundingBoxContainsCircle(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		center Point
+		radius float64
+	}{
+		{"berlin", berlin, 50},
+		{"antimeridian", Point{Lat: -17.7, Lng: 179.9}, 100},
+		{"near pole", Point{Lat: 89.5, Lng: 0}, 100},
+	} {
+		box := BoundingBox(test.center, test.radius)
+
+		// Walk around the circle and check every point lies in the box.
+		for bearing := 0.0; bearing < 360; bearing += 5 {
+			p := destination(test.center, bearing, test.radius*0.999)
+
+			if !box.Contains(p) {
+				t.Errorf("%s: %+v at bearing %.0f is outside %+v", test.name, p, bearing, box)
+			}
+		}
+	}
+
+	if box := BoundingBox(Point{Lat: -17.7, Lng: 179.9}, 100); box.MinLng <= box.MaxLng {
+		t.Errorf("box across the antimeridian doesn't wrap: %+v", box)
+	}
+
+	if box := BoundingBox(berlin, 50); box.Contains(paris) {
+		t.Error("box around Berlin contains Paris")
+	}
+}
+
+// destination returns the point distanceKm away from start in the given
+// direction.
+func destination(start Point, bearingDeg, distanceKm float64) Point {
+	lat1 := radians(start.Lat)
+	lng1 := radians(start.Lng)
+	bearing := radians(bearingDeg)
+	d := distanceKm / EarthRadiusKm
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(bearing))
+	lng2 := lng1 + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	return Point{Lat: degrees(lat2), Lng: wrapLng(degrees(lng2))}
+}
+
+func TestStaticGeocoder(t *testing.T) {
+	geocoder := StaticGeocoder{"alexanderplatz 1, berlin": berlin}
+
+	point, err := geocoder.Geocode(context.Background(), "  Alexanderplatz 1,  Berlin ")
+	if err != nil || point != berlin {
+		t.Errorf("got %+v, %v", point, err)
+	}
+
+	_, err = geocoder.Geocode(context.Background(), "Nowhere")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown address: got error %v, want ErrNotFound", err)
+	}
+}
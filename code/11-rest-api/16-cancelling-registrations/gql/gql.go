@@ -0,0 +1,37 @@
+// Package gql serves a GraphQL API over events, users and registrations
+// next to the REST routes. It uses the same models and permission checks;
+// nested fields are batched with per-request Loaders.
+package gql
+
+import (
+	_ "embed"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+//go:embed schema.graphql
+var schemaSource string
+
+// maxDepth bounds the nesting of queries, which could otherwise walk
+// events and registrations back and forth without end.
+const maxDepth = 8
+
+// NewHandler parses the schema and returns a handler for GraphQL requests
+// sent as JSON by POST. The id of the authenticated user must be stored in
+// the request context with WithUserID, and the API token it used, if any,
+// with WithAPIToken.
+func NewHandler() (http.Handler, error) {
+	schema, err := graphql.ParseSchema(schemaSource, &resolver{}, graphql.MaxDepth(maxDepth))
+
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &relay.Handler{Schema: schema}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(withLoaders(r.Context(), newLoaders())))
+	}), nil
+}
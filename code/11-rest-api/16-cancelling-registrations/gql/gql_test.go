@@ -0,0 +1,184 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+func TestLoaderBatchesPrimedKeys(t *testing.T) {
+	var calls [][]int
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		calls = append(calls, keys)
+		values := map[int]string{}
+
+		for _, key := range keys {
+			if key != 3 {
+				values[key] = "value"
+			}
+		}
+
+		return values, nil
+	})
+	ctx := context.Background()
+
+	loader.Prime(1, 2, 3, 2)
+
+	for _, key := range []int{1, 2, 1} {
+		if value, ok, err := loader.Load(ctx, key); value != "value" || !ok || err != nil {
+			t.Errorf("Load(%d) = %q, %v, %v", key, value, ok, err)
+		}
+	}
+
+	if _, ok, _ := loader.Load(ctx, 3); ok {
+		t.Error("Load(3) found a value fetch didn't return")
+	}
+
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Errorf("got fetch calls %v, want one with keys 1, 2 and 3", calls)
+	}
+
+	loader.Load(ctx, 4)
+
+	if loader.Batches() != 2 {
+		t.Errorf("got %d batches, want 2", loader.Batches())
+	}
+}
+
+func TestLoaderRetriesAfterError(t *testing.T) {
+	fail := true
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		if fail {
+			return nil, errors.New("database is down")
+		}
+
+		return map[int]int{keys[0]: 1}, nil
+	})
+
+	if _, _, err := loader.Load(context.Background(), 1); err == nil {
+		t.Fatal("expected the fetch error")
+	}
+
+	fail = false
+
+	if value, ok, err := loader.Load(context.Background(), 1); value != 1 || !ok || err != nil {
+		t.Errorf("after recovery: got %d, %v, %v", value, ok, err)
+	}
+}
+
+func TestNestedFieldsAreBatched(t *testing.T) {
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+	ctx := context.Background()
+
+	_, err := db.DB.Exec("INSERT INTO users(email, password) VALUES ('a@example.com', 'x'), ('b@example.com', 'x'), ('c@example.com', 'x')")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 6; i++ {
+		event := models.Event{
+			Name:        "Meetup",
+			Description: "A meetup",
+			Location:    "Berlin",
+			DateTime:    time.Date(2025, 1, i, 18, 0, 0, 0, time.UTC),
+			UserID:      int64(i%3 + 1),
+		}
+
+		err := event.Save(ctx)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = event.Register(ctx, 1, "")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schema := graphql.MustParseSchema(schemaSource, &resolver{})
+	ctx = WithUserID(ctx, 1)
+
+	l := newLoaders()
+	res := schema.Exec(withLoaders(ctx, l), `{ events { id attendeeCount host { id email } } }`, "", nil)
+
+	if len(res.Errors) > 0 {
+		t.Fatal(res.Errors)
+	}
+
+	var events struct {
+		Events []struct {
+			AttendeeCount int
+			Host          struct{ Email *string }
+		}
+	}
+	err = json.Unmarshal(res.Data, &events)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events.Events) != 6 || events.Events[0].AttendeeCount != 1 {
+		t.Fatalf("got %s", res.Data)
+	}
+
+	for _, event := range events.Events {
+		if event.Host.Email != nil && *event.Host.Email != "a@example.com" {
+			t.Errorf("host email %q is visible to another user", *event.Host.Email)
+		}
+	}
+
+	if l.users.Batches() != 1 || l.registrationCounts.Batches() != 1 {
+		t.Errorf("got %d user and %d count batches, want 1 each", l.users.Batches(), l.registrationCounts.Batches())
+	}
+
+	l = newLoaders()
+	res = schema.Exec(withLoaders(ctx, l), `{ me { registrations { event { name attendeeCount host { id } } } } }`, "", nil)
+
+	if len(res.Errors) > 0 {
+		t.Fatal(res.Errors)
+	}
+
+	var me struct {
+		Me struct {
+			Registrations []struct{ Event struct{ AttendeeCount int } }
+		}
+	}
+	err = json.Unmarshal(res.Data, &me)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(me.Me.Registrations) != 6 {
+		t.Fatalf("got %s", res.Data)
+	}
+
+	// One batch for me and one for the hosts of all events.
+	if l.events.Batches() != 1 || l.registrationCounts.Batches() != 1 || l.users.Batches() != 2 {
+		t.Errorf("got %d event, %d count and %d user batches, want 1, 1 and 2",
+			l.events.Batches(), l.registrationCounts.Batches(), l.users.Batches())
+	}
+
+	// User 1 hosts two of the events and may only list their attendees.
+	l = newLoaders()
+	res = schema.Exec(withLoaders(ctx, l), `{ events { registrations { id } } }`, "", nil)
+
+	if len(res.Errors) != 4 {
+		t.Errorf("got errors %v, want 4 for the events of other hosts", res.Errors)
+	}
+
+	if l.attendeeAccess.Batches() != 1 || l.registrations.Batches() != 1 {
+		t.Errorf("got %d permission and %d registration batches, want 1 each", l.attendeeAccess.Batches(), l.registrations.Batches())
+	}
+}
@@ -0,0 +1,207 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"example.com/rest-api/models"
+)
+
+// Loader batches lookups by key like a dataloader. Resolvers returning a
+// list Prime the keys their items will need, and the first Load fetches
+// all primed keys with a single call to fetch. Later Loads are answered
+// from memory. A Loader lives for one request.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	known   map[K]bool
+	values  map[K]V
+	batches int
+}
+
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, known: map[K]bool{}, values: map[K]V{}}
+}
+
+// Prime queues keys for the next fetch.
+func (l *Loader[K, V]) Prime(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prime(keys)
+}
+
+func (l *Loader[K, V]) prime(keys []K) {
+	for _, key := range keys {
+		if !l.known[key] {
+			l.known[key] = true
+			l.pending = append(l.pending, key)
+		}
+	}
+}
+
+// Load returns the value for key, fetching it together with every primed
+// key if it isn't known yet. ok is false if fetch found no value.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (value V, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prime([]K{key})
+
+	if len(l.pending) > 0 {
+		keys := l.pending
+		values, err := l.fetch(ctx, keys)
+
+		if err != nil {
+			// Forget the keys so a later Load tries again.
+			for _, key := range keys {
+				delete(l.known, key)
+			}
+
+			l.pending = nil
+			return value, false, err
+		}
+
+		l.batches++
+		l.pending = nil
+
+		for key, value := range values {
+			l.values[key] = value
+		}
+	}
+
+	value, ok = l.values[key]
+	return value, ok, nil
+}
+
+// Batches returns how often the loader called fetch successfully.
+func (l *Loader[K, V]) Batches() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.batches
+}
+
+// loaders are the Loaders of one request.
+type loaders struct {
+	users              *Loader[int64, models.User]
+	events             *Loader[int64, models.Event]
+	registrationCounts *Loader[int64, int]
+	// attendeeAccess tells whether the viewer may see the attendees of an
+	// event, and registrations loads them where they may.
+	attendeeAccess *Loader[int64, bool]
+	registrations  *Loader[int64, []models.Registration]
+
+	mu sync.Mutex
+	// seen are the events primed so far, which attendeeAccess needs
+	// whole.
+	seen map[int64]models.Event
+}
+
+func newLoaders() *loaders {
+	l := &loaders{
+		users:              NewLoader(models.GetUsersByIDs),
+		registrationCounts: NewLoader(models.CountRegistrations),
+		seen:               map[int64]models.Event{},
+	}
+
+	l.attendeeAccess = NewLoader(func(ctx context.Context, ids []int64) (map[int64]bool, error) {
+		return models.CanEach(ctx, viewerID(ctx), models.PermViewAttendees, l.seenEvents(ids))
+	})
+
+	// Only the attendees the viewer may see are fetched.
+	l.registrations = NewLoader(func(ctx context.Context, ids []int64) (map[int64][]models.Registration, error) {
+		var allowed []int64
+
+		for _, id := range ids {
+			ok, _, err := l.attendeeAccess.Load(ctx, id)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				allowed = append(allowed, id)
+			}
+		}
+
+		return models.RegistrationsOfEvents(ctx, allowed)
+	})
+
+	l.events = NewLoader(func(ctx context.Context, ids []int64) (map[int64]models.Event, error) {
+		events, err := models.GetEventsByIDs(ctx, ids)
+
+		if err == nil {
+			l.primeEvents(eventsOf(events))
+		}
+
+		return events, err
+	})
+
+	return l
+}
+
+// primeEvents queues what the fields of the events will load.
+func (l *loaders) primeEvents(events []models.Event) {
+	// The lock is released before priming, as attendeeAccess takes it
+	// while fetching.
+	l.mu.Lock()
+
+	for _, event := range events {
+		l.seen[event.ID] = event
+	}
+
+	l.mu.Unlock()
+
+	for _, event := range events {
+		l.users.Prime(event.UserID)
+		l.registrationCounts.Prime(event.ID)
+		l.attendeeAccess.Prime(event.ID)
+		l.registrations.Prime(event.ID)
+	}
+}
+
+func (l *loaders) seenEvents(ids []int64) []models.Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := make([]models.Event, 0, len(ids))
+
+	for _, id := range ids {
+		if event, ok := l.seen[id]; ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func eventsOf(byId map[int64]models.Event) []models.Event {
+	events := make([]models.Event, 0, len(byId))
+
+	for _, event := range byId {
+		events = append(events, event)
+	}
+
+	return events
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+// loadersFrom returns the request's loaders. Outside of a request, as in
+// tests calling the schema directly, every call gets new ones.
+func loadersFrom(ctx context.Context) *loaders {
+	l, ok := ctx.Value(loadersKey{}).(*loaders)
+
+	if !ok {
+		return newLoaders()
+	}
+
+	return l
+}
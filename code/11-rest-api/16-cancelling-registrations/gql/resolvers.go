@@ -0,0 +1,645 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+var (
+	errNotAuthorized = errors.New("Not authorized.")
+	errNotAllowed    = errors.New("Not allowed.")
+	errInvalidID     = errors.New("Invalid id.")
+	errEventNotFound = errors.New("Could not find event.")
+)
+
+// internalError logs err through the request logger and returns a
+// GraphQL error with only message in it.
+func internalError(ctx context.Context, message string, err error) error {
+	logging.FromContext(ctx).Error(message, "error", err)
+	return errors.New(message)
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+
+	if err != nil {
+		return 0, errInvalidID
+	}
+
+	return n, nil
+}
+
+func formatID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+type userIDKey struct{}
+
+// WithUserID stores the id of the authenticated user, 0 for anonymous
+// requests.
+func WithUserID(ctx context.Context, userId int64) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userId)
+}
+
+func viewerID(ctx context.Context) int64 {
+	userId, _ := ctx.Value(userIDKey{}).(int64)
+	return userId
+}
+
+type apiTokenKey struct{}
+
+// WithAPIToken stores the API token the request was authenticated with, nil
+// for password logins. Mutations check its scopes.
+func WithAPIToken(ctx context.Context, token *models.APIToken) context.Context {
+	return context.WithValue(ctx, apiTokenKey{}, token)
+}
+
+// requireScope fails if the request was made with an API token lacking the
+// scope.
+func requireScope(ctx context.Context, scope models.Scope) error {
+	token, _ := ctx.Value(apiTokenKey{}).(*models.APIToken)
+
+	if token != nil && !token.HasScope(scope) {
+		return fmt.Errorf("Token lacks the %s scope.", scope)
+	}
+
+	return nil
+}
+
+func requireViewer(ctx context.Context) (int64, error) {
+	userId := viewerID(ctx)
+
+	if userId == 0 {
+		return 0, errNotAuthorized
+	}
+
+	return userId, nil
+}
+
+type resolver struct{}
+
+func (r *resolver) Events(ctx context.Context, args struct {
+	Tags *[]string
+	Org  *graphql.ID
+}) ([]*eventResolver, error) {
+	err := requireScope(ctx, models.ScopeEventsRead)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var filter models.EventFilter
+
+	if args.Tags != nil {
+		filter.Tags = *args.Tags
+	}
+
+	if args.Org != nil {
+		orgId, err := parseID(*args.Org)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filter.OrgID = orgId
+	}
+
+	events, err := models.FindEvents(ctx, filter)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch events. Try again later.", err)
+	}
+
+	return newEventResolvers(ctx, events), nil
+}
+
+func (r *resolver) Event(ctx context.Context, args struct {
+	ID     graphql.ID
+	Invite *string
+}) (*eventResolver, error) {
+	err := requireScope(ctx, models.ScopeEventsRead)
+
+	if err != nil {
+		return nil, err
+	}
+
+	eventId, err := parseID(args.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invite := ""
+
+	if args.Invite != nil {
+		invite = *args.Invite
+	}
+
+	event, err := models.GetVisibleEvent(ctx, eventId, viewerID(ctx), invite)
+
+	if errors.Is(err, models.ErrEventNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch event.", err)
+	}
+
+	return newEventResolvers(ctx, []models.Event{*event})[0], nil
+}
+
+func (r *resolver) Me(ctx context.Context) (*userResolver, error) {
+	userId := viewerID(ctx)
+
+	if userId == 0 {
+		return nil, nil
+	}
+
+	user, ok, err := loadersFrom(ctx).users.Load(ctx, userId)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch user.", err)
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &userResolver{user: user}, nil
+}
+
+type eventInput struct {
+	Name        string
+	Description string
+	Location    string
+	DateTime    graphql.Time
+	Visibility  *string
+	OrgID       *graphql.ID
+	Tags        *[]string
+}
+
+// event converts and validates the input.
+func (in eventInput) event() (models.Event, error) {
+	event := models.Event{
+		Name:        in.Name,
+		Description: in.Description,
+		Location:    in.Location,
+		DateTime:    in.DateTime.Time,
+	}
+
+	if in.Visibility != nil {
+		event.Visibility = *in.Visibility
+	}
+
+	if in.Tags != nil {
+		event.Tags = *in.Tags
+	}
+
+	err := event.Validate()
+
+	if errors.Is(err, models.ErrInvalidVisibility) {
+		return event, errors.New("Visibility must be public, unlisted or private.")
+	}
+
+	if err != nil {
+		return event, errors.New("Could not parse request data.")
+	}
+
+	return event, nil
+}
+
+func (r *resolver) CreateEvent(ctx context.Context, args struct{ Input eventInput }) (*eventResolver, error) {
+	err := requireScope(ctx, models.ScopeEventsWrite)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requireViewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := args.Input.event()
+
+	if err != nil {
+		return nil, err
+	}
+
+	event.UserID = userId
+
+	if args.Input.OrgID != nil {
+		event.OrgID, err = parseID(*args.Input.OrgID)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = event.CheckCreator(ctx)
+
+	if errors.Is(err, models.ErrNotOrgMember) {
+		return nil, errors.New("Not a member of the organization.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not check permissions.", err)
+	}
+
+	err = event.Save(ctx)
+
+	if errors.Is(err, models.ErrUnknownTag) {
+		return nil, errors.New("Unknown tag.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not create event. Try again later.", err)
+	}
+
+	return newEventResolvers(ctx, []models.Event{event})[0], nil
+}
+
+func (r *resolver) UpdateEvent(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input eventInput
+}) (*eventResolver, error) {
+	err := requireScope(ctx, models.ScopeEventsWrite)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := loadEventWith(ctx, args.ID, models.PermEditEvent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := args.Input.event()
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated.ID = event.ID
+	err = updated.Update(ctx)
+
+	if errors.Is(err, models.ErrUnknownTag) {
+		return nil, errors.New("Unknown tag.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not update event.", err)
+	}
+
+	event, err = models.GetEventByID(ctx, event.ID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch event.", err)
+	}
+
+	return newEventResolvers(ctx, []models.Event{*event})[0], nil
+}
+
+func (r *resolver) DeleteEvent(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	err := requireScope(ctx, models.ScopeEventsWrite)
+
+	if err != nil {
+		return false, err
+	}
+
+	event, err := loadEventWith(ctx, args.ID, models.PermDeleteEvent)
+
+	if err != nil {
+		return false, err
+	}
+
+	err = event.Delete(ctx)
+
+	if errors.Is(err, models.ErrEventHasPaidOrders) {
+		return false, errors.New("The event has paid tickets that must be refunded first.")
+	}
+
+	if err != nil {
+		return false, internalError(ctx, "Could not delete the event.", err)
+	}
+
+	return true, nil
+}
+
+func (r *resolver) RegisterForEvent(ctx context.Context, args struct {
+	EventID graphql.ID
+	Invite  *string
+}) (bool, error) {
+	err := requireScope(ctx, models.ScopeRegistrationsWrite)
+
+	if err != nil {
+		return false, err
+	}
+
+	userId, err := requireViewer(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	eventId, err := parseID(args.EventID)
+
+	if err != nil {
+		return false, err
+	}
+
+	invite := ""
+
+	if args.Invite != nil {
+		invite = *args.Invite
+	}
+
+	event, err := models.GetVisibleEvent(ctx, eventId, userId, invite)
+
+	if err != nil {
+		return false, eventError(ctx, err)
+	}
+
+	err = event.Register(ctx, userId, invite)
+
+	switch {
+	case errors.Is(err, models.ErrEventNotFound):
+		return false, errEventNotFound
+	case errors.Is(err, models.ErrInviteRequired):
+		return false, errors.New("This event is invite-only.")
+	case errors.Is(err, models.ErrInvalidInvite):
+		return false, errors.New("The invite is not valid.")
+	case errors.Is(err, models.ErrAlreadyRegistered):
+		return false, errors.New("Already registered.")
+	case errors.Is(err, models.ErrTicketRequired):
+		return false, errors.New("This event requires a ticket.")
+	case err != nil:
+		return false, internalError(ctx, "Could not register user for event.", err)
+	}
+
+	return true, nil
+}
+
+func (r *resolver) CancelRegistration(ctx context.Context, args struct{ EventID graphql.ID }) (bool, error) {
+	err := requireScope(ctx, models.ScopeRegistrationsWrite)
+
+	if err != nil {
+		return false, err
+	}
+
+	userId, err := requireViewer(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	eventId, err := parseID(args.EventID)
+
+	if err != nil {
+		return false, err
+	}
+
+	event := models.Event{ID: eventId}
+	err = event.CancelRegistration(ctx, userId)
+
+	if errors.Is(err, models.ErrRegistrationNotFound) {
+		return false, errors.New("Not registered for this event.")
+	}
+
+	if errors.Is(err, models.ErrPaymentsDisabled) {
+		return false, errors.New("Refunds are not available.")
+	}
+
+	if err != nil {
+		return false, internalError(ctx, "Could not cancel registration.", err)
+	}
+
+	return true, nil
+}
+
+// loadEventWith fetches the event and checks that the viewer has the
+// permission on it.
+func loadEventWith(ctx context.Context, id graphql.ID, permission models.Permission) (*models.Event, error) {
+	userId, err := requireViewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	eventId, err := parseID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetEventWith(ctx, eventId, userId, permission)
+
+	if err != nil {
+		return nil, eventError(ctx, err)
+	}
+
+	return event, nil
+}
+
+// eventError turns an error from fetching an event into the one returned
+// to the client.
+func eventError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, models.ErrEventNotFound):
+		return errEventNotFound
+	case errors.Is(err, models.ErrNotAllowed):
+		return errNotAllowed
+	default:
+		return internalError(ctx, "Could not fetch event.", err)
+	}
+}
+
+type eventResolver struct {
+	event models.Event
+}
+
+// newEventResolvers wraps the events and primes the loaders for their
+// fields, so a list of events costs one query per field.
+func newEventResolvers(ctx context.Context, events []models.Event) []*eventResolver {
+	loadersFrom(ctx).primeEvents(events)
+	resolvers := make([]*eventResolver, len(events))
+
+	for i, event := range events {
+		resolvers[i] = &eventResolver{event: event}
+	}
+
+	return resolvers
+}
+
+func (e *eventResolver) ID() graphql.ID         { return formatID(e.event.ID) }
+func (e *eventResolver) Name() string           { return e.event.Name }
+func (e *eventResolver) Description() string    { return e.event.Description }
+func (e *eventResolver) Location() string       { return e.event.Location }
+func (e *eventResolver) DateTime() graphql.Time { return graphql.Time{Time: e.event.DateTime} }
+func (e *eventResolver) Visibility() string     { return e.event.Visibility }
+
+func (e *eventResolver) Tags() []string {
+	if e.event.Tags == nil {
+		return []string{}
+	}
+
+	return e.event.Tags
+}
+
+func (e *eventResolver) Host(ctx context.Context) (*userResolver, error) {
+	user, ok, err := loadersFrom(ctx).users.Load(ctx, e.event.UserID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch user.", err)
+	}
+
+	if !ok {
+		return nil, errors.New("Could not find user.")
+	}
+
+	return &userResolver{user: user}, nil
+}
+
+func (e *eventResolver) AttendeeCount(ctx context.Context) (int32, error) {
+	n, _, err := loadersFrom(ctx).registrationCounts.Load(ctx, e.event.ID)
+
+	if err != nil {
+		return 0, internalError(ctx, "Could not fetch attendees.", err)
+	}
+
+	return int32(n), nil
+}
+
+func (e *eventResolver) Registrations(ctx context.Context) ([]*registrationResolver, error) {
+	err := requireScope(ctx, models.ScopeEventsRead)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = requireViewer(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	l := loadersFrom(ctx)
+	allowed, _, err := l.attendeeAccess.Load(ctx, e.event.ID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not check permissions.", err)
+	}
+
+	if !allowed {
+		return nil, errNotAllowed
+	}
+
+	registrations, _, err := l.registrations.Load(ctx, e.event.ID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch attendees.", err)
+	}
+
+	return newRegistrationResolvers(ctx, registrations, true), nil
+}
+
+type userResolver struct {
+	user models.User
+	// showEmail is set when the user was reached through the attendees of
+	// an event the viewer may see.
+	showEmail bool
+}
+
+func (u *userResolver) ID() graphql.ID { return formatID(u.user.ID) }
+
+func (u *userResolver) Email(ctx context.Context) *string {
+	if !u.showEmail && u.user.ID != viewerID(ctx) {
+		return nil
+	}
+
+	return &u.user.Email
+}
+
+func (u *userResolver) Registrations(ctx context.Context) ([]*registrationResolver, error) {
+	err := requireScope(ctx, models.ScopeRegistrationsRead)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if u.user.ID != viewerID(ctx) {
+		return nil, errNotAllowed
+	}
+
+	registrations, err := models.GetRegistrationsForUser(ctx, u.user.ID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch registrations.", err)
+	}
+
+	return newRegistrationResolvers(ctx, registrations, false), nil
+}
+
+type registrationResolver struct {
+	registration models.Registration
+	showEmail    bool
+}
+
+func newRegistrationResolvers(ctx context.Context, registrations []models.Registration, showEmail bool) []*registrationResolver {
+	l := loadersFrom(ctx)
+	resolvers := make([]*registrationResolver, len(registrations))
+
+	for i, registration := range registrations {
+		l.events.Prime(registration.EventID)
+		l.users.Prime(registration.UserID)
+		resolvers[i] = &registrationResolver{registration: registration, showEmail: showEmail}
+	}
+
+	return resolvers
+}
+
+func (r *registrationResolver) ID() graphql.ID { return formatID(r.registration.ID) }
+
+func (r *registrationResolver) CheckedInAt() *graphql.Time {
+	if r.registration.CheckedInAt == nil {
+		return nil
+	}
+
+	return &graphql.Time{Time: *r.registration.CheckedInAt}
+}
+
+func (r *registrationResolver) Event(ctx context.Context) (*eventResolver, error) {
+	event, ok, err := loadersFrom(ctx).events.Load(ctx, r.registration.EventID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch event.", err)
+	}
+
+	if !ok {
+		return nil, errEventNotFound
+	}
+
+	return &eventResolver{event: event}, nil
+}
+
+func (r *registrationResolver) User(ctx context.Context) (*userResolver, error) {
+	user, ok, err := loadersFrom(ctx).users.Load(ctx, r.registration.UserID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch user.", err)
+	}
+
+	if !ok {
+		return nil, errors.New("Could not find user.")
+	}
+
+	return &userResolver{user: user, showEmail: r.showEmail}, nil
+}
@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
+	"example.com/rest-api/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var errNotAuthorized = status.Error(codes.Unauthenticated, "Not authorized.")
+
+type userIDKey struct{}
+
+// userID returns the id of the authenticated user, 0 for anonymous calls.
+func userID(ctx context.Context) int64 {
+	userId, _ := ctx.Value(userIDKey{}).(int64)
+	return userId
+}
+
+func requireUser(ctx context.Context) (int64, error) {
+	userId := userID(ctx)
+
+	if userId == 0 {
+		return 0, errNotAuthorized
+	}
+
+	return userId, nil
+}
+
+// authenticate stores the user of the token sent as "authorization"
+// metadata in the context. Unlike the optional REST authentication an
+// invalid token fails the call, since a service sending one is broken
+// rather than anonymous. The "Bearer " prefix sent by gRPC's per-RPC
+// credentials is accepted.
+func authenticate(ctx context.Context) (context.Context, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+
+	if len(values) == 0 || values[0] == "" {
+		return ctx, nil
+	}
+
+	userId, issuedAt, err := utils.VerifyToken(strings.TrimPrefix(values[0], "Bearer "))
+
+	if err != nil {
+		logging.FromContext(ctx).Info("Rejected token", "error", err)
+		return nil, errNotAuthorized
+	}
+
+	err = models.CheckSession(ctx, userId, issuedAt)
+
+	if errors.Is(err, models.ErrUserDisabled) || errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrSessionRevoked) {
+		logging.FromContext(ctx).Info("Rejected token", "error", err)
+		return nil, errNotAuthorized
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not authenticate user.", err)
+	}
+
+	return context.WithValue(ctx, userIDKey{}, userId), nil
+}
+
+func unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func streamAuth(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(stream.Context())
+
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream replaces the context of a stream with one carrying
+// the user.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,414 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"example.com/rest-api/grpcapi/eventsv1"
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	errNotAllowed    = status.Error(codes.PermissionDenied, "Not allowed.")
+	errEventNotFound = status.Error(codes.NotFound, "Could not find event.")
+	errInvalidInput  = status.Error(codes.InvalidArgument, "Could not parse request data.")
+)
+
+// watchBuffer is how many changes a watcher may fall behind before it is
+// disconnected.
+const watchBuffer = 64
+
+// internalError logs err and returns an Internal status carrying only
+// message.
+func internalError(ctx context.Context, message string, err error) error {
+	logging.FromContext(ctx).Error(message, "error", err)
+	return status.Error(codes.Internal, message)
+}
+
+type eventService struct {
+	eventsv1.UnimplementedEventServiceServer
+
+	// stopping is closed when the server shuts down.
+	stopping chan struct{}
+}
+
+func (s *eventService) ListEvents(ctx context.Context, req *eventsv1.ListEventsRequest) (*eventsv1.ListEventsResponse, error) {
+	events, err := models.FindEvents(ctx, models.EventFilter{Tags: req.Tags, OrgID: req.OrgId})
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch events. Try again later.", err)
+	}
+
+	res := &eventsv1.ListEventsResponse{Events: make([]*eventsv1.Event, len(events))}
+
+	for i, event := range events {
+		res.Events[i] = eventMessage(event)
+	}
+
+	return res, nil
+}
+
+func (s *eventService) GetEvent(ctx context.Context, req *eventsv1.GetEventRequest) (*eventsv1.Event, error) {
+	event, err := models.GetVisibleEvent(ctx, req.Id, userID(ctx), req.Invite)
+
+	if err != nil {
+		return nil, eventError(ctx, err)
+	}
+
+	return eventMessage(*event), nil
+}
+
+func (s *eventService) CreateEvent(ctx context.Context, req *eventsv1.CreateEventRequest) (*eventsv1.Event, error) {
+	userId, err := requireUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := eventFromInput(req.Event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event.UserID = userId
+	event.OrgID = req.OrgId
+	err = event.CheckCreator(ctx)
+
+	if errors.Is(err, models.ErrNotOrgMember) {
+		return nil, status.Error(codes.PermissionDenied, "Not a member of the organization.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not check permissions.", err)
+	}
+
+	err = event.Save(ctx)
+
+	if errors.Is(err, models.ErrUnknownTag) {
+		return nil, status.Error(codes.InvalidArgument, "Unknown tag.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not create event. Try again later.", err)
+	}
+
+	return eventMessage(event), nil
+}
+
+func (s *eventService) UpdateEvent(ctx context.Context, req *eventsv1.UpdateEventRequest) (*eventsv1.Event, error) {
+	event, err := loadEventWith(ctx, req.Id, models.PermEditEvent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := eventFromInput(req.Event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated.ID = event.ID
+	err = updated.Update(ctx)
+
+	if errors.Is(err, models.ErrUnknownTag) {
+		return nil, status.Error(codes.InvalidArgument, "Unknown tag.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not update event.", err)
+	}
+
+	event, err = models.GetEventByID(ctx, event.ID)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch event.", err)
+	}
+
+	return eventMessage(*event), nil
+}
+
+func (s *eventService) DeleteEvent(ctx context.Context, req *eventsv1.DeleteEventRequest) (*eventsv1.DeleteEventResponse, error) {
+	event, err := loadEventWith(ctx, req.Id, models.PermDeleteEvent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = event.Delete(ctx)
+
+	if errors.Is(err, models.ErrEventHasPaidOrders) {
+		return nil, status.Error(codes.FailedPrecondition, "The event has paid tickets that must be refunded first.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not delete the event.", err)
+	}
+
+	return &eventsv1.DeleteEventResponse{}, nil
+}
+
+func (s *eventService) RegisterForEvent(ctx context.Context, req *eventsv1.RegisterForEventRequest) (*eventsv1.RegisterForEventResponse, error) {
+	userId, err := requireUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetVisibleEvent(ctx, req.EventId, userId, req.Invite)
+
+	if err != nil {
+		return nil, eventError(ctx, err)
+	}
+
+	err = event.Register(ctx, userId, req.Invite)
+
+	switch {
+	case errors.Is(err, models.ErrEventNotFound):
+		return nil, errEventNotFound
+	case errors.Is(err, models.ErrInviteRequired):
+		return nil, status.Error(codes.PermissionDenied, "This event is invite-only.")
+	case errors.Is(err, models.ErrInvalidInvite):
+		return nil, status.Error(codes.PermissionDenied, "The invite is not valid.")
+	case errors.Is(err, models.ErrAlreadyRegistered):
+		return nil, status.Error(codes.AlreadyExists, "Already registered.")
+	case errors.Is(err, models.ErrTicketRequired):
+		return nil, status.Error(codes.FailedPrecondition, "This event requires a ticket.")
+	case err != nil:
+		return nil, internalError(ctx, "Could not register user for event.", err)
+	}
+
+	return &eventsv1.RegisterForEventResponse{}, nil
+}
+
+func (s *eventService) CancelRegistration(ctx context.Context, req *eventsv1.CancelRegistrationRequest) (*eventsv1.CancelRegistrationResponse, error) {
+	userId, err := requireUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetVisibleEvent(ctx, req.EventId, userId, "")
+
+	if err != nil {
+		return nil, eventError(ctx, err)
+	}
+
+	err = event.CancelRegistration(ctx, userId)
+
+	if errors.Is(err, models.ErrRegistrationNotFound) {
+		return nil, status.Error(codes.NotFound, "Not registered for this event.")
+	}
+
+	if errors.Is(err, models.ErrPaymentsDisabled) {
+		return nil, status.Error(codes.Unavailable, "Refunds are not available.")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not cancel registration.", err)
+	}
+
+	return &eventsv1.CancelRegistrationResponse{}, nil
+}
+
+func (s *eventService) ListRegistrations(ctx context.Context, req *eventsv1.ListRegistrationsRequest) (*eventsv1.ListRegistrationsResponse, error) {
+	event, err := loadEventWith(ctx, req.EventId, models.PermViewAttendees)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registrations, err := event.Registrations(ctx)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch attendees.", err)
+	}
+
+	userIds := make([]int64, len(registrations))
+
+	for i, registration := range registrations {
+		userIds[i] = registration.UserID
+	}
+
+	users, err := models.GetUsersByIDs(ctx, userIds)
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch attendees.", err)
+	}
+
+	res := &eventsv1.ListRegistrationsResponse{Registrations: make([]*eventsv1.Registration, len(registrations))}
+
+	for i, registration := range registrations {
+		res.Registrations[i] = &eventsv1.Registration{
+			Id:      registration.ID,
+			EventId: registration.EventID,
+			UserId:  registration.UserID,
+			Email:   users[registration.UserID].Email,
+		}
+
+		if registration.CheckedInAt != nil {
+			res.Registrations[i].CheckedInAt = timestamppb.New(*registration.CheckedInAt)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *eventService) WatchEvents(req *eventsv1.WatchEventsRequest, stream eventsv1.EventService_WatchEventsServer) error {
+	ctx := stream.Context()
+	userId := userID(ctx)
+
+	changes, stop := models.WatchEvents(watchBuffer)
+	defer stop()
+
+	// Tell the client the watch is in place.
+	err := stream.SendHeader(metadata.MD{})
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "Server is shutting down.")
+		case change, ok := <-changes:
+			if !ok {
+				return status.Error(codes.Aborted, "Fell behind on event changes.")
+			}
+
+			msg, err := changeMessage(ctx, userId, change)
+
+			if err != nil {
+				return err
+			}
+
+			if msg == nil {
+				continue
+			}
+
+			err = stream.Send(msg)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// changeMessage returns the change as the user should see it, or nil if
+// the event is hidden from them.
+func changeMessage(ctx context.Context, userId int64, change models.EventChange) (*eventsv1.EventChange, error) {
+	if change.Type == models.EventDeleted {
+		visible, err := change.Event.VisibleTo(ctx, userId, "")
+
+		if err != nil || !visible {
+			return nil, err
+		}
+
+		return &eventsv1.EventChange{Type: eventsv1.EventChange_TYPE_DELETED, EventId: change.EventID}, nil
+	}
+
+	event, err := models.GetVisibleEvent(ctx, change.EventID, userId, "")
+
+	if errors.Is(err, models.ErrEventNotFound) {
+		// Hidden, or deleted since and the deletion follows.
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "Could not fetch event.", err)
+	}
+
+	msg := &eventsv1.EventChange{Type: eventsv1.EventChange_TYPE_UPDATED, EventId: event.ID, Event: eventMessage(*event)}
+
+	if change.Type == models.EventCreated {
+		msg.Type = eventsv1.EventChange_TYPE_CREATED
+	}
+
+	return msg, nil
+}
+
+// loadEventWith fetches the event and checks that the caller has the
+// permission on it.
+func loadEventWith(ctx context.Context, eventId int64, permission models.Permission) (*models.Event, error) {
+	userId, err := requireUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := models.GetEventWith(ctx, eventId, userId, permission)
+
+	if err != nil {
+		return nil, eventError(ctx, err)
+	}
+
+	return event, nil
+}
+
+// eventError maps an error from fetching an event to its gRPC status.
+func eventError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, models.ErrEventNotFound):
+		return errEventNotFound
+	case errors.Is(err, models.ErrNotAllowed):
+		return errNotAllowed
+	default:
+		return internalError(ctx, "Could not fetch event.", err)
+	}
+}
+
+// eventFromInput converts and validates the input.
+func eventFromInput(in *eventsv1.EventInput) (models.Event, error) {
+	if in == nil || in.DateTime == nil || in.DateTime.CheckValid() != nil {
+		return models.Event{}, errInvalidInput
+	}
+
+	event := models.Event{
+		Name:        in.Name,
+		Description: in.Description,
+		Location:    in.Location,
+		DateTime:    in.DateTime.AsTime(),
+		Visibility:  in.Visibility,
+	}
+
+	if in.SetTags || len(in.Tags) > 0 {
+		event.Tags = append([]string{}, in.Tags...)
+	}
+
+	err := event.Validate()
+
+	if errors.Is(err, models.ErrInvalidVisibility) {
+		return event, status.Error(codes.InvalidArgument, "Visibility must be public, unlisted or private.")
+	}
+
+	if err != nil {
+		return event, errInvalidInput
+	}
+
+	return event, nil
+}
+
+func eventMessage(event models.Event) *eventsv1.Event {
+	return &eventsv1.Event{
+		Id:          event.ID,
+		Name:        event.Name,
+		Description: event.Description,
+		Location:    event.Location,
+		DateTime:    timestamppb.New(event.DateTime),
+		UserId:      event.UserID,
+		OrgId:       event.OrgID,
+		Visibility:  event.Visibility,
+		Tags:        event.Tags,
+		UpdatedAt:   timestamppb.New(event.UpdatedAt),
+	}
+}
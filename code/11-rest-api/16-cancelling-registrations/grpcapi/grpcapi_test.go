@@ -0,0 +1,254 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/grpcapi"
+	"example.com/rest-api/grpcapi/eventsv1"
+	"example.com/rest-api/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newClient serves a fresh database over an in-memory connection. Users
+// 1 (owner@example.com) and 2 (guest@example.com) exist.
+func newClient(t *testing.T) eventsv1.EventServiceClient {
+	t.Helper()
+
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+
+	_, err := db.DB.Exec("INSERT INTO users(email, password) VALUES ('owner@example.com', 'x'), ('guest@example.com', 'x')")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- grpcapi.NewServer().Serve(ctx, ln)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+
+	return eventsv1.NewEventServiceClient(conn)
+}
+
+// as returns a context authenticating calls as the user.
+func as(t *testing.T, email string, userId int64) context.Context {
+	t.Helper()
+
+	token, err := utils.GenerateToken(email, userId)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", token)
+}
+
+func input(name, visibility string) *eventsv1.EventInput {
+	return &eventsv1.EventInput{
+		Name:        name,
+		Description: "A meetup",
+		Location:    "Berlin",
+		DateTime:    timestamppb.New(time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC)),
+		Visibility:  visibility,
+	}
+}
+
+func wantCode(t *testing.T, what string, err error, code codes.Code) {
+	t.Helper()
+
+	if status.Code(err) != code {
+		t.Errorf("%s: got %v, want %s", what, err, code)
+	}
+}
+
+func TestEventService(t *testing.T) {
+	client := newClient(t)
+	owner := as(t, "owner@example.com", 1)
+	guest := as(t, "guest@example.com", 2)
+
+	_, err := client.CreateEvent(context.Background(), &eventsv1.CreateEventRequest{Event: input("Meetup", "")})
+	wantCode(t, "anonymous CreateEvent", err, codes.Unauthenticated)
+
+	invalid := metadata.AppendToOutgoingContext(context.Background(), "authorization", "not-a-token")
+	_, err = client.ListEvents(invalid, &eventsv1.ListEventsRequest{})
+	wantCode(t, "ListEvents with an invalid token", err, codes.Unauthenticated)
+
+	_, err = client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: &eventsv1.EventInput{Name: "Meetup"}})
+	wantCode(t, "CreateEvent without details", err, codes.InvalidArgument)
+
+	event, err := client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: input("Meetup", "")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Id != 1 || event.UserId != 1 || event.Visibility != "public" {
+		t.Errorf("CreateEvent: got %v", event)
+	}
+
+	list, err := client.ListEvents(context.Background(), &eventsv1.ListEventsRequest{})
+
+	if err != nil || len(list.Events) != 1 || list.Events[0].Name != "Meetup" {
+		t.Errorf("anonymous ListEvents: got %v, %v", list, err)
+	}
+
+	_, err = client.DeleteEvent(guest, &eventsv1.DeleteEventRequest{Id: 1})
+	wantCode(t, "guest DeleteEvent", err, codes.PermissionDenied)
+
+	_, err = client.RegisterForEvent(guest, &eventsv1.RegisterForEventRequest{EventId: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ListRegistrations(guest, &eventsv1.ListRegistrationsRequest{EventId: 1})
+	wantCode(t, "guest ListRegistrations", err, codes.PermissionDenied)
+
+	registrations, err := client.ListRegistrations(owner, &eventsv1.ListRegistrationsRequest{EventId: 1})
+
+	if err != nil || len(registrations.Registrations) != 1 || registrations.Registrations[0].Email != "guest@example.com" {
+		t.Errorf("owner ListRegistrations: got %v, %v", registrations, err)
+	}
+
+	event, err = client.UpdateEvent(owner, &eventsv1.UpdateEventRequest{Id: 1, Event: input("Renamed", "private")})
+
+	if err != nil || event.Name != "Renamed" || event.Visibility != "private" {
+		t.Errorf("UpdateEvent: got %v, %v", event, err)
+	}
+
+	_, err = client.GetEvent(context.Background(), &eventsv1.GetEventRequest{Id: 1})
+	wantCode(t, "anonymous GetEvent of a private event", err, codes.NotFound)
+
+	_, err = client.CancelRegistration(guest, &eventsv1.CancelRegistrationRequest{EventId: 1})
+
+	if err != nil {
+		t.Errorf("CancelRegistration: %v", err)
+	}
+
+	_, err = client.RegisterForEvent(guest, &eventsv1.RegisterForEventRequest{EventId: 1})
+	wantCode(t, "RegisterForEvent of a hidden private event", err, codes.NotFound)
+
+	_, err = client.CancelRegistration(guest, &eventsv1.CancelRegistrationRequest{EventId: 1})
+	wantCode(t, "CancelRegistration of a hidden private event", err, codes.NotFound)
+
+	_, err = client.CancelRegistration(guest, &eventsv1.CancelRegistrationRequest{EventId: 2})
+	wantCode(t, "CancelRegistration of a missing event", err, codes.NotFound)
+
+	_, err = client.DeleteEvent(owner, &eventsv1.DeleteEventRequest{Id: 1})
+
+	if err != nil {
+		t.Errorf("DeleteEvent: %v", err)
+	}
+
+	_, err = client.GetEvent(owner, &eventsv1.GetEventRequest{Id: 1})
+	wantCode(t, "GetEvent after DeleteEvent", err, codes.NotFound)
+}
+
+func TestWatchEvents(t *testing.T) {
+	client := newClient(t)
+	owner := as(t, "owner@example.com", 1)
+	guest := as(t, "guest@example.com", 2)
+
+	stream, err := client.WatchEvents(guest, &eventsv1.WatchEventsRequest{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The headers arrive once the watch is in place.
+	_, err = stream.Header()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() *eventsv1.EventChange {
+		t.Helper()
+
+		change, err := stream.Recv()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return change
+	}
+
+	// The guest can't see the private event, so its changes are skipped.
+	_, err = client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: input("Secret", "private")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.CreateEvent(owner, &eventsv1.CreateEventRequest{Event: input("Meetup", "")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change := recv(); change.Type != eventsv1.EventChange_TYPE_CREATED || change.EventId != 2 || change.Event.GetName() != "Meetup" {
+		t.Errorf("after create: got %v", change)
+	}
+
+	_, err = client.UpdateEvent(owner, &eventsv1.UpdateEventRequest{Id: 2, Event: input("Renamed", "")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change := recv(); change.Type != eventsv1.EventChange_TYPE_UPDATED || change.Event.GetName() != "Renamed" {
+		t.Errorf("after update: got %v", change)
+	}
+
+	_, err = client.DeleteEvent(owner, &eventsv1.DeleteEventRequest{Id: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.DeleteEvent(owner, &eventsv1.DeleteEventRequest{Id: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change := recv(); change.Type != eventsv1.EventChange_TYPE_DELETED || change.EventId != 2 || change.Event != nil {
+		t.Errorf("after delete: got %v", change)
+	}
+}
@@ -0,0 +1,34 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"example.com/rest-api/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoverPanic turns a panic in a call into an Internal error. gRPC doesn't
+// recover handlers itself, so one bad call would take down the process and
+// the REST API with it.
+func recoverPanic(ctx context.Context, err *error) {
+	if r := recover(); r != nil {
+		logging.FromContext(ctx).Error("Recovered from panic", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		*err = status.Error(codes.Internal, "Internal error.")
+	}
+}
+
+func unaryRecover(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ any, err error) {
+	defer recoverPanic(ctx, &err)
+
+	return handler(ctx, req)
+}
+
+func streamRecover(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverPanic(stream.Context(), &err)
+
+	return handler(srv, stream)
+}
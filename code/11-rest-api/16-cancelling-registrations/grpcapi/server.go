@@ -0,0 +1,65 @@
+// Package grpcapi serves events and registrations over gRPC for internal
+// services, next to the REST routes. The contract is eventsv1/events.proto;
+// the handlers use the same models and permission checks as the REST and
+// GraphQL APIs.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative eventsv1/events.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"example.com/rest-api/grpcapi/eventsv1"
+	"google.golang.org/grpc"
+)
+
+// Server is a gRPC server with the event service registered.
+type Server struct {
+	grpc   *grpc.Server
+	events *eventService
+}
+
+// NewServer returns a Server authenticating calls with the tokens issued
+// by /login and recovering from panics in them. opts are passed on to
+// grpc.NewServer.
+func NewServer(opts ...grpc.ServerOption) *Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(unaryRecover, unaryAuth), grpc.ChainStreamInterceptor(streamRecover, streamAuth))
+
+	s := &Server{
+		grpc:   grpc.NewServer(opts...),
+		events: &eventService{stopping: make(chan struct{})},
+	}
+
+	eventsv1.RegisterEventServiceServer(s.grpc, s.events)
+	return s
+}
+
+// Serve accepts connections on ln until ctx is cancelled. It then ends the
+// running watches and waits for the other calls to finish.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- s.grpc.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Watches never end on their own, so they would hold up GracefulStop.
+	close(s.events.stopping)
+	s.grpc.GracefulStop()
+
+	err := <-serveErr
+
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+
+	return err
+}
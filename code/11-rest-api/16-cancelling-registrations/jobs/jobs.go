@@ -0,0 +1,125 @@
+// Package jobs is a persistent job queue stored in the jobs table and a
+// worker pool that runs the jobs inside the API process.
+//
+// Jobs run at least once: a job whose worker crashed is picked up again
+// once its lock expires, so handlers must tolerate being repeated.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+// Job statuses.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// DefaultMaxAttempts is how often a job is tried unless Job.MaxAttempts
+// says otherwise.
+const DefaultMaxAttempts = 5
+
+// Job is a unit of work for the handler registered for Kind.
+type Job struct {
+	ID   int64
+	Kind string
+	// Payload is passed to the handler as is, usually JSON.
+	Payload []byte
+	// RunAt is the earliest time the job runs. The zero time means now.
+	RunAt time.Time
+	// UniqueKey identifies a job across enqueues. Enqueueing a job with the
+	// key of an existing one reschedules that job instead of adding one.
+	UniqueKey   string
+	Attempts    int
+	MaxAttempts int
+	Status      string
+	LastError   string
+}
+
+// Enqueue stores the job. It joins the transaction in ctx, so a job
+// enqueued together with a model change only exists if the change commits.
+//
+// If a job with the same UniqueKey exists it is replaced and queued again,
+// whatever its status was. A worker still running the old version finishes
+// it, but its result is not recorded.
+func Enqueue(ctx context.Context, job Job) (id int64, err error) {
+	now := time.Now().UTC()
+
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+
+	uniqueKey := sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""}
+
+	err = db.Conn(ctx).QueryRowContext(ctx, `
+	INSERT INTO jobs(kind, payload, unique_key, status, run_at, attempts, max_attempts, created_at, updated_at)
+	VALUES (?, ?, ?, 'queued', ?, 0, ?, ?, ?)
+	ON CONFLICT(unique_key) DO UPDATE SET
+		kind = excluded.kind, payload = excluded.payload, status = 'queued', run_at = excluded.run_at,
+		attempts = 0, max_attempts = excluded.max_attempts, last_error = NULL,
+		lock_token = NULL, locked_at = NULL, updated_at = excluded.updated_at
+	RETURNING id`,
+		job.Kind, job.Payload, uniqueKey, job.RunAt.UTC(), job.MaxAttempts, now, now).Scan(&id)
+
+	return id, err
+}
+
+// EnqueueJSON is Enqueue with payload encoded as JSON.
+func EnqueueJSON(ctx context.Context, kind string, payload any, runAt time.Time, uniqueKey string) (int64, error) {
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return Enqueue(ctx, Job{Kind: kind, Payload: data, RunAt: runAt, UniqueKey: uniqueKey})
+}
+
+// Cancel removes the job with the unique key unless a worker is running
+// it. It is not an error if there is no such job.
+func Cancel(ctx context.Context, uniqueKey string) error {
+	_, err := db.Conn(ctx).ExecContext(ctx, "DELETE FROM jobs WHERE unique_key = ? AND status != 'running'", uniqueKey)
+	return err
+}
+
+// ErrJobNotFound is returned by Get for unknown ids.
+var ErrJobNotFound = errors.New("Job not found")
+
+// Get returns the job with the id.
+func Get(ctx context.Context, id int64) (*Job, error) {
+	return scanJob(db.Conn(ctx).QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+}
+
+// GetByKey returns the job with the unique key.
+func GetByKey(ctx context.Context, uniqueKey string) (*Job, error) {
+	return scanJob(db.Conn(ctx).QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE unique_key = ?", uniqueKey))
+}
+
+const jobColumns = "id, kind, payload, COALESCE(unique_key, ''), status, run_at, attempts, max_attempts, COALESCE(last_error, '')"
+
+func scanJob(row *sql.Row) (*Job, error) {
+	var job Job
+	err := row.Scan(&job.ID, &job.Kind, &job.Payload, &job.UniqueKey, &job.Status, &job.RunAt, &job.Attempts, &job.MaxAttempts, &job.LastError)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,326 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+}
+
+func noBackoff(int) time.Duration { return 0 }
+
+func getJob(t *testing.T, id int64) *Job {
+	t.Helper()
+
+	job, err := Get(context.Background(), id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return job
+}
+
+func TestEnqueueWithUniqueKeyReschedules(t *testing.T) {
+	setupDB(t)
+	ctx := context.Background()
+	first := time.Now().Add(time.Hour).UTC()
+	second := first.Add(time.Hour)
+
+	id, err := Enqueue(ctx, Job{Kind: "test", RunAt: first, UniqueKey: "key"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sameId, err := Enqueue(ctx, Job{Kind: "test", RunAt: second, UniqueKey: "key"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sameId != id {
+		t.Errorf("got job %d, want the existing job %d", sameId, id)
+	}
+
+	if job := getJob(t, id); !job.RunAt.Equal(second) {
+		t.Errorf("got run at %v, want %v", job.RunAt, second)
+	}
+
+	otherId, err := Enqueue(ctx, Job{Kind: "test", RunAt: first})
+
+	if err != nil || otherId == id {
+		t.Errorf("job without key: got id %d, %v, want a new job", otherId, err)
+	}
+
+	err = Cancel(ctx, "key")
+
+	if _, getErr := Get(ctx, id); err != nil || !errors.Is(getErr, ErrJobNotFound) {
+		t.Errorf("after cancel: got %v, %v, want the job gone", err, getErr)
+	}
+}
+
+func TestRunOneWaitsForRunAt(t *testing.T) {
+	setupDB(t)
+	pool := &Pool{}
+	pool.Handle("test", func(ctx context.Context, job Job) error { return nil })
+
+	_, err := Enqueue(context.Background(), Job{Kind: "test", RunAt: time.Now().Add(time.Hour)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := pool.RunOne(context.Background())
+
+	if ran || err != nil {
+		t.Errorf("got %v, %v, want nothing to run", ran, err)
+	}
+}
+
+func TestRunOneRetriesWithBackoff(t *testing.T) {
+	setupDB(t)
+	var calls atomic.Int32
+	pool := &Pool{Backoff: noBackoff}
+	pool.Handle("test", func(ctx context.Context, job Job) error {
+		if calls.Add(1) == 1 {
+			return errors.New("temporary failure")
+		}
+
+		return nil
+	})
+
+	id, err := Enqueue(context.Background(), Job{Kind: "test"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.RunOne(context.Background())
+
+	if job := getJob(t, id); job.Status != StatusQueued || job.Attempts != 1 || job.LastError != "temporary failure" {
+		t.Errorf("after failure: got %+v, want queued after 1 attempt", job)
+	}
+
+	pool.RunOne(context.Background())
+
+	if job := getJob(t, id); job.Status != StatusDone || job.Attempts != 2 {
+		t.Errorf("after retry: got %+v, want done after 2 attempts", job)
+	}
+}
+
+func TestRunOneGivesUpAfterMaxAttempts(t *testing.T) {
+	setupDB(t)
+	pool := &Pool{Backoff: noBackoff}
+	pool.Handle("test", func(ctx context.Context, job Job) error { return errors.New("permanent failure") })
+
+	id, err := Enqueue(context.Background(), Job{Kind: "test", MaxAttempts: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		pool.RunOne(context.Background())
+	}
+
+	if job := getJob(t, id); job.Status != StatusFailed || job.Attempts != 2 {
+		t.Errorf("got %+v, want failed after 2 attempts", job)
+	}
+}
+
+func TestDoneJobsArePruned(t *testing.T) {
+	setupDB(t)
+	ctx := context.Background()
+	pool := &Pool{Retention: time.Hour}
+	pool.Handle("test", func(ctx context.Context, job Job) error { return nil })
+
+	var ids []int64
+
+	for i := 0; i < 3; i++ {
+		id, err := Enqueue(ctx, Job{Kind: "test"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	pool.RunOne(ctx)
+	pool.RunOne(ctx)
+
+	// The first job finished before the retention, the second within it
+	// and the third is still queued.
+	_, err := db.DB.Exec("UPDATE jobs SET updated_at = ? WHERE id IN (?, ?)", time.Now().UTC().Add(-2*time.Hour), ids[0], ids[2])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pool.pruneDone(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get(ctx, ids[0]); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("old done job: got %v, want it pruned", err)
+	}
+
+	for _, id := range ids[1:] {
+		getJob(t, id)
+	}
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	tests := map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 20: time.Hour}
+
+	for attempts, want := range tests {
+		if got := DefaultBackoff(attempts); got != want {
+			t.Errorf("DefaultBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestAbandonedJobsAreRecovered(t *testing.T) {
+	setupDB(t)
+	ctx := context.Background()
+	var ran atomic.Bool
+	pool := &Pool{LockTimeout: time.Minute}
+	pool.Handle("test", func(ctx context.Context, job Job) error {
+		ran.Store(true)
+		return nil
+	})
+
+	id, err := Enqueue(ctx, Job{Kind: "test"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A process claims the job and dies before finishing it.
+	_, _, err = claim(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := pool.RunOne(ctx); ok {
+		t.Fatal("a locked job ran again before its lock expired")
+	}
+
+	_, err = db.DB.Exec("UPDATE jobs SET locked_at = ? WHERE id = ?", time.Now().UTC().Add(-2*time.Minute), id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := pool.RunOne(ctx); ok {
+		t.Fatal("RunOne recovered the job, which is up to Run")
+	}
+
+	err = pool.requeueAbandoned(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.RunOne(ctx)
+
+	if job := getJob(t, id); !ran.Load() || job.Status != StatusDone {
+		t.Errorf("got %+v, want the abandoned job run", job)
+	}
+}
+
+func TestRescheduleWhileRunningKeepsNewSchedule(t *testing.T) {
+	setupDB(t)
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour).UTC()
+	pool := &Pool{}
+	pool.Handle("test", func(ctx context.Context, job Job) error {
+		_, err := Enqueue(ctx, Job{Kind: "test", RunAt: later, UniqueKey: "key"})
+		return err
+	})
+
+	id, err := Enqueue(ctx, Job{Kind: "test", UniqueKey: "key"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.RunOne(ctx)
+
+	if job := getJob(t, id); job.Status != StatusQueued || !job.RunAt.Equal(later) {
+		t.Errorf("got %+v, want queued for %v", job, later)
+	}
+}
+
+func TestPoolRun(t *testing.T) {
+	setupDB(t)
+	done := make(chan int64, 10)
+	pool := &Pool{Workers: 3, PollInterval: 10 * time.Millisecond}
+	pool.Handle("test", func(ctx context.Context, job Job) error {
+		done <- job.ID
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		_, err := Enqueue(context.Background(), Job{Kind: "test"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One of them was left running by a process that died; Run recovers
+	// it when it starts.
+	_, _, err := claim(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.DB.Exec("UPDATE jobs SET locked_at = ? WHERE status = 'running'", time.Now().UTC().Add(-time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+
+	go func() { stopped <- pool.Run(ctx) }()
+
+	seen := map[int64]bool{}
+
+	for len(seen) < 5 {
+		select {
+		case id := <-done:
+			if seen[id] {
+				t.Errorf("job %d ran twice", id)
+			}
+
+			seen[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ran %d of 5 jobs", len(seen))
+		}
+	}
+
+	cancel()
+
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/logging"
+)
+
+// Handler runs a job. Returning an error schedules a retry with backoff
+// until the job runs out of attempts.
+type Handler func(ctx context.Context, job Job) error
+
+// ErrUnknownKind is recorded for jobs without a registered handler.
+var ErrUnknownKind = errors.New("no handler for job kind")
+
+// Pool runs queued jobs with a fixed number of workers. The zero value
+// uses the defaults documented on each field.
+type Pool struct {
+	// Workers is how many jobs run at the same time, 4 by default.
+	Workers int
+	// PollInterval is how often idle workers look for due jobs, every
+	// second by default.
+	PollInterval time.Duration
+	// LockTimeout is how long a job may run. Jobs locked for longer are
+	// considered abandoned by a crashed process and queued again. The
+	// default is 5 minutes.
+	LockTimeout time.Duration
+	// Backoff returns the delay before retrying after the given number of
+	// failed attempts. The default doubles from 10 seconds up to an hour.
+	Backoff func(attempts int) time.Duration
+	// Retention is how long jobs are kept after they are done, 7 days by
+	// default. Failed jobs are kept until someone looks at them.
+	Retention time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// Handle registers the handler for jobs of the kind.
+func (p *Pool) Handle(kind string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.handlers == nil {
+		p.handlers = map[string]Handler{}
+	}
+
+	p.handlers[kind] = handler
+}
+
+func (p *Pool) handler(kind string) (Handler, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	handler, ok := p.handlers[kind]
+	return handler, ok
+}
+
+// DefaultBackoff doubles the delay after every attempt, from 10 seconds up
+// to an hour.
+func DefaultBackoff(attempts int) time.Duration {
+	delay := 10 * time.Second
+
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+
+	return min(delay, time.Hour)
+}
+
+// Run works off due jobs until ctx is cancelled and then waits for the
+// running jobs to finish. Abandoned jobs are queued again and done jobs
+// past their Retention are deleted when it starts and every half
+// LockTimeout after. It is meant to run as a lifecycle worker.
+func (p *Pool) Run(ctx context.Context) error {
+	workers := p.Workers
+
+	if workers <= 0 {
+		workers = 4
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		p.maintain(ctx)
+	}()
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	interval := p.PollInterval
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+		ran, err := p.RunOne(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Could not run job", "error", err)
+		}
+
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// maintain calls requeueAbandoned and pruneDone until ctx is cancelled. It
+// runs apart from the workers so their idle polling stays read-only.
+func (p *Pool) maintain(ctx context.Context) {
+	ticker := time.NewTicker(p.lockTimeout() / 2)
+	defer ticker.Stop()
+
+	for {
+		err := p.requeueAbandoned(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Could not requeue abandoned jobs", "error", err)
+		}
+
+		err = p.pruneDone(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Could not prune done jobs", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOne claims the next due job and runs it. It reports whether there was
+// a job to run. Errors returned by the job's handler are recorded on the
+// job, not returned.
+func (p *Pool) RunOne(ctx context.Context) (bool, error) {
+	job, token, err := claim(ctx)
+
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	handler, ok := p.handler(job.Kind)
+	runErr := ErrUnknownKind
+
+	if ok {
+		runErr = p.run(ctx, handler, *job)
+	}
+
+	// The result is recorded even if ctx was cancelled meanwhile, so a
+	// shutdown doesn't leave the job locked until it times out.
+	return true, p.finish(context.WithoutCancel(ctx), *job, token, runErr)
+}
+
+func (p *Pool) run(ctx context.Context, handler Handler, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, p.lockTimeout())
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+func (p *Pool) lockTimeout() time.Duration {
+	if p.LockTimeout <= 0 {
+		return 5 * time.Minute
+	}
+
+	return p.LockTimeout
+}
+
+// requeueAbandoned queues jobs again whose lock expired because the process
+// running them died.
+func (p *Pool) requeueAbandoned(ctx context.Context) error {
+	_, err := db.Conn(ctx).ExecContext(ctx, `
+	UPDATE jobs SET status = 'queued', lock_token = NULL, locked_at = NULL, updated_at = ?
+	WHERE status = 'running' AND locked_at < ?`,
+		time.Now().UTC(), time.Now().UTC().Add(-p.lockTimeout()))
+
+	return err
+}
+
+// pruneDone deletes the jobs done longer than Retention ago.
+func (p *Pool) pruneDone(ctx context.Context) error {
+	retention := p.Retention
+
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+
+	_, err := db.Conn(ctx).ExecContext(ctx,
+		"DELETE FROM jobs WHERE status = 'done' AND updated_at < ?",
+		time.Now().UTC().Add(-retention))
+
+	return err
+}
+
+// claim locks the next due job. Each claim gets a new lock token, so only
+// the worker holding the current lock can record a result.
+func claim(ctx context.Context) (*Job, string, error) {
+	var token [16]byte
+	_, err := rand.Read(token[:])
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	lockToken := hex.EncodeToString(token[:])
+	now := time.Now().UTC()
+
+	// Looking first keeps idle polling to reads, which don't wait for
+	// SQLite's single write lock.
+	var due bool
+	err = db.Conn(ctx).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM jobs WHERE status = 'queued' AND run_at <= ?)", now).Scan(&due)
+
+	if err != nil || !due {
+		return nil, "", err
+	}
+
+	var job Job
+	err = db.Conn(ctx).QueryRowContext(ctx, `
+	UPDATE jobs SET status = 'running', attempts = attempts + 1, lock_token = ?, locked_at = ?, updated_at = ?
+	WHERE id = (SELECT id FROM jobs WHERE status = 'queued' AND run_at <= ? ORDER BY run_at, id LIMIT 1)
+	RETURNING id, kind, payload, COALESCE(unique_key, ''), status, run_at, attempts, max_attempts`,
+		lockToken, now, now, now).
+		Scan(&job.ID, &job.Kind, &job.Payload, &job.UniqueKey, &job.Status, &job.RunAt, &job.Attempts, &job.MaxAttempts)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", nil
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &job, lockToken, nil
+}
+
+func (p *Pool) finish(ctx context.Context, job Job, token string, runErr error) error {
+	now := time.Now().UTC()
+
+	if runErr == nil {
+		_, err := db.Conn(ctx).ExecContext(ctx,
+			"UPDATE jobs SET status = 'done', lock_token = NULL, locked_at = NULL, last_error = NULL, updated_at = ? WHERE id = ? AND lock_token = ?",
+			now, job.ID, token)
+		return err
+	}
+
+	status, runAt := StatusQueued, now.Add(p.backoff(job.Attempts))
+
+	if job.Attempts >= job.MaxAttempts || errors.Is(runErr, ErrUnknownKind) {
+		status, runAt = StatusFailed, job.RunAt
+	}
+
+	logging.FromContext(ctx).Warn("Job failed", "job", job.ID, "kind", job.Kind, "attempt", job.Attempts, "status", status, "error", runErr)
+
+	_, err := db.Conn(ctx).ExecContext(ctx,
+		"UPDATE jobs SET status = ?, run_at = ?, lock_token = NULL, locked_at = NULL, last_error = ?, updated_at = ? WHERE id = ? AND lock_token = ?",
+		status, runAt, runErr.Error(), now, job.ID, token)
+	return err
+}
+
+func (p *Pool) backoff(attempts int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempts)
+	}
+
+	return DefaultBackoff(attempts)
+}
@@ -0,0 +1,113 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// draining is set while Serve shuts the server down.
+var draining atomic.Bool
+
+// Draining reports whether Serve is shutting the server down. Readiness
+// checks fail from then on, so load balancers stop sending new requests.
+func Draining() bool {
+	return draining.Load()
+}
+
+// Workers runs background workers next to the HTTP server. Workers are
+// stopped in the reverse order they were started, so a worker may depend on
+// anything started before it.
+type Workers struct {
+	mu      sync.Mutex
+	running []*worker
+}
+
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Go starts run in its own goroutine. The context passed to run is cancelled
+// when the worker is asked to stop; run should return promptly after that.
+func (w *Workers) Go(name string, run func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wk := &worker{name: name, cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(wk.done)
+		wk.err = run(ctx)
+	}()
+
+	w.mu.Lock()
+	w.running = append(w.running, wk)
+	w.mu.Unlock()
+}
+
+// Shutdown stops the workers one at a time, newest first, and waits for each
+// to return before stopping the next. It gives up once ctx is done.
+func (w *Workers) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	running := w.running
+	w.running = nil
+	w.mu.Unlock()
+
+	var errs []error
+
+	for i := len(running) - 1; i >= 0; i-- {
+		wk := running[i]
+		wk.cancel()
+
+		select {
+		case <-wk.done:
+			if wk.err != nil && !errors.Is(wk.err, context.Canceled) {
+				errs = append(errs, fmt.Errorf("worker %s: %w", wk.name, wk.err))
+			}
+		case <-ctx.Done():
+			return errors.Join(append(errs, fmt.Errorf("worker %s: %w", wk.name, ctx.Err()))...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Serve runs srv on ln until ctx is cancelled, typically by SIGTERM. It then
+// marks the server as Draining, stops accepting connections, waits up to
+// drainTimeout for in-flight requests to finish and stops the workers
+// within the same deadline. The workers are stopped however Serve returns.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, drainTimeout time.Duration, workers *Workers) error {
+	draining.Store(false)
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	var err error
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	}
+
+	draining.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err == nil {
+		err = srv.Shutdown(shutdownCtx)
+
+		if err != nil {
+			err = fmt.Errorf("draining HTTP server: %w", err)
+		}
+	}
+
+	return errors.Join(err, workers.Shutdown(shutdownCtx))
+}
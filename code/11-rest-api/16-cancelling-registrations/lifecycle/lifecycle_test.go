@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- Serve(ctx, srv, ln, 5*time.Second, &Workers{})
+	}()
+
+	responses := make(chan string, 1)
+
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned before the request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !Draining() {
+		t.Error("not draining after the context was cancelled")
+	}
+
+	close(release)
+
+	if body := <-responses; body != "done" {
+		t.Errorf("in-flight request got %q, want %q", body, "done")
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+func TestWorkersShutdownInReverseOrder(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+
+	var workers Workers
+
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		workers.Go(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return ctx.Err()
+		})
+	}
+
+	err := workers.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"third", "second", "first"}
+	if !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped %v, want %v", stopped, want)
+	}
+}
+
+func TestWorkersShutdownTimeout(t *testing.T) {
+	var workers Workers
+
+	workers.Go("stuck", func(ctx context.Context) error {
+		select {}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := workers.Shutdown(ctx)
+	if err == nil {
+		t.Fatal("expected an error for a worker that doesn't stop")
+	}
+}
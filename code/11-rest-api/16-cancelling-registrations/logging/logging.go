@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// Setup makes a JSON (or, for format "text", a human readable) slog logger
+// writing to w the default logger.
+func Setup(w io.Writer, format string) {
+	var handler slog.Handler
+
+	if format == "text" {
+		handler = slog.NewTextHandler(w, nil)
+	} else {
+		handler = slog.NewJSONHandler(w, nil)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the default logger annotated with the request and
+// trace IDs found in ctx, so log lines can be matched to a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+
+	if requestID := RequestID(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With("trace_id", spanContext.TraceID().String())
+	}
+
+	return logger
+}
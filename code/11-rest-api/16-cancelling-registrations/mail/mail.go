@@ -0,0 +1,80 @@
+// Package mail sends the emails the API needs, such as invitations.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"example.com/rest-api/logging"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer doesn't deliver anything but logs the recipient and subject of
+// every message. Bodies are left out: they carry invitation tokens, which
+// must not end up in the logs.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).Info("Email not sent, no mailer configured",
+		slog.String("to", msg.To), slog.String("subject", msg.Subject))
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server.
+type SMTPMailer struct {
+	// Addr is the host:port of the server.
+	Addr string
+	From string
+	// Auth may be nil for servers that don't require it.
+	Auth smtp.Auth
+}
+
+func (m SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("mail: header contains a line break")
+	}
+
+	data := "From: " + m.From + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + msg.Body
+
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, []byte(data))
+}
+
+// Outbox keeps every message in memory. It is meant for tests.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
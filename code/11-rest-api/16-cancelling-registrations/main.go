@@ -1,16 +1,286 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
 	"example.com/rest-api/db"
+	"example.com/rest-api/geo"
+	"example.com/rest-api/grpcapi"
+	"example.com/rest-api/jobs"
+	"example.com/rest-api/lifecycle"
+	"example.com/rest-api/logging"
+	"example.com/rest-api/mail"
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/middlewares"
+	"example.com/rest-api/models"
+	"example.com/rest-api/notify"
+	"example.com/rest-api/payments"
 	"example.com/rest-api/routes"
+	"example.com/rest-api/sso"
+	"example.com/rest-api/tracing"
+	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
+// drainTimeout bounds how long a shutdown waits for in-flight requests and
+// background workers.
+const drainTimeout = 15 * time.Second
+
 func main() {
-	db.InitDB()
-	server := gin.Default()
+	err := newRootCommand().Execute()
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// configure applies the settings from the environment that every command
+// shares.
+func configure() error {
+	logging.Setup(os.Stderr, os.Getenv("LOG_FORMAT"))
+
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+
+		if err != nil {
+			return fmt.Errorf("could not parse DB_QUERY_TIMEOUT: %w", err)
+		}
+
+		db.DefaultQueryTimeout = timeout
+	}
+
+	// Session cookies are Secure unless turned off for development over
+	// plain HTTP.
+	if value := os.Getenv("SESSION_COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return fmt.Errorf("could not parse SESSION_COOKIE_SECURE: %w", err)
+		}
+
+		middlewares.SetSecureCookies(secure)
+	}
+
+	if spec := os.Getenv("PASSWORD_HASHER"); spec != "" {
+		hasher, err := utils.ParsePasswordHasher(spec)
+
+		if err != nil {
+			return fmt.Errorf("could not configure password hashing: %w", err)
+		}
+
+		utils.SetPasswordHasher(hasher)
+	}
+
+	return nil
+}
+
+// serve runs the API on the database at dbPath until it is interrupted.
+// Errors are returned rather than exiting, so the database is closed on
+// every path.
+func serve(dbPath string) error {
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("TRACE_EXPORTER"))
+
+	if err != nil {
+		return fmt.Errorf("could not set up tracing: %w", err)
+	}
+
+	// Invite links and check-in codes are signed with SIGNING_KEY, so they
+	// could be forged with a key everyone knows.
+	err = utils.SetSigningKey(os.Getenv("SIGNING_KEY"))
+
+	if err != nil {
+		return fmt.Errorf("could not set up signing, set SIGNING_KEY to a random secret: %w", err)
+	}
+
+	if value := os.Getenv("EVENT_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size < 0 {
+			return fmt.Errorf("could not parse EVENT_CACHE_SIZE %q, set it to a number of events", value)
+		}
+
+		models.EnableEventCache(size)
+	}
+
+	if path := os.Getenv("GEOCODER_FILE"); path != "" {
+		geocoder, err := geo.LoadStatic(path)
+
+		if err != nil {
+			return fmt.Errorf("could not load geocoder table: %w", err)
+		}
+
+		routes.SetGeocoder(geocoder)
+	}
+
+	var mailer mail.Mailer = mail.LogMailer{}
+
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		mailer = mail.SMTPMailer{Addr: addr, From: os.Getenv("SMTP_FROM")}
+	}
+
+	routes.SetMailer(mailer)
+
+	if path := os.Getenv("SSO_CONFIG_FILE"); path != "" {
+		configs, err := sso.LoadConfig(path)
+
+		if err != nil {
+			return fmt.Errorf("could not load identity providers: %w", err)
+		}
+
+		var providers []*sso.Provider
+
+		for _, config := range configs {
+			provider, err := sso.NewProvider(context.Background(), config)
+
+			if err != nil {
+				return fmt.Errorf("could not set up identity provider %s: %w", config.Name, err)
+			}
+
+			providers = append(providers, provider)
+		}
+
+		routes.SetSSOProviders(providers)
+	}
+
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		cors := middlewares.DefaultCORSConfig
+		cors.AllowedOrigins = strings.Split(strings.ReplaceAll(origins, " ", ""), ",")
+		cors.AllowCredentials = os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"
+		routes.SetCORS(cors)
+	}
+
+	db.InitDB(dbPath)
+	defer db.DB.Close()
+
+	server := gin.New()
+	server.Use(gin.Recovery())
+
+	err = routes.RegisterRoutes(server)
+
+	if err != nil {
+		return fmt.Errorf("could not register routes: %w", err)
+	}
+
+	if os.Getenv("PAYMENT_PROVIDER") == "fake" {
+		// The fake provider's checkout pages are served by this server, so
+		// it can be tried out without an account anywhere.
+		provider := &payments.FakeProvider{
+			Secret:      os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+			WebhookURL:  "http://localhost:8080/payments/webhook",
+			CheckoutURL: "http://localhost:8080/fake-checkout",
+		}
+		models.SetPaymentProvider(provider)
+		server.Any("/fake-checkout/*path", gin.WrapH(http.StripPrefix("/fake-checkout", provider.Handler())))
+	}
+
+	httpServer := &http.Server{
+		Addr:    ":8080", // localhost:8080
+		Handler: server,
+	}
+
+	// Both listeners are bound before any worker starts, so failing to
+	// bind leaves nothing running. From here on Serve stops the workers.
+	httpListener, err := net.Listen("tcp", httpServer.Addr)
+
+	if err != nil {
+		return fmt.Errorf("could not listen for HTTP: %w", err)
+	}
+
+	// Metrics are kept off the public server, on an address only the
+	// scraper should reach.
+	metricsAddr := os.Getenv("METRICS_ADDR")
+
+	if metricsAddr == "" {
+		metricsAddr = "127.0.0.1:9090"
+	}
+
+	metricsListener, err := net.Listen("tcp", metricsAddr)
+
+	if err != nil {
+		httpListener.Close()
+		return fmt.Errorf("could not listen for metrics: %w", err)
+	}
+
+	var grpcListener net.Listener
+
+	if addr := os.Getenv("GRPC_ADDR"); addr != "" {
+		grpcListener, err = net.Listen("tcp", addr)
+
+		if err != nil {
+			httpListener.Close()
+			metricsListener.Close()
+			return fmt.Errorf("could not listen for gRPC: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers lifecycle.Workers
+	workers.Go("metrics", func(ctx context.Context) error {
+		return serveMetrics(ctx, metricsListener)
+	})
+
+	workers.Go("ticket holds", func(ctx context.Context) error {
+		return models.RunHoldExpiry(ctx, time.Minute)
+	})
+
+	pool := &jobs.Pool{}
+	pool.Handle(models.ReminderJob, models.ReminderHandler(notify.EmailNotifier{Mailer: mailer}))
+	workers.Go("jobs", pool.Run)
+
+	if grpcListener != nil {
+		grpcServer := grpcapi.NewServer()
+		workers.Go("grpc", func(ctx context.Context) error {
+			return grpcServer.Serve(ctx, grpcListener)
+		})
+	}
+
+	serveErr := lifecycle.Serve(ctx, httpServer, httpListener, drainTimeout, &workers)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err = shutdownTracing(flushCtx)
+
+	if err != nil {
+		slog.Error("Could not flush traces", "error", err)
+	}
+
+	if serveErr != nil {
+		return fmt.Errorf("server stopped with error: %w", serveErr)
+	}
+
+	return nil
+}
+
+// serveMetrics serves /metrics on ln until ctx is cancelled.
+func serveMetrics(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	err := srv.Serve(ln)
 
-	routes.RegisterRoutes(server)
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
 
-	server.Run(":8080") // localhost:8080
+	return err
 }
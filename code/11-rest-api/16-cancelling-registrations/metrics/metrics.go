@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric exposed on /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	passwordHashDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "password_hash_duration_seconds",
+		Help:    "Time spent hashing and comparing passwords.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2, 4},
+	}, []string{"operation"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by result.",
+	}, []string{"result"})
+
+	signups = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "user_signups_total",
+		Help: "Users created through /signup.",
+	})
+
+	eventRegistrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "event_registrations_total",
+		Help: "Event registrations by action (register, cancel).",
+	}, []string{"action"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		passwordHashDuration,
+		logins,
+		signups,
+		eventRegistrations,
+	)
+}
+
+var (
+	dbMu        sync.Mutex
+	dbCollector prometheus.Collector
+)
+
+// RegisterDB exposes the connection pool stats of database. Registering a
+// new database replaces the previous one.
+func RegisterDB(database *sql.DB) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if dbCollector != nil {
+		Registry.Unregister(dbCollector)
+	}
+
+	dbCollector = collectors.NewDBStatsCollector(database, "api")
+	Registry.MustRegister(dbCollector)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records request counts and latency. Routes are labelled with
+// their pattern (e.g. /events/:id) so label cardinality stays bounded.
+func Middleware(context *gin.Context) {
+	start := time.Now()
+
+	context.Next()
+
+	route := context.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	method := context.Request.Method
+	status := strconv.Itoa(context.Writer.Status())
+
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+}
+
+// ObservePasswordHash records how long a password operation ("hash" or
+// "compare") took since start.
+func ObservePasswordHash(operation string, start time.Time) {
+	passwordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// LoginSucceeded counts a successful login.
+func LoginSucceeded() {
+	logins.WithLabelValues("success").Inc()
+}
+
+// LoginFailed counts a rejected login.
+func LoginFailed() {
+	logins.WithLabelValues("failure").Inc()
+}
+
+// UserSignedUp counts a new user.
+func UserSignedUp() {
+	signups.Inc()
+}
+
+// EventRegistered counts a registration for an event.
+func EventRegistered() {
+	eventRegistrations.WithLabelValues("register").Inc()
+}
+
+// EventRegistrationCancelled counts a cancelled registration.
+func EventRegistrationCancelled() {
+	eventRegistrations.WithLabelValues("cancel").Inc()
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin only lets admins through. It must run after Authenticate.
+// The role is read from the database on every request, so demoting a user
+// takes effect right away.
+func RequireAdmin(context *gin.Context) {
+	user, err := models.GetUserByID(context.Request.Context(), context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrUserNotFound) {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized."})
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(context.Request.Context()).Error("Could not look up user role", "error", err)
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not check permissions."})
+		return
+	}
+
+	if user.Role != models.RoleAdmin {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Not allowed."})
+		return
+	}
+
+	context.Next()
+}
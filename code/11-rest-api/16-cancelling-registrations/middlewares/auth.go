@@ -1,27 +1,116 @@
 package middlewares
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
 	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
+var errInvalidToken = errors.New("Invalid token")
+
+// identify sets userId for the token. Login tokens come from /login; API
+// tokens are created by users and also set apiToken, which RequireScope
+// checks. Rejected tokens return an error wrapping errInvalidToken.
+func identify(context *gin.Context, token string) error {
+	if models.IsAPIToken(token) {
+		apiToken, err := models.AuthenticateAPIToken(context.Request.Context(), token)
+
+		if errors.Is(err, models.ErrInvalidAPIToken) {
+			return fmt.Errorf("%w: %w", errInvalidToken, err)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		context.Set("userId", apiToken.UserID)
+		context.Set("apiToken", apiToken)
+		return nil
+	}
+
+	userId, issuedAt, err := utils.VerifyToken(token)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidToken, err)
+	}
+
+	// Login tokens can't be revoked one by one, so disabled users and
+	// revoked sessions have to be checked here.
+	err = models.CheckSession(context.Request.Context(), userId, issuedAt)
+
+	if errors.Is(err, models.ErrUserDisabled) || errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrSessionRevoked) {
+		return fmt.Errorf("%w: %w", errInvalidToken, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	context.Set("userId", userId)
+	return nil
+}
+
+// Authenticate identifies the user by the Authorization header or, for
+// browser clients, the session cookie. Cookie requests that change
+// anything must also pass the CSRF check.
 func Authenticate(context *gin.Context) {
-	token := context.Request.Header.Get("Authorization")
+	token, fromCookie := requestToken(context)
 
 	if token == "" {
 		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized."})
 		return
 	}
 
-	userId, err := utils.VerifyToken(token)
+	if fromCookie && !validCSRF(context) {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Missing or invalid CSRF token."})
+		return
+	}
 
-	if err != nil {
+	err := identify(context, token)
+
+	if errors.Is(err, errInvalidToken) {
+		logging.FromContext(context.Request.Context()).Info("Rejected token", "error", err)
 		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not authorized."})
 		return
 	}
 
-	context.Set("userId", userId)
+	if err != nil {
+		logging.FromContext(context.Request.Context()).Error("Could not check token", "error", err)
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user."})
+		return
+	}
+
+	context.Next()
+}
+
+// OptionalAuthenticate identifies the user like Authenticate when a valid
+// token is sent, but lets anonymous requests through. A missing or invalid
+// token leaves userId unset, and so does a session cookie failing the CSRF
+// check.
+func OptionalAuthenticate(context *gin.Context) {
+	token, fromCookie := requestToken(context)
+
+	if token == "" {
+		context.Next()
+		return
+	}
+
+	if fromCookie && !validCSRF(context) {
+		logging.FromContext(context.Request.Context()).Info("Ignored session cookie without a CSRF token")
+		context.Next()
+		return
+	}
+
+	err := identify(context, token)
+
+	if err != nil {
+		logging.FromContext(context.Request.Context()).Info("Ignored invalid token", "error", err)
+	}
+
 	context.Next()
 }
@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig says which other origins may call the API from a browser.
+type CORSConfig struct {
+	// AllowedOrigins are origins like "https://app.example.com". "*"
+	// allows any origin, but then browsers won't send credentials.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies, which the session mode
+	// needs.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig allows what the API uses once AllowedOrigins is set.
+// Without origins, cross-origin requests stay blocked.
+var DefaultCORSConfig = CORSConfig{
+	AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+	AllowedHeaders: []string{"Authorization", "Content-Type", CSRFHeader, RequestIDHeader},
+	ExposedHeaders: []string{RequestIDHeader},
+	MaxAge:         10 * time.Minute,
+}
+
+// CORS adds the CORS headers for allowed origins and answers preflight
+// requests. Requests from other origins get no CORS headers, so browsers
+// don't let the calling page see the response.
+func CORS(config CORSConfig) gin.HandlerFunc {
+	anyOrigin := slices.Contains(config.AllowedOrigins, "*")
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(context *gin.Context) {
+		origin := context.GetHeader("Origin")
+		preflight := context.Request.Method == http.MethodOptions && context.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			context.Next()
+			return
+		}
+
+		context.Writer.Header().Add("Vary", "Origin")
+
+		if !anyOrigin && !slices.Contains(config.AllowedOrigins, origin) {
+			if preflight {
+				context.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			context.Next()
+			return
+		}
+
+		if anyOrigin {
+			context.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			context.Header("Access-Control-Allow-Origin", origin)
+
+			if config.AllowCredentials {
+				context.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if !preflight {
+			if exposed != "" {
+				context.Header("Access-Control-Expose-Headers", exposed)
+			}
+
+			context.Next()
+			return
+		}
+
+		if !slices.Contains(config.AllowedMethods, context.GetHeader("Access-Control-Request-Method")) {
+			context.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		context.Header("Access-Control-Allow-Methods", methods)
+		context.Header("Access-Control-Allow-Headers", headers)
+		context.Header("Access-Control-Max-Age", maxAge)
+		context.AbortWithStatus(http.StatusNoContent)
+	}
+}
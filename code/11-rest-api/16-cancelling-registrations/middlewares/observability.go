@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"example.com/rest-api/logging"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Client supplied request IDs are echoed into logs, so only accept harmless
+// ones and generate a fresh ID otherwise.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+var tracer = otel.Tracer("example.com/rest-api/middlewares")
+
+// RequestID reuses the caller's X-Request-ID or generates one, returns it in
+// the response and stores it in the request context for logging.
+func RequestID(context *gin.Context) {
+	requestId := context.GetHeader(RequestIDHeader)
+
+	if !validRequestID.MatchString(requestId) {
+		requestId = newRequestID()
+	}
+
+	context.Set("requestId", requestId)
+	context.Header(RequestIDHeader, requestId)
+	context.Request = context.Request.WithContext(logging.WithRequestID(context.Request.Context(), requestId))
+
+	context.Next()
+}
+
+// Trace wraps the request in a server span, continuing any trace propagated
+// by the caller.
+func Trace(context *gin.Context) {
+	route := context.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Request.Context(), propagation.HeaderCarrier(context.Request.Header))
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", context.Request.Method, route),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(context.Request.Method),
+			semconv.HTTPRoute(route),
+		),
+	)
+	defer span.End()
+
+	context.Request = context.Request.WithContext(ctx)
+
+	context.Next()
+
+	status := context.Writer.Status()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+}
+
+// AccessLog writes one structured log line per request.
+func AccessLog(context *gin.Context) {
+	start := time.Now()
+
+	context.Next()
+
+	status := context.Writer.Status()
+	level := slog.LevelInfo
+
+	if status >= 500 {
+		level = slog.LevelError
+	}
+
+	logging.FromContext(context.Request.Context()).Log(context.Request.Context(), level, "request",
+		"method", context.Request.Method,
+		"path", context.Request.URL.Path,
+		"route", context.FullPath(),
+		"status", status,
+		"duration", time.Since(start),
+		"client_ip", context.ClientIP(),
+	)
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	_, err := rand.Read(bytes)
+
+	if err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/rest-api/logging"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	server := gin.New()
+	server.Use(RequestID)
+	server.GET("/", func(context *gin.Context) {
+		seen = logging.RequestID(context.Request.Context())
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "generated", incoming: "", keep: false},
+		{name: "propagated", incoming: "abc-123", keep: true},
+		{name: "unsafe", incoming: "abc\n123", keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+
+			if got == "" {
+				t.Fatal("response has no request ID")
+			}
+
+			if got != seen {
+				t.Errorf("response header %q doesn't match context %q", got, seen)
+			}
+
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("got request ID %q for incoming %q", got, tt.incoming)
+			}
+		})
+	}
+}
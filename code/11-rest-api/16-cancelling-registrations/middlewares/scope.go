@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APIToken returns the API token the request was authenticated with, or nil
+// for password logins and anonymous requests.
+func APIToken(context *gin.Context) *models.APIToken {
+	token, _ := context.Get("apiToken")
+	apiToken, _ := token.(*models.APIToken)
+	return apiToken
+}
+
+// RequireScope rejects requests made with an API token that wasn't given
+// the scope. Password logins and anonymous requests pass, so it must run
+// after Authenticate or OptionalAuthenticate.
+func RequireScope(scope models.Scope) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		token := APIToken(context)
+
+		if token != nil && !token.HasScope(scope) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": fmt.Sprintf("Token lacks the %s scope.", scope)})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// RequireLogin rejects requests made with an API token, so a leaked token
+// can't be used to create or revoke tokens. It must run after Authenticate.
+func RequireLogin(context *gin.Context) {
+	if APIToken(context) != nil {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Not allowed with an API token."})
+		return
+	}
+
+	context.Next()
+}
@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := DefaultCORSConfig
+	config.AllowedOrigins = []string{"https://app.example.com"}
+	config.AllowCredentials = true
+
+	server := gin.New()
+	server.Use(CORS(config))
+	server.GET("/events", func(context *gin.Context) { context.Status(http.StatusOK) })
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		wantStatus  int
+		wantAllowed bool
+	}{
+		{name: "same origin", method: http.MethodGet, origin: "", wantStatus: http.StatusOK},
+		{name: "allowed", method: http.MethodGet, origin: "https://app.example.com", wantStatus: http.StatusOK, wantAllowed: true},
+		{name: "other origin", method: http.MethodGet, origin: "https://evil.example", wantStatus: http.StatusOK},
+		{name: "preflight", method: http.MethodOptions, origin: "https://app.example.com", wantStatus: http.StatusNoContent, wantAllowed: true},
+		{name: "preflight from other origin", method: http.MethodOptions, origin: "https://evil.example", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/events", nil)
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			allowed := rec.Header().Get("Access-Control-Allow-Origin")
+
+			if tt.wantAllowed != (allowed != "") || (tt.wantAllowed && rec.Header().Get("Access-Control-Allow-Credentials") != "true") {
+				t.Errorf("got Access-Control-Allow-Origin %q, want allowed: %v", allowed, tt.wantAllowed)
+			}
+
+			if tt.method == http.MethodOptions && tt.wantAllowed && rec.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("got Access-Control-Max-Age %q, want 600", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := gin.New()
+	server.Use(SecurityHeaders(SecurityHeadersConfig{HSTSMaxAge: time.Hour, FrameOptions: "DENY"}))
+	server.GET("/", func(context *gin.Context) { context.Status(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=3600",
+		"X-Frame-Options":           "DENY",
+		"X-Content-Type-Options":    "nosniff",
+		"Content-Security-Policy":   "",
+	}
+
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("got %s %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := gin.New()
+	server.Use(LimitBody(8))
+
+	read := func(context *gin.Context) {
+		_, err := io.ReadAll(context.Request.Body)
+
+		if err != nil {
+			context.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		context.Status(http.StatusOK)
+	}
+	server.POST("/small", read)
+	server.POST("/large", LimitBody(32), read)
+
+	tests := []struct {
+		path       string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{path: "/small", body: "12345678", wantStatus: http.StatusOK},
+		{path: "/small", body: "123456789", wantStatus: http.StatusRequestEntityTooLarge},
+		{path: "/small", body: "123456789", chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{path: "/large", body: strings.Repeat("x", 32), wantStatus: http.StatusOK},
+		{path: "/large", body: strings.Repeat("x", 32), chunked: true, wantStatus: http.StatusOK},
+		{path: "/large", body: strings.Repeat("x", 33), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s with %d bytes (chunked: %v): got status %d, want %d", tt.path, len(tt.body), tt.chunked, rec.Code, tt.wantStatus)
+		}
+	}
+}
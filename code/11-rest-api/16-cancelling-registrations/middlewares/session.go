@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SessionCookie holds the login token for browser clients. It is
+	// HttpOnly, so scripts on the page can't steal it.
+	SessionCookie = "session"
+	// CSRFCookie holds the CSRF token. Scripts on the page read it and send
+	// it back in CSRFHeader, which other sites can't do.
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// secureCookies marks the session cookies Secure, so browsers only send
+// them over HTTPS. It can't be read off the request, since behind a proxy
+// terminating TLS every request arrives over plain HTTP.
+var secureCookies = true
+
+// SetSecureCookies sets whether the session cookies are marked Secure. It
+// is on by default; turn it off only for development over plain HTTP.
+func SetSecureCookies(secure bool) {
+	secureCookies = secure
+}
+
+// SecureCookies reports whether cookies should be marked Secure.
+func SecureCookies() bool {
+	return secureCookies
+}
+
+// StartSession stores token in the session cookie and returns the CSRF
+// token that requests changing anything must send in CSRFHeader.
+func StartSession(context *gin.Context, token string) (string, error) {
+	csrfToken, _, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := int(utils.TokenTTL.Seconds())
+
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(SessionCookie, token, maxAge, "/", "", secureCookies, true)
+	context.SetCookie(CSRFCookie, csrfToken, maxAge, "/", "", secureCookies, false)
+
+	return csrfToken, nil
+}
+
+// EndSession removes the session cookies.
+func EndSession(context *gin.Context) {
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(SessionCookie, "", -1, "/", "", secureCookies, true)
+	context.SetCookie(CSRFCookie, "", -1, "/", "", secureCookies, false)
+}
+
+// requestToken returns the token the request authenticates with. The
+// Authorization header, with or without the Bearer scheme, wins over the
+// session cookie; fromCookie tells which one it was.
+func requestToken(context *gin.Context) (token string, fromCookie bool) {
+	header := context.Request.Header.Get("Authorization")
+
+	if header != "" {
+		scheme, credentials, found := strings.Cut(header, " ")
+
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(credentials), false
+		}
+
+		return header, false
+	}
+
+	cookie, err := context.Cookie(SessionCookie)
+
+	if err != nil {
+		return "", false
+	}
+
+	return cookie, true
+}
+
+// validCSRF reports whether a cookie-authenticated request may go ahead.
+// Safe methods always may; the others must echo the CSRF cookie in
+// CSRFHeader, which proves the request came from a page on our origin.
+func validCSRF(context *gin.Context) bool {
+	switch context.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := context.Cookie(CSRFCookie)
+	header := context.GetHeader(CSRFHeader)
+
+	return err == nil && cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
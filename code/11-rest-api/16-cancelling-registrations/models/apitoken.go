@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+// Scope limits what an API token may be used for. Password logins are not
+// limited by scopes.
+type Scope string
+
+const (
+	ScopeEventsRead         Scope = "events:read"
+	ScopeEventsWrite        Scope = "events:write"
+	ScopeRegistrationsRead  Scope = "registrations:read"
+	ScopeRegistrationsWrite Scope = "registrations:write"
+	ScopeOrgsRead           Scope = "orgs:read"
+	ScopeOrgsWrite          Scope = "orgs:write"
+	// ScopeTagsWrite is only useful to admins.
+	ScopeTagsWrite Scope = "tags:write"
+)
+
+// Scopes lists every scope a token can be given.
+var Scopes = []Scope{
+	ScopeEventsRead, ScopeEventsWrite,
+	ScopeRegistrationsRead, ScopeRegistrationsWrite,
+	ScopeOrgsRead, ScopeOrgsWrite,
+	ScopeTagsWrite,
+}
+
+func (s Scope) valid() bool {
+	for _, scope := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// APITokenPrefix starts every API token, which tells them apart from login
+// tokens and makes leaked ones easy to find.
+const APITokenPrefix = "pat_"
+
+// apiTokenTouchInterval is how stale LastUsedAt may get, so a busy script
+// doesn't write to the database on every request.
+const apiTokenTouchInterval = time.Minute
+
+// APIToken is a long-lived, named token with limited scopes that a user
+// creates for scripts and integrations. Only a hash of the token is stored.
+type APIToken struct {
+	ID     int64
+	UserID int64
+	Name   string
+	Scopes []Scope
+	// ExpiresAt is optional; tokens without it work until revoked.
+	ExpiresAt  *time.Time
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+var (
+	ErrAPITokenNotFound = errors.New("API token not found")
+	ErrInvalidScope     = errors.New("Invalid scope")
+	ErrExpiryInPast     = errors.New("Expiry is in the past")
+	// ErrInvalidAPIToken covers unknown, revoked and expired tokens and
+	// those of disabled users.
+	ErrInvalidAPIToken = errors.New("API token is not valid")
+)
+
+// IsAPIToken reports whether token looks like an API token rather than a
+// login token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HasScope reports whether the token was given the scope.
+func (t APIToken) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create stores the token for its user and returns the secret to send with
+// requests. The secret can't be recovered later.
+func (t *APIToken) Create(ctx context.Context) (secret string, err error) {
+	query := `
+	INSERT INTO api_tokens(user_id, name, token_hash, scopes, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+	ctx, end := startOperation(ctx, "APIToken.Create", query)
+	defer end(&err)
+
+	for _, scope := range t.Scopes {
+		if !scope.valid() {
+			return "", ErrInvalidScope
+		}
+	}
+
+	t.Name = strings.TrimSpace(t.Name)
+	t.CreatedAt = time.Now().UTC()
+
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(t.CreatedAt) {
+		return "", ErrExpiryInPast
+	}
+
+	token, _, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	secret = APITokenPrefix + token
+	result, err := db.Conn(ctx).ExecContext(ctx, query,
+		t.UserID, t.Name, utils.HashRandomToken(secret), formatScopes(t.Scopes), t.CreatedAt, t.ExpiresAt)
+
+	if err != nil {
+		return "", err
+	}
+
+	t.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// GetAPITokensForUser returns the user's tokens, including revoked and
+// expired ones.
+func GetAPITokensForUser(ctx context.Context, userId int64) (tokens []APIToken, err error) {
+	query := `
+	SELECT id, user_id, name, scopes, created_at, expires_at, last_used_at, revoked_at
+	FROM api_tokens WHERE user_id = ? ORDER BY id`
+	ctx, end := startOperation(ctx, "GetAPITokensForUser", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens = []APIToken{}
+
+	for rows.Next() {
+		var token APIToken
+		err := scanAPIToken(rows, &token)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func scanAPIToken(row scanner, token *APIToken) error {
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt)
+
+	token.Scopes = parseScopes(scopes)
+	token.ExpiresAt = nullTimePtr(expiresAt)
+	token.LastUsedAt = nullTimePtr(lastUsedAt)
+	token.RevokedAt = nullTimePtr(revokedAt)
+	return err
+}
+
+// RevokeAPIToken stops the user's token from working.
+func RevokeAPIToken(ctx context.Context, userId, tokenId int64) (err error) {
+	query := "UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL"
+	ctx, end := startOperation(ctx, "RevokeAPIToken", query)
+	defer end(&err)
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, time.Now().UTC(), tokenId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIToken returns the token matching secret and records that
+// it was used. Tokens of disabled users are rejected even if they weren't
+// revoked, like one created while DisableUser ran.
+func AuthenticateAPIToken(ctx context.Context, secret string) (_ *APIToken, err error) {
+	query := `
+	SELECT api_tokens.id, api_tokens.user_id, api_tokens.name, api_tokens.scopes, api_tokens.created_at,
+		api_tokens.expires_at, api_tokens.last_used_at, api_tokens.revoked_at
+	FROM api_tokens JOIN users ON users.id = api_tokens.user_id
+	WHERE api_tokens.token_hash = ? AND users.disabled_at IS NULL`
+	ctx, end := startOperation(ctx, "AuthenticateAPIToken", query)
+	defer end(&err)
+
+	var token APIToken
+	err = scanAPIToken(db.Conn(ctx).QueryRowContext(ctx, query, utils.HashRandomToken(secret)), &token)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval {
+		_, err = db.Conn(ctx).ExecContext(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, token.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		token.LastUsedAt = &now
+	}
+
+	return &token, nil
+}
+
+func parseScopes(stored string) []Scope {
+	scopes := []Scope{}
+
+	for _, s := range strings.Split(stored, ",") {
+		if s != "" {
+			scopes = append(scopes, Scope(s))
+		}
+	}
+
+	return scopes
+}
+
+func formatScopes(scopes []Scope) string {
+	names := make([]string, len(scopes))
+
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"sync"
+
+	"example.com/rest-api/cache"
+	"example.com/rest-api/db"
+)
+
+// eventCache sits in front of GetEventByID and GetAllEvents. It is off
+// unless EnableEventCache is called, and only serves reads made outside of
+// transactions, which always see committed data.
+var eventCache struct {
+	mu       sync.RWMutex
+	byID     *cache.LRU[int64, Event]
+	all      []Event
+	allValid bool
+
+	// generation is bumped by every invalidation. Reads remember it before
+	// querying and don't cache their result if it moved, since a write may
+	// have committed while they ran.
+	generation uint64
+}
+
+// EnableEventCache caches up to size events in memory. A size of 0 turns the
+// cache off. Writes through this package invalidate it; writes by other
+// processes sharing the database are not seen.
+func EnableEventCache(size int) {
+	eventCache.mu.Lock()
+	defer eventCache.mu.Unlock()
+
+	eventCache.byID = nil
+	eventCache.all = nil
+	eventCache.allValid = false
+
+	if size > 0 {
+		eventCache.byID = cache.NewLRU[int64, Event](size)
+	}
+}
+
+// cachedEvent returns the cached event with id. On a miss it returns the
+// generation to pass to cacheEvent.
+func cachedEvent(ctx context.Context, id int64) (*Event, uint64, bool) {
+	eventCache.mu.RLock()
+	defer eventCache.mu.RUnlock()
+
+	if eventCache.byID == nil || db.InTx(ctx) {
+		return nil, eventCache.generation, false
+	}
+
+	event, ok := eventCache.byID.Get(id)
+
+	if !ok {
+		return nil, eventCache.generation, false
+	}
+
+	return &event, eventCache.generation, true
+}
+
+func cacheEvent(ctx context.Context, generation uint64, event Event) {
+	eventCache.mu.RLock()
+	defer eventCache.mu.RUnlock()
+
+	if eventCache.byID == nil || db.InTx(ctx) || generation != eventCache.generation {
+		return
+	}
+
+	eventCache.byID.Add(event.ID, event)
+}
+
+// cachedEvents returns the cached event list. On a miss it returns the
+// generation to pass to cacheEvents.
+func cachedEvents(ctx context.Context) ([]Event, uint64, bool) {
+	eventCache.mu.RLock()
+	defer eventCache.mu.RUnlock()
+
+	if eventCache.byID == nil || !eventCache.allValid || db.InTx(ctx) {
+		return nil, eventCache.generation, false
+	}
+
+	return append([]Event(nil), eventCache.all...), eventCache.generation, true
+}
+
+func cacheEvents(ctx context.Context, generation uint64, events []Event) {
+	eventCache.mu.Lock()
+	defer eventCache.mu.Unlock()
+
+	if eventCache.byID == nil || db.InTx(ctx) || generation != eventCache.generation {
+		return
+	}
+
+	eventCache.all = append([]Event(nil), events...)
+	eventCache.allValid = true
+}
+
+// invalidateEventCache drops the event with id (0 for none) and the event
+// list. It runs right away and again after the surrounding transaction
+// commits, so reads in between can't leave stale entries behind.
+func invalidateEventCache(ctx context.Context, id int64) {
+	runInvalidation(ctx, func() {
+		eventCache.mu.Lock()
+		defer eventCache.mu.Unlock()
+
+		eventCache.generation++
+
+		if eventCache.byID == nil {
+			return
+		}
+
+		if id != 0 {
+			eventCache.byID.Remove(id)
+		}
+
+		eventCache.all = nil
+		eventCache.allValid = false
+	})
+}
+
+// purgeEventCache drops every cached event, for writes that touch many
+// events at once.
+func purgeEventCache(ctx context.Context) {
+	runInvalidation(ctx, func() {
+		eventCache.mu.Lock()
+		defer eventCache.mu.Unlock()
+
+		eventCache.generation++
+
+		if eventCache.byID == nil {
+			return
+		}
+
+		eventCache.byID.Purge()
+		eventCache.all = nil
+		eventCache.allValid = false
+	})
+}
+
+func runInvalidation(ctx context.Context, invalidate func()) {
+	invalidate()
+
+	if db.InTx(ctx) {
+		db.AfterCommit(ctx, invalidate)
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"example.com/rest-api/db"
+)
+
+func TestEventCacheIsInvalidatedOnWrite(t *testing.T) {
+	EnableEventCache(16)
+	t.Cleanup(func() { EnableEventCache(0) })
+
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	if _, err := GetEventByID(ctx, event.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetAllEvents(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes behind the models package's back are not seen...
+	_, err := db.DB.Exec("UPDATE events SET name = 'Changed' WHERE id = ?", event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := GetEventByID(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached.Name != "Meetup" {
+		t.Fatalf("got %q, expected the cached name", cached.Name)
+	}
+
+	// ...but writes through it invalidate the cache.
+	event.Name = "Updated"
+	if err := event.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := GetEventByID(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fresh.Name != "Updated" {
+		t.Errorf("got %q after update, want %q", fresh.Name, "Updated")
+	}
+
+	all, err := GetAllEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 1 || all[0].Name != "Updated" {
+		t.Errorf("got stale event list %+v", all)
+	}
+
+	if err := event.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetEventByID(ctx, event.ID); err == nil {
+		t.Error("deleted event is still served from the cache")
+	}
+}
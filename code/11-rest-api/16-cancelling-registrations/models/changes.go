@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"sync"
+
+	"example.com/rest-api/db"
+)
+
+// Kinds of EventChange.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// EventChange tells watchers that an event was created, updated,
+// transferred or deleted through this package. Renaming a tag doesn't count
+// as a change of its events, and writes by other processes sharing the
+// database are not seen.
+type EventChange struct {
+	Type    string
+	EventID int64
+	// Event is the deleted event as passed to Delete, for EventDeleted
+	// only. Watchers load the current event for the other kinds.
+	Event *Event
+}
+
+var eventWatchers struct {
+	mu       sync.Mutex
+	watchers map[chan EventChange]struct{}
+}
+
+// WatchEvents returns a channel receiving every committed event change
+// until stop is called. Up to buffer changes are queued; a watcher falling
+// further behind is dropped and its channel closed, so publishing never
+// blocks a write.
+func WatchEvents(buffer int) (changes <-chan EventChange, stop func()) {
+	ch := make(chan EventChange, buffer)
+
+	eventWatchers.mu.Lock()
+	defer eventWatchers.mu.Unlock()
+
+	if eventWatchers.watchers == nil {
+		eventWatchers.watchers = map[chan EventChange]struct{}{}
+	}
+
+	eventWatchers.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		eventWatchers.mu.Lock()
+		defer eventWatchers.mu.Unlock()
+
+		if _, ok := eventWatchers.watchers[ch]; ok {
+			delete(eventWatchers.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishEventChange sends the change to the watchers once the transaction
+// joined by ctx has committed.
+func publishEventChange(ctx context.Context, change EventChange) {
+	db.AfterCommit(ctx, func() {
+		eventWatchers.mu.Lock()
+		defer eventWatchers.mu.Unlock()
+
+		for ch := range eventWatchers.watchers {
+			select {
+			case ch <- change:
+			default:
+				delete(eventWatchers.watchers, ch)
+				close(ch)
+			}
+		}
+	})
+}
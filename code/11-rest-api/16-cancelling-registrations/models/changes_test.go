@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFailedDeletePublishesNoChange(t *testing.T) {
+	event := setupEvent(t)
+	changes, stop := WatchEvents(1)
+	defer stop()
+
+	failOn(t, "DELETE", "events")
+
+	if err := event.Delete(context.Background()); err == nil {
+		t.Fatal("expected the injected failure")
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("got %+v for a rolled back delete", change)
+	default:
+	}
+}
+
+func TestSlowWatcherIsDropped(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+	changes, stop := WatchEvents(1)
+	defer stop()
+
+	for i := 0; i < 2; i++ {
+		err := event.Update(ctx)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if change := <-changes; change.Type != EventUpdated || change.EventID != event.ID {
+		t.Errorf("got %+v, want the first update", change)
+	}
+
+	if _, ok := <-changes; ok {
+		t.Error("the watcher wasn't dropped when its buffer was full")
+	}
+}
@@ -0,0 +1,317 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+// Registration is a user's place at an event.
+type Registration struct {
+	ID          int64
+	EventID     int64
+	EventName   string
+	UserID      int64
+	CheckedInAt *time.Time
+}
+
+// CheckIn is the result of scanning a check-in code at the door.
+type CheckIn struct {
+	RegistrationID int64
+	UserID         int64
+	Email          string
+	CheckedInAt    time.Time
+}
+
+// EventStats are the live numbers of an event.
+type EventStats struct {
+	Registrations int
+	CheckedIn     int
+}
+
+var (
+	ErrRegistrationNotFound = errors.New("Registration not found")
+	// ErrInvalidCheckInCode covers forged codes, codes for other events and
+	// codes of cancelled registrations.
+	ErrInvalidCheckInCode = errors.New("Check-in code is not valid")
+	ErrAlreadyCheckedIn   = errors.New("Already checked in")
+)
+
+// checkInSignaturePurpose keeps check-in signatures apart from other signed
+// values.
+const checkInSignaturePurpose = "registration-checkin"
+
+// CheckInCode returns the code shown at the door for the registration. Like
+// invite tokens it is the signed registration id, so it can't be guessed
+// from someone else's code.
+func CheckInCode(registrationId int64) string {
+	id := strconv.FormatInt(registrationId, 10)
+	return id + "." + utils.Sign(checkInSignaturePurpose, id)
+}
+
+func parseCheckInCode(code string) (int64, bool) {
+	id, signature, found := strings.Cut(strings.TrimSpace(code), ".")
+
+	if !found || !utils.VerifySignature(checkInSignaturePurpose, id, signature) {
+		return 0, false
+	}
+
+	registrationId, err := strconv.ParseInt(id, 10, 64)
+	return registrationId, err == nil
+}
+
+// GetRegistrationsForUser returns the user's registrations, soonest event
+// first.
+func GetRegistrationsForUser(ctx context.Context, userId int64) (registrations []Registration, err error) {
+	query := `
+	SELECT registrations.id, registrations.event_id, events.name, registrations.user_id, registrations.checked_in_at
+	FROM registrations JOIN events ON events.id = registrations.event_id
+	WHERE registrations.user_id = ? ORDER BY events.dateTime, registrations.id`
+	ctx, end := startOperation(ctx, "GetRegistrationsForUser", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	registrations = []Registration{}
+
+	for rows.Next() {
+		var registration Registration
+		err := scanRegistration(rows, &registration)
+
+		if err != nil {
+			return nil, err
+		}
+
+		registrations = append(registrations, registration)
+	}
+
+	return registrations, rows.Err()
+}
+
+func GetRegistrationByID(ctx context.Context, id int64) (_ *Registration, err error) {
+	query := `
+	SELECT registrations.id, registrations.event_id, events.name, registrations.user_id, registrations.checked_in_at
+	FROM registrations JOIN events ON events.id = registrations.event_id
+	WHERE registrations.id = ?`
+	ctx, end := startOperation(ctx, "GetRegistrationByID", query)
+	defer end(&err)
+
+	var registration Registration
+	err = scanRegistration(db.Conn(ctx).QueryRowContext(ctx, query, id), &registration)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRegistrationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &registration, nil
+}
+
+func scanRegistration(row scanner, registration *Registration) error {
+	var checkedInAt sql.NullTime
+	err := row.Scan(&registration.ID, &registration.EventID, &registration.EventName, &registration.UserID, &checkedInAt)
+	registration.CheckedInAt = nullTimePtr(checkedInAt)
+	return err
+}
+
+// CheckIn records that the holder of the code arrived at the event. A code
+// can only be used once; on ErrAlreadyCheckedIn the returned CheckIn says
+// when it was used.
+func (e Event) CheckIn(ctx context.Context, code string) (_ *CheckIn, err error) {
+	query := "UPDATE registrations SET checked_in_at = ? WHERE id = ? AND checked_in_at IS NULL"
+	ctx, end := startOperation(ctx, "Event.CheckIn", query)
+	defer end(&err)
+
+	registrationId, ok := parseCheckInCode(code)
+
+	if !ok {
+		return nil, ErrInvalidCheckInCode
+	}
+
+	var checkIn CheckIn
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var checkedInAt sql.NullTime
+		err := tx.QueryRowContext(ctx, `
+		SELECT registrations.id, registrations.user_id, users.email, registrations.checked_in_at
+		FROM registrations JOIN users ON users.id = registrations.user_id
+		WHERE registrations.id = ? AND registrations.event_id = ?`, registrationId, e.ID).
+			Scan(&checkIn.RegistrationID, &checkIn.UserID, &checkIn.Email, &checkedInAt)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCheckInCode
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if checkedInAt.Valid {
+			checkIn.CheckedInAt = checkedInAt.Time
+			return ErrAlreadyCheckedIn
+		}
+
+		checkIn.CheckedInAt = time.Now().UTC()
+		_, err = tx.ExecContext(ctx, query, checkIn.CheckedInAt, registrationId)
+		return err
+	})
+
+	if errors.Is(err, ErrAlreadyCheckedIn) {
+		return &checkIn, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkIn, nil
+}
+
+// Stats counts the registrations of the event and how many of them have
+// checked in so far.
+func (e Event) Stats(ctx context.Context) (_ *EventStats, err error) {
+	query := "SELECT COUNT(*), COUNT(checked_in_at) FROM registrations WHERE event_id = ?"
+	ctx, end := startOperation(ctx, "Event.Stats", query)
+	defer end(&err)
+
+	var stats EventStats
+	err = db.Conn(ctx).QueryRowContext(ctx, query, e.ID).Scan(&stats.Registrations, &stats.CheckedIn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// Registrations returns the registrations of the event in the order they
+// were made.
+func (e Event) Registrations(ctx context.Context) (registrations []Registration, err error) {
+	query := `
+	SELECT registrations.id, registrations.event_id, events.name, registrations.user_id, registrations.checked_in_at
+	FROM registrations JOIN events ON events.id = registrations.event_id
+	WHERE registrations.event_id = ? ORDER BY registrations.id`
+	ctx, end := startOperation(ctx, "Event.Registrations", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, e.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	registrations = []Registration{}
+
+	for rows.Next() {
+		var registration Registration
+		err := scanRegistration(rows, &registration)
+
+		if err != nil {
+			return nil, err
+		}
+
+		registrations = append(registrations, registration)
+	}
+
+	return registrations, rows.Err()
+}
+
+// RegistrationsOfEvents is Event.Registrations for many events, by event
+// id. Events without registrations are missing from the map.
+func RegistrationsOfEvents(ctx context.Context, eventIds []int64) (registrations map[int64][]Registration, err error) {
+	query := `
+	SELECT registrations.id, registrations.event_id, events.name, registrations.user_id, registrations.checked_in_at
+	FROM registrations JOIN events ON events.id = registrations.event_id
+	WHERE registrations.event_id IN (%s) ORDER BY registrations.id`
+	ctx, end := startOperation(ctx, "RegistrationsOfEvents", query)
+	defer end(&err)
+
+	registrations = make(map[int64][]Registration, len(eventIds))
+
+	err = eachIDBatch(eventIds, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), args...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var registration Registration
+			err := scanRegistration(rows, &registration)
+
+			if err != nil {
+				return err
+			}
+
+			registrations[registration.EventID] = append(registrations[registration.EventID], registration)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return registrations, nil
+}
+
+// CountRegistrations returns the number of registrations of each event.
+// Events without registrations are missing from the map.
+func CountRegistrations(ctx context.Context, eventIds []int64) (counts map[int64]int, err error) {
+	query := "SELECT event_id, COUNT(*) FROM registrations WHERE event_id IN (%s) GROUP BY event_id"
+	ctx, end := startOperation(ctx, "CountRegistrations", query)
+	defer end(&err)
+
+	counts = make(map[int64]int, len(eventIds))
+
+	err = eachIDBatch(eventIds, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), args...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var eventId int64
+			var n int
+			err := rows.Scan(&eventId, &n)
+
+			if err != nil {
+				return err
+			}
+
+			counts[eventId] = n
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
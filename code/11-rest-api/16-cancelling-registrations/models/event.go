@@ -1,9 +1,15 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"example.com/rest-api/db"
+	"example.com/rest-api/metrics"
 )
 
 type Event struct {
@@ -13,41 +19,216 @@ type Event struct {
 	Location    string    `binding:"required"`
 	DateTime    time.Time `binding:"required"`
 	UserID      int64
+	// OrgID is the organization owning the event, or 0 if it belongs to
+	// its creator alone. It is set on creation only.
+	OrgID     int64
+	UpdatedAt time.Time
+	// Tags are the names of the event's tags. On Save and Update a nil
+	// slice leaves the tags alone, an empty one removes them.
+	Tags []string
+	// Venue is where the event takes place. On Update nil leaves it alone
+	// and an empty Venue removes it.
+	Venue *Venue
+	// Visibility is one of the Visibility constants. Save defaults it to
+	// VisibilityPublic and Update leaves it alone when it is empty.
+	Visibility string `binding:"omitempty,oneof=public unlisted private"`
+}
+
+// Event visibilities. Public events are listed and open to everyone.
+// Unlisted events are open to anyone with their id but not listed. Private
+// events are only visible to their hosts and invitees, and registering
+// requires an invite.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// EventFilter narrows down FindEvents. The zero value matches every public
+// event.
+type EventFilter struct {
+	// Tags only matches events that have all of these tags.
+	Tags []string
+	// OrgID only matches events of this organization.
+	OrgID int64
+	// AllVisibilities also matches unlisted and private events.
+	AllVisibilities bool
+}
+
+// where returns the SQL condition (prefixed with WHERE, or empty) and its
+// arguments for a query over the events table.
+func (f EventFilter) where() (string, []any) {
+	conditions, args := f.conditions()
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// conditions returns the SQL conditions that must all hold, and their
+// arguments in order.
+func (f EventFilter) conditions() ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if tags := normalizeTags(f.Tags); len(tags) > 0 {
+		conditions = append(conditions, `events.id IN (
+			SELECT event_tags.event_id FROM event_tags
+			JOIN tags ON tags.id = event_tags.tag_id
+			WHERE tags.name IN (`+placeholders(len(tags))+`)
+			GROUP BY event_tags.event_id
+			HAVING COUNT(DISTINCT tags.id) = ?
+		)`)
+
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+
+		args = append(args, len(tags))
+	}
+
+	if f.OrgID != 0 {
+		conditions = append(conditions, "events.org_id = ?")
+		args = append(args, f.OrgID)
+	}
+
+	if !f.AllVisibilities {
+		conditions = append(conditions, "events.visibility = ?")
+		args = append(args, VisibilityPublic)
+	}
+
+	return conditions, args
+}
+
+func (f EventFilter) isEmpty() bool {
+	return len(f.Tags) == 0 && f.OrgID == 0 && !f.AllVisibilities
+}
+
+// eventColumns is the column list scanEvent expects.
+const eventColumns = "id, name, description, location, dateTime, user_id, org_id, updated_at, venue_address, venue_lat, venue_lng, visibility"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row scanner, event *Event) error {
+	var orgId sql.NullInt64
+	var updatedAt sql.NullTime
+	var address sql.NullString
+	var lat, lng sql.NullFloat64
+	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID, &orgId, &updatedAt, &address, &lat, &lng, &event.Visibility)
+	event.OrgID = orgId.Int64
+	event.UpdatedAt = updatedAt.Time
+	event.Venue = venueFromColumns(address, lat, lng)
+	return err
 }
 
 var events = []Event{}
 
-func (e *Event) Save() error {
+var ErrEventNotFound = errors.New("Event not found")
+
+// ErrAlreadyRegistered is returned by Register and Reserve when the user is
+// registered for the event or holds an unexpired ticket for it.
+var ErrAlreadyRegistered = errors.New("Already registered")
+
+var (
+	// ErrInvalidEvent is returned by Validate when a required field is
+	// missing.
+	ErrInvalidEvent = errors.New("Event is missing required fields")
+	// ErrInvalidVisibility is returned by Validate for an unknown
+	// visibility.
+	ErrInvalidVisibility = errors.New("Visibility must be public, unlisted or private")
+)
+
+// Validate checks the fields a client sets when creating or updating an
+// event: name, description, location and date are required and visibility,
+// if set, must be one of the Visibility constants.
+func (e Event) Validate() error {
+	if strings.TrimSpace(e.Name) == "" || strings.TrimSpace(e.Description) == "" || strings.TrimSpace(e.Location) == "" || e.DateTime.IsZero() {
+		return ErrInvalidEvent
+	}
+
+	switch e.Visibility {
+	case "", VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return nil
+	default:
+		return ErrInvalidVisibility
+	}
+}
+
+func (e *Event) Save(ctx context.Context) (err error) {
 	query := `
-	INSERT INTO events(name, description, location, dateTime, user_id) 
-	VALUES (?, ?, ?, ?, ?)`
-	stmt, err := db.DB.Prepare(query)
-	if err != nil {
-		return err
+	INSERT INTO events(name, description, location, dateTime, user_id, org_id, updated_at, venue_address, venue_lat, venue_lng, visibility) 
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, end := startOperation(ctx, "Event.Save", query)
+	defer end(&err)
+	defer invalidateEventCache(ctx, 0)
+
+	if !e.Venue.complete() {
+		return ErrIncompleteVenue
 	}
-	defer stmt.Close()
-	result, err := stmt.Exec(e.Name, e.Description, e.Location, e.DateTime, e.UserID)
-	if err != nil {
-		return err
+
+	if e.Visibility == "" {
+		e.Visibility = VisibilityPublic
 	}
-	id, err := result.LastInsertId()
-	e.ID = id
-	return err
+
+	e.UpdatedAt = time.Now().UTC()
+	orgId := sql.NullInt64{Int64: e.OrgID, Valid: e.OrgID != 0}
+	address, lat, lng := e.Venue.columns()
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		result, err := stmt.ExecContext(ctx, e.Name, e.Description, e.Location, e.DateTime, e.UserID, orgId, e.UpdatedAt, address, lat, lng, e.Visibility)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		e.ID = id
+		if err != nil {
+			return err
+		}
+		publishEventChange(ctx, EventChange{Type: EventCreated, EventID: e.ID})
+		err = scheduleReminders(ctx, e.ID, e.DateTime)
+		if err != nil || e.Tags == nil {
+			return err
+		}
+		e.Tags, err = setEventTags(ctx, tx, e.ID, e.Tags)
+		return err
+	})
 }
 
-func GetAllEvents() ([]Event, error) {
-	query := "SELECT * FROM events"
-	rows, err := db.DB.Query(query)
+func GetAllEvents(ctx context.Context) ([]Event, error) {
+	return FindEvents(ctx, EventFilter{})
+}
+
+// FindEvents returns the events matching filter, with their tags.
+func FindEvents(ctx context.Context, filter EventFilter) (events []Event, err error) {
+	cached, generation, ok := cachedEvents(ctx)
+
+	if ok && filter.isEmpty() {
+		return cached, nil
+	}
+
+	where, args := filter.where()
+	query := "SELECT " + eventColumns + " FROM events" + where
+	ctx, end := startOperation(ctx, "FindEvents", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var events []Event
-
 	for rows.Next() {
 		var event Event
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+		err := scanEvent(rows, &event)
 
 		if err != nil {
 			return nil, err
@@ -56,80 +237,453 @@ func GetAllEvents() ([]Event, error) {
 		events = append(events, event)
 	}
 
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadTags(ctx, events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.isEmpty() {
+		cacheEvents(ctx, generation, events)
+	}
+
 	return events, nil
 }
 
-func GetEventByID(id int64) (*Event, error) {
-	query := "SELECT * FROM events WHERE id = ?"
-	row := db.DB.QueryRow(query, id)
+func GetEventByID(ctx context.Context, id int64) (_ *Event, err error) {
+	cached, generation, ok := cachedEvent(ctx, id)
+
+	if ok {
+		return cached, nil
+	}
+
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ?"
+	ctx, end := startOperation(ctx, "GetEventByID", query)
+	defer end(&err)
+
+	row := db.Conn(ctx).QueryRowContext(ctx, query, id)
 
 	var event Event
-	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+	err = scanEvent(row, &event)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{event}
+	err = loadTags(ctx, events)
 	if err != nil {
 		return nil, err
 	}
+	event = events[0]
 
+	cacheEvent(ctx, generation, event)
 	return &event, nil
 }
 
-func (event Event) Update() error {
+// GetEventsByIDs returns the events with the ids and their tags. Unknown
+// ids are missing from the map. Visibility is not checked.
+func GetEventsByIDs(ctx context.Context, ids []int64) (_ map[int64]Event, err error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id IN (%s)"
+	ctx, end := startOperation(ctx, "GetEventsByIDs", query)
+	defer end(&err)
+
+	var events []Event
+
+	err = eachIDBatch(ids, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), args...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var event Event
+			err := scanEvent(rows, &event)
+
+			if err != nil {
+				return err
+			}
+
+			events = append(events, event)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadTags(ctx, events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]Event, len(events))
+
+	for _, event := range events {
+		byId[event.ID] = event
+	}
+
+	return byId, nil
+}
+
+// EventsChangedAt returns when an event was last created, updated or
+// deleted.
+func EventsChangedAt(ctx context.Context) (changedAt time.Time, err error) {
+	query := "SELECT changed_at FROM collection_changes WHERE name = 'events'"
+	ctx, end := startOperation(ctx, "EventsChangedAt", query)
+	defer end(&err)
+
+	err = db.Conn(ctx).QueryRowContext(ctx, query).Scan(&changedAt)
+	return changedAt, err
+}
+
+func (event Event) Update(ctx context.Context) (err error) {
 	query := `
 	UPDATE events
-	SET name = ?, description = ?, location = ?, dateTime = ?
+	SET name = ?, description = ?, location = ?, dateTime = ?, updated_at = ?
 	WHERE id = ?
 	`
-	stmt, err := db.DB.Prepare(query)
+	ctx, end := startOperation(ctx, "Event.Update", query)
+	defer end(&err)
+	defer invalidateEventCache(ctx, event.ID)
 
-	if err != nil {
-		return err
+	if !event.Venue.complete() {
+		return ErrIncompleteVenue
 	}
 
-	defer stmt.Close()
+	event.UpdatedAt = time.Now().UTC()
 
-	_, err = stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.ID)
-	return err
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var previous time.Time
+		err := tx.QueryRowContext(ctx, "SELECT dateTime FROM events WHERE id = ?", event.ID).Scan(&previous)
+
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+
+		if err != nil {
+			return err
+		}
+
+		defer stmt.Close()
+
+		_, err = stmt.ExecContext(ctx, event.Name, event.Description, event.Location, event.DateTime, event.UpdatedAt, event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		publishEventChange(ctx, EventChange{Type: EventUpdated, EventID: event.ID})
+
+		// Reminders only move with the date, so other edits don't queue
+		// reminders that were sent already again.
+		if !previous.Equal(event.DateTime) {
+			err = scheduleReminders(ctx, event.ID, event.DateTime)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if event.Visibility != "" {
+			_, err = tx.ExecContext(ctx, "UPDATE events SET visibility = ? WHERE id = ?", event.Visibility, event.ID)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if event.Venue != nil {
+			address, lat, lng := event.Venue.columns()
+			_, err = tx.ExecContext(ctx,
+				"UPDATE events SET venue_address = ?, venue_lat = ?, venue_lng = ? WHERE id = ?",
+				address, lat, lng, event.ID)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if event.Tags == nil {
+			return nil
+		}
+
+		_, err = setEventTags(ctx, tx, event.ID, event.Tags)
+		return err
+	})
 }
 
-func (event Event) Delete() error {
+// Delete removes the event together with its registrations. Events with
+// paid orders can't be deleted until those are refunded.
+func (event Event) Delete(ctx context.Context) (err error) {
 	query := "DELETE FROM events WHERE id = ?"
-	stmt, err := db.DB.Prepare(query)
+	ctx, end := startOperation(ctx, "Event.Delete", query)
+	defer end(&err)
+	defer invalidateEventCache(ctx, event.ID)
 
-	if err != nil {
-		return err
-	}
+	// Rows are deleted before the rows they reference, as foreign keys
+	// are enforced.
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM reminder_deliveries WHERE registration_id IN (SELECT id FROM registrations WHERE event_id = ?)", event.ID)
+
+		if err != nil {
+			return err
+		}
 
-	defer stmt.Close()
+		_, err = tx.ExecContext(ctx, "DELETE FROM registrations WHERE event_id = ?", event.ID)
 
-	_, err = stmt.Exec(event.ID)
-	return err
+		if err != nil {
+			return err
+		}
+
+		err = deleteOrders(ctx, tx, event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM event_tags WHERE event_id = ?", event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM event_hosts WHERE event_id = ?", event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		err = deleteInvites(ctx, tx, event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		err = cancelReminders(ctx, event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query, event.ID)
+
+		if err != nil {
+			return err
+		}
+
+		publishEventChange(ctx, EventChange{Type: EventDeleted, EventID: event.ID, Event: &event})
+		return nil
+	})
 }
 
-func (e Event) Register(userId int64) error {
+// Register signs the user up for the event. The event is looked up in the
+// same transaction, so a concurrent Delete can't leave an orphaned
+// registration behind.
+//
+// inviteToken may be empty. If it is set it must be a valid invite for the
+// event and its use is recorded. Private events can't be joined without
+// either an invite token or an invite sent to the user's email address,
+// unless the user owns or co-hosts them, and those the user can't see are
+// reported as ErrEventNotFound. Registering twice fails with
+// ErrAlreadyRegistered.
+func (e Event) Register(ctx context.Context, userId int64, inviteToken string) (err error) {
 	query := "INSERT INTO registrations(event_id, user_id) VALUES (?, ?)"
-	stmt, err := db.DB.Prepare(query)
+	ctx, end := startOperation(ctx, "Event.Register", query)
+	defer end(&err)
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var event Event
+		err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE id = ?", e.ID), &event)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEventNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		visible, err := event.VisibleTo(ctx, userId, inviteToken)
+
+		if err != nil {
+			return err
+		}
+
+		if !visible {
+			return ErrEventNotFound
+		}
+
+		err = checkNotRegistered(ctx, tx, e.ID, userId, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		var ticketed bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM ticket_types WHERE event_id = ?)", e.ID).Scan(&ticketed)
+
+		if err != nil {
+			return err
+		}
+
+		if ticketed {
+			return ErrTicketRequired
+		}
+
+		err = checkInvite(ctx, tx, event, userId, inviteToken)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query, e.ID, userId)
+
+		if db.IsUniqueViolation(err) {
+			return ErrAlreadyRegistered
+		}
+
+		if err != nil {
+			return err
+		}
+
+		db.AfterCommit(ctx, metrics.EventRegistered)
+		return nil
+	})
+}
+
+// CancelRegistration removes the user's registration, or returns
+// ErrRegistrationNotFound if they aren't registered. Paid tickets are
+// refunded first, so a failed refund leaves the registration in place and
+// the call can be retried.
+func (e Event) CancelRegistration(ctx context.Context, userId int64) (err error) {
+	query := "DELETE FROM registrations WHERE event_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "Event.CancelRegistration", query)
+	defer end(&err)
+
+	orders, err := refundOrders(ctx, e.ID, userId)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM reminder_deliveries WHERE registration_id IN (SELECT id FROM registrations WHERE event_id = ? AND user_id = ?)", e.ID, userId)
 
-	_, err = stmt.Exec(e.ID, userId)
+		if err != nil {
+			return err
+		}
 
-	return err
+		result, err := tx.ExecContext(ctx, query, e.ID, userId)
+
+		if err != nil {
+			return err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrRegistrationNotFound
+		}
+
+		db.AfterCommit(ctx, metrics.EventRegistrationCancelled)
+		return closeOrders(ctx, tx, orders)
+	})
 }
 
-func (e Event) CancelRegistration(userId int64) error {
-	query := "DELETE FROM registrations WHERE event_id = ? AND user_id = ?"
-	stmt, err := db.DB.Prepare(query)
+// eachEventBatch is how many events EachEvent reads per query.
+var eachEventBatch = 500
+
+// EachEvent calls fn for every event, reading them from the database in
+// batches so the whole table is never held in memory. Each batch is read
+// before fn is called, so a slow fn, like a download to a slow client,
+// doesn't hold the read lock that writers wait for. It stops at the first
+// error returned by fn. Tags are not loaded.
+//
+// The query timeout bounds each batch rather than the whole operation, as
+// an export streamed to a client would otherwise be cut off midway.
+func EachEvent(ctx context.Context, fn func(Event) error) (err error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE id > ? ORDER BY id LIMIT ?"
+	ctx, end := startSpan(ctx, "EachEvent", query)
+	defer end(&err)
+
+	var lastId int64
 
+	for {
+		events, err := eventBatch(ctx, query, lastId)
+
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			err = fn(event)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(events) < eachEventBatch {
+			return nil
+		}
+
+		lastId = events[len(events)-1].ID
+	}
+}
+
+func eventBatch(ctx context.Context, query string, afterId int64) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.QueryTimeout("EachEvent"))
+	defer cancel()
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, afterId, eachEventBatch)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	defer stmt.Close()
+	events := make([]Event, 0, eachEventBatch)
 
-	_, err = stmt.Exec(e.ID, userId)
+	for rows.Next() {
+		var event Event
+		err := scanEvent(rows, &event)
 
-	return err
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// SaveAll saves every event in a single transaction: either all of them are
+// created or none.
+func SaveAll(ctx context.Context, events []Event) error {
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		for i := range events {
+			err := events[i].Save(ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
@@ -0,0 +1,305 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+func setupEvent(t *testing.T) Event {
+	t.Helper()
+
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+
+	_, err := db.DB.Exec("INSERT INTO users(email, password) VALUES ('owner@example.com', 'x'), ('guest@example.com', 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := Event{
+		Name:        "Meetup",
+		Description: "A meetup",
+		Location:    "Berlin",
+		DateTime:    time.Date(2025, 1, 1, 15, 30, 0, 0, time.UTC),
+		UserID:      1,
+	}
+
+	err = event.Save(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
+
+// failOn makes the next matching statement fail inside SQLite, in the middle
+// of whatever transaction is running.
+func failOn(t *testing.T, operation, table string) {
+	t.Helper()
+
+	_, err := db.DB.Exec("CREATE TRIGGER inject_failure BEFORE " + operation + " ON " + table +
+		" BEGIN SELECT RAISE(ABORT, 'injected failure'); END")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func count(t *testing.T, query string, args ...any) int {
+	t.Helper()
+
+	var n int
+	err := db.DB.QueryRow(query, args...).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestDeleteRemovesRegistrations(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	err := event.Register(ctx, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = event.Delete(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM registrations WHERE event_id = ?", event.ID); n != 0 {
+		t.Errorf("%d registrations left for deleted event", n)
+	}
+}
+
+func TestDeleteIsAllOrNothing(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	err := event.Register(ctx, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Registrations are deleted first, so this fails halfway through.
+	failOn(t, "DELETE", "events")
+
+	err = event.Delete(ctx)
+	if err == nil {
+		t.Fatal("expected the injected failure")
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM events WHERE id = ?", event.ID); n != 1 {
+		t.Error("event was deleted")
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM registrations WHERE event_id = ?", event.ID); n != 1 {
+		t.Error("registrations were deleted although the event wasn't")
+	}
+}
+
+func TestRegisterFailureLeavesNoRegistration(t *testing.T) {
+	event := setupEvent(t)
+
+	failOn(t, "INSERT", "registrations")
+
+	err := event.Register(context.Background(), 2, "")
+	if err == nil {
+		t.Fatal("expected the injected failure")
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM registrations"); n != 0 {
+		t.Errorf("got %d registrations, want 0", n)
+	}
+}
+
+func TestRegisterForMissingEvent(t *testing.T) {
+	setupEvent(t)
+
+	err := Event{ID: 42}.Register(context.Background(), 2, "")
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrEventNotFound)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	err := event.Register(ctx, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := event.Register(ctx, 2, ""); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("got error %v, want %v", err, ErrAlreadyRegistered)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM registrations"); n != 1 {
+		t.Errorf("got %d registrations, want 1", n)
+	}
+}
+
+func TestHostsRegisterForPrivateEventsWithoutInvite(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	_, err := db.DB.Exec("INSERT INTO users(email, password) VALUES ('stranger@example.com', 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event.Visibility = VisibilityPrivate
+	err = event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = event.SetHost(ctx, 2, []Permission{PermCheckIn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := event.Register(ctx, 1, ""); err != nil {
+		t.Errorf("owner: got error %v", err)
+	}
+
+	if err := event.Register(ctx, 2, ""); err != nil {
+		t.Errorf("co-host: got error %v", err)
+	}
+
+	if err := event.Register(ctx, 3, ""); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("stranger: got error %v, want %v", err, ErrEventNotFound)
+	}
+}
+
+func TestModelsJoinCallerTransaction(t *testing.T) {
+	event := setupEvent(t)
+
+	injected := errors.New("injected failure")
+	err := db.WithTx(context.Background(), func(ctx context.Context, tx *sql.Tx) error {
+		if err := event.Register(ctx, 2, ""); err != nil {
+			return err
+		}
+		if err := event.Delete(ctx); err != nil {
+			return err
+		}
+		return injected
+	})
+	if !errors.Is(err, injected) {
+		t.Fatalf("got error %v, want %v", err, injected)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM events WHERE id = ?", event.ID); n != 1 {
+		t.Error("event delete survived the rollback")
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM registrations"); n != 0 {
+		t.Error("registration survived the rollback")
+	}
+}
+
+func TestEachEventDoesNotBlockWriters(t *testing.T) {
+	setupEvent(t)
+
+	batch := eachEventBatch
+	eachEventBatch = 2
+	t.Cleanup(func() { eachEventBatch = batch })
+
+	for i := 0; i < 4; i++ {
+		event := Event{Name: "Meetup", Description: "A meetup", Location: "Berlin", DateTime: time.Now().UTC(), UserID: 1}
+
+		if err := event.Save(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	start := time.Now()
+
+	// Writing while the export runs would wait for the busy timeout and
+	// fail if a read were still open.
+	err := EachEvent(context.Background(), func(event Event) error {
+		ids = append(ids, event.ID)
+		_, err := db.DB.Exec("UPDATE events SET description = 'Exported' WHERE id = ?", event.ID)
+		return err
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Errorf("got events %v, want 1 to 5", ids)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("export took %v, writers were blocked", elapsed)
+	}
+}
+
+func TestEachEventOutlastsTheQueryTimeout(t *testing.T) {
+	setupEvent(t)
+
+	batch := eachEventBatch
+	eachEventBatch = 1
+	timeout := db.QueryTimeouts["EachEvent"]
+	db.QueryTimeouts["EachEvent"] = 50 * time.Millisecond
+	t.Cleanup(func() {
+		eachEventBatch = batch
+		db.QueryTimeouts["EachEvent"] = timeout
+	})
+
+	for i := 0; i < 3; i++ {
+		event := Event{Name: "Meetup", Description: "A meetup", Location: "Berlin", DateTime: time.Now().UTC(), UserID: 1}
+
+		if err := event.Save(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A slow client makes the export take longer than the timeout of a
+	// single batch.
+	exported := 0
+	err := EachEvent(context.Background(), func(event Event) error {
+		exported++
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+
+	if err != nil || exported != 4 {
+		t.Errorf("got %d events, %v, want all 4", exported, err)
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	valid := Event{Name: "Meetup", Description: "A meetup", Location: "Berlin", DateTime: time.Now()}
+
+	for _, test := range []struct {
+		name   string
+		change func(*Event)
+		want   error
+	}{
+		{"valid", func(*Event) {}, nil},
+		{"blank name", func(e *Event) { e.Name = "  " }, ErrInvalidEvent},
+		{"no date", func(e *Event) { e.DateTime = time.Time{} }, ErrInvalidEvent},
+		{"private", func(e *Event) { e.Visibility = VisibilityPrivate }, nil},
+		{"unknown visibility", func(e *Event) { e.Visibility = "secret" }, ErrInvalidVisibility},
+	} {
+		event := valid
+		test.change(&event)
+
+		if err := event.Validate(); !errors.Is(err, test.want) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.want)
+		}
+	}
+}
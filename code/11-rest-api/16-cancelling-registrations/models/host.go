@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+// Host is a co-host of an event and what they may do.
+type Host struct {
+	UserID      int64
+	Email       string
+	Permissions []Permission
+}
+
+type Attendee struct {
+	UserID int64
+	Email  string
+}
+
+var (
+	ErrInvalidPermission = errors.New("Permission can't be granted to co-hosts")
+	ErrHostNotFound      = errors.New("User is not a co-host of the event")
+	// ErrHostIsOwner is returned when the owner is made a co-host of their
+	// own event.
+	ErrHostIsOwner = errors.New("User owns the event")
+)
+
+// SetHost makes the user a co-host with exactly the given permissions,
+// replacing what they had before.
+func (e Event) SetHost(ctx context.Context, userId int64, permissions []Permission) (err error) {
+	query := `
+	INSERT INTO event_hosts(event_id, user_id, permissions) VALUES (?, ?, ?)
+	ON CONFLICT(event_id, user_id) DO UPDATE SET permissions = excluded.permissions`
+	ctx, end := startOperation(ctx, "Event.SetHost", query)
+	defer end(&err)
+
+	if userId == e.UserID {
+		return ErrHostIsOwner
+	}
+
+	unique := []Permission{}
+	seen := map[Permission]bool{}
+
+	for _, p := range permissions {
+		if !p.grantable() {
+			return ErrInvalidPermission
+		}
+
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+
+	_, err = db.Conn(ctx).ExecContext(ctx, query, e.ID, userId, formatPermissions(unique))
+	return err
+}
+
+func (e Event) RemoveHost(ctx context.Context, userId int64) (err error) {
+	query := "DELETE FROM event_hosts WHERE event_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "Event.RemoveHost", query)
+	defer end(&err)
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, e.ID, userId)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrHostNotFound
+	}
+
+	return nil
+}
+
+func (e Event) Hosts(ctx context.Context) (hosts []Host, err error) {
+	query := `
+	SELECT users.id, users.email, event_hosts.permissions FROM event_hosts
+	JOIN users ON users.id = event_hosts.user_id
+	WHERE event_hosts.event_id = ?
+	ORDER BY users.email`
+	ctx, end := startOperation(ctx, "Event.Hosts", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, e.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	hosts = []Host{}
+
+	for rows.Next() {
+		var host Host
+		var stored string
+		err := rows.Scan(&host.UserID, &host.Email, &stored)
+
+		if err != nil {
+			return nil, err
+		}
+
+		host.Permissions = parsePermissions(stored)
+		hosts = append(hosts, host)
+	}
+
+	return hosts, rows.Err()
+}
+
+// TransferOwnership hands the event over to another user. A co-host that
+// becomes the owner stops being a co-host. The event of an organization can
+// only be handed to one of its members.
+func (e *Event) TransferOwnership(ctx context.Context, newOwnerId int64) (err error) {
+	query := "UPDATE events SET user_id = ?, updated_at = ? WHERE id = ?"
+	ctx, end := startOperation(ctx, "Event.TransferOwnership", query)
+	defer end(&err)
+	defer invalidateEventCache(ctx, e.ID)
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if e.OrgID != 0 {
+			role, err := OrgRole(ctx, e.OrgID, newOwnerId)
+
+			if err != nil {
+				return err
+			}
+
+			if role == "" {
+				return ErrNotOrgMember
+			}
+		}
+
+		updatedAt := time.Now().UTC()
+		_, err := tx.ExecContext(ctx, query, newOwnerId, updatedAt, e.ID)
+
+		if err != nil {
+			return err
+		}
+
+		publishEventChange(ctx, EventChange{Type: EventUpdated, EventID: e.ID})
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM event_hosts WHERE event_id = ? AND user_id = ?", e.ID, newOwnerId)
+
+		if err != nil {
+			return err
+		}
+
+		e.UserID = newOwnerId
+		e.UpdatedAt = updatedAt
+		return nil
+	})
+}
+
+// Attendees returns the users registered for the event.
+func (e Event) Attendees(ctx context.Context) (attendees []Attendee, err error) {
+	query := `
+	SELECT users.id, users.email FROM registrations
+	JOIN users ON users.id = registrations.user_id
+	WHERE registrations.event_id = ?
+	ORDER BY registrations.id`
+	ctx, end := startOperation(ctx, "Event.Attendees", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, e.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attendees = []Attendee{}
+
+	for rows.Next() {
+		var attendee Attendee
+		err := rows.Scan(&attendee.UserID, &attendee.Email)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attendees = append(attendees, attendee)
+	}
+
+	return attendees, rows.Err()
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+// SSOLoginTTL is how long a user has to finish signing in with an identity
+// provider.
+const SSOLoginTTL = 10 * time.Minute
+
+var (
+	// ErrSSOLoginNotFound covers unknown, expired and already finished
+	// sign-ins.
+	ErrSSOLoginNotFound = errors.New("Sign-in not found or expired")
+	// ErrEmailNotVerified is returned for identities whose email address
+	// the provider hasn't verified. Trusting it could hand someone else's
+	// account over.
+	ErrEmailNotVerified = errors.New("Email not verified by the identity provider")
+)
+
+// SSOLogin is a sign-in with an identity provider waiting for its
+// callback.
+type SSOLogin struct {
+	Provider string
+	Nonce    string
+	Verifier string
+}
+
+// SaveSSOLogin stores the login under its state until the callback comes
+// back with it. Only a hash of state is stored.
+func SaveSSOLogin(ctx context.Context, state string, login SSOLogin) (err error) {
+	query := "INSERT INTO sso_logins(state_hash, provider, nonce, verifier, expires_at) VALUES (?, ?, ?, ?, ?)"
+	ctx, end := startOperation(ctx, "SaveSSOLogin", query)
+	defer end(&err)
+
+	now := time.Now().UTC()
+
+	// Abandoned sign-ins are cleaned up here rather than by a worker.
+	_, err = db.Conn(ctx).ExecContext(ctx, "DELETE FROM sso_logins WHERE expires_at <= ?", now)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Conn(ctx).ExecContext(ctx, query, utils.HashRandomToken(state), login.Provider, login.Nonce, login.Verifier, now.Add(SSOLoginTTL))
+	return err
+}
+
+// TakeSSOLogin returns the login saved under state and removes it, so each
+// callback works once.
+func TakeSSOLogin(ctx context.Context, provider, state string) (_ *SSOLogin, err error) {
+	query := `
+	DELETE FROM sso_logins WHERE state_hash = ? AND provider = ? AND expires_at > ?
+	RETURNING provider, nonce, verifier`
+	ctx, end := startOperation(ctx, "TakeSSOLogin", query)
+	defer end(&err)
+
+	var login SSOLogin
+	err = db.Conn(ctx).QueryRowContext(ctx, query, utils.HashRandomToken(state), provider, time.Now().UTC()).
+		Scan(&login.Provider, &login.Nonce, &login.Verifier)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSSOLoginNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &login, nil
+}
+
+// SignInWithIdentity returns the user the provider's identity belongs to.
+// The first sign-in links the identity to the user with the same email
+// address, or creates a user without a password if there is none. Both
+// need an email address the provider verified; later sign-ins only go by
+// the subject, so changing the email address at the provider is fine.
+//
+// Sign up doesn't verify email addresses, so a user with a password may
+// have been created by someone else ahead of the address's owner. Linking
+// to one resets its credentials: the password and API tokens are removed
+// and its login tokens revoked. The second factor stays, it still guards
+// sign-ins through the provider.
+func SignInWithIdentity(ctx context.Context, provider, subject, email string, emailVerified bool) (_ *User, err error) {
+	query := `
+	SELECT users.id, users.email, users.role, users.disabled_at IS NOT NULL FROM user_identities
+	JOIN users ON users.id = user_identities.user_id
+	WHERE user_identities.provider = ? AND user_identities.subject = ?`
+	ctx, end := startOperation(ctx, "SignInWithIdentity", query)
+	defer end(&err)
+
+	var user User
+	email = NormalizeEmail(email)
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var disabled bool
+		err := tx.QueryRowContext(ctx, query, provider, subject).Scan(&user.ID, &user.Email, &user.Role, &disabled)
+
+		if err == nil && disabled {
+			return ErrUserDisabled
+		}
+
+		if err == nil || !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if !emailVerified {
+			return ErrEmailNotVerified
+		}
+
+		var password string
+		err = tx.QueryRowContext(ctx, "SELECT id, email, password, role, disabled_at IS NOT NULL FROM users WHERE email = ? COLLATE NOCASE", email).
+			Scan(&user.ID, &user.Email, &password, &user.Role, &disabled)
+
+		if err == nil && disabled {
+			return ErrUserDisabled
+		}
+
+		if err == nil && password != "" {
+			err = resetCredentials(ctx, tx, user.ID)
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			// The empty password never matches a hash, so the user can
+			// only sign in through a provider.
+			err = tx.QueryRowContext(ctx, "INSERT INTO users(email, password) VALUES (?, '') RETURNING id, email, role", email).
+				Scan(&user.ID, &user.Email, &user.Role)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO user_identities(user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)",
+			user.ID, provider, subject, email, time.Now().UTC())
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// resetCredentials leaves the user with no way to sign in other than their
+// linked identities.
+func resetCredentials(ctx context.Context, tx *sql.Tx, userId int64) error {
+	now := time.Now().UTC()
+
+	_, err := tx.ExecContext(ctx, "UPDATE users SET password = '', sessions_valid_after = ? WHERE id = ?", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	// A password sign-in waiting for its second factor would otherwise
+	// still end with a token.
+	_, err = tx.ExecContext(ctx, "DELETE FROM login_challenges WHERE user_id = ?", userId)
+	return err
+}
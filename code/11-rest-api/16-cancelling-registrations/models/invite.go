@@ -0,0 +1,312 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+// EventInvite lets people see and join a private event. A link invite works
+// for anyone holding its token; an email invite is mailed to its address and
+// only the user with that address can register with its token.
+type EventInvite struct {
+	ID      int64
+	EventID int64
+	// Email is empty for link invites.
+	Email string
+	// MaxUses is how many registrations the invite allows, 0 for no limit.
+	MaxUses    int
+	Uses       int
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+var (
+	ErrInviteNotFound = errors.New("Invite not found")
+	// ErrInviteRequired is returned when registering for a private event
+	// without an invite.
+	ErrInviteRequired = errors.New("Event requires an invite")
+	// ErrInvalidInvite covers unknown, revoked, expired and used up invites
+	// as well as invites meant for someone else.
+	ErrInvalidInvite = errors.New("Invite is not valid")
+)
+
+// inviteSignaturePurpose keeps invite signatures apart from other signed
+// values.
+const inviteSignaturePurpose = "event-invite"
+
+// usableInvite is the condition an invite must meet to be used.
+const usableInvite = "revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses = 0 OR uses < max_uses)"
+
+// InviteToken returns the token for the invite. The token is the invite's
+// id signed with the server secret, so it doesn't need to be stored and
+// can't be guessed from other invites.
+func InviteToken(inviteId int64) string {
+	id := strconv.FormatInt(inviteId, 10)
+	return id + "." + utils.Sign(inviteSignaturePurpose, id)
+}
+
+func parseInviteToken(token string) (int64, bool) {
+	id, signature, found := strings.Cut(token, ".")
+
+	if !found || !utils.VerifySignature(inviteSignaturePurpose, id, signature) {
+		return 0, false
+	}
+
+	inviteId, err := strconv.ParseInt(id, 10, 64)
+	return inviteId, err == nil
+}
+
+// CreateInvite creates a link invite, or an email invite if email is set.
+// Email invites are single use unless maxUses says otherwise. A nil
+// expiresAt means the invite doesn't expire.
+func (e Event) CreateInvite(ctx context.Context, createdBy int64, email string, maxUses int, expiresAt *time.Time) (_ *EventInvite, err error) {
+	query := `
+	INSERT INTO event_invites(event_id, email, max_uses, created_by, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+	ctx, end := startOperation(ctx, "Event.CreateInvite", query)
+	defer end(&err)
+
+	invite := EventInvite{
+		EventID:   e.ID,
+		Email:     strings.TrimSpace(email),
+		MaxUses:   maxUses,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	if invite.Email != "" && invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+
+	storedEmail := sql.NullString{String: invite.Email, Valid: invite.Email != ""}
+	result, err := db.Conn(ctx).ExecContext(ctx, query, e.ID, storedEmail, invite.MaxUses, createdBy, invite.CreatedAt, expiresAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invite.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// Invites returns every invite of the event, including revoked ones.
+func (e Event) Invites(ctx context.Context) (invites []EventInvite, err error) {
+	query := `
+	SELECT id, event_id, email, max_uses, uses, created_at, expires_at, revoked_at, last_used_at
+	FROM event_invites WHERE event_id = ? ORDER BY id`
+	ctx, end := startOperation(ctx, "Event.Invites", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, e.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invites = []EventInvite{}
+
+	for rows.Next() {
+		var invite EventInvite
+		var email sql.NullString
+		var expiresAt, revokedAt, lastUsedAt sql.NullTime
+		err := rows.Scan(&invite.ID, &invite.EventID, &email, &invite.MaxUses, &invite.Uses, &invite.CreatedAt, &expiresAt, &revokedAt, &lastUsedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		invite.Email = email.String
+		invite.ExpiresAt = nullTimePtr(expiresAt)
+		invite.RevokedAt = nullTimePtr(revokedAt)
+		invite.LastUsedAt = nullTimePtr(lastUsedAt)
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// RevokeInvite stops the invite from being used. Registrations made with
+// it stay.
+func (e Event) RevokeInvite(ctx context.Context, inviteId int64) (err error) {
+	query := "UPDATE event_invites SET revoked_at = ? WHERE id = ? AND event_id = ? AND revoked_at IS NULL"
+	ctx, end := startOperation(ctx, "Event.RevokeInvite", query)
+	defer end(&err)
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, time.Now().UTC(), inviteId, e.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrInviteNotFound
+	}
+
+	return nil
+}
+
+// VisibleTo reports whether the user (0 for anonymous requests) may see the
+// event. Only private events are restricted: they are visible to their
+// owners and co-hosts, to registered users and to anyone holding a valid
+// invite token. Email invites need their token too: sign up doesn't verify
+// addresses, so an account with the invited email proves nothing.
+func (e Event) VisibleTo(ctx context.Context, userId int64, inviteToken string) (visible bool, err error) {
+	if e.Visibility != VisibilityPrivate {
+		return true, nil
+	}
+
+	query := `
+	SELECT EXISTS(SELECT 1 FROM event_hosts WHERE event_id = ? AND user_id = ?)
+		OR EXISTS(SELECT 1 FROM registrations WHERE event_id = ? AND user_id = ?)`
+	ctx, end := startOperation(ctx, "Event.VisibleTo", query)
+	defer end(&err)
+
+	if inviteId, ok := parseInviteToken(inviteToken); ok {
+		err := db.Conn(ctx).QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM event_invites WHERE id = ? AND event_id = ? AND "+usableInvite+")",
+			inviteId, e.ID, time.Now().UTC()).Scan(&visible)
+
+		if err != nil || visible {
+			return visible, err
+		}
+	}
+
+	if userId == 0 {
+		return false, nil
+	}
+
+	owner, err := e.isOwnedBy(ctx, userId)
+
+	if err != nil || owner {
+		return owner, err
+	}
+
+	err = db.Conn(ctx).QueryRowContext(ctx, query, e.ID, userId, e.ID, userId).Scan(&visible)
+	return visible, err
+}
+
+// GetVisibleEvent fetches the event if the user, or the invite token, may
+// see it. Hidden events are reported as ErrEventNotFound, so their ids
+// don't reveal that they exist.
+func GetVisibleEvent(ctx context.Context, eventId, userId int64, inviteToken string) (*Event, error) {
+	event, err := GetEventByID(ctx, eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := event.VisibleTo(ctx, userId, inviteToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !visible {
+		return nil, ErrEventNotFound
+	}
+
+	return event, nil
+}
+
+// checkInvite applies the invite rules of Register and Reserve: a token
+// must be a valid invite for the event, and private events can't be joined
+// without one unless the user owns or co-hosts them.
+func checkInvite(ctx context.Context, tx *sql.Tx, event Event, userId int64, token string) error {
+	if token == "" {
+		if event.Visibility != VisibilityPrivate {
+			return nil
+		}
+
+		hosting, err := event.isHostedBy(ctx, userId)
+
+		if err != nil || hosting {
+			return err
+		}
+
+		return ErrInviteRequired
+	}
+
+	return useInvite(ctx, tx, event.ID, userId, token)
+}
+
+// useInvite checks that the user may register for the event with the
+// invite token and records the use. An email invite's token only works for
+// the user with that address.
+func useInvite(ctx context.Context, tx *sql.Tx, eventId, userId int64, token string) error {
+	now := time.Now().UTC()
+	inviteId, ok := parseInviteToken(token)
+
+	if !ok {
+		return ErrInvalidInvite
+	}
+
+	var email sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"SELECT email FROM event_invites WHERE id = ? AND event_id = ? AND "+usableInvite,
+		inviteId, eventId, now).Scan(&email)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidInvite
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if email.Valid {
+		var matches bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND email = ? COLLATE NOCASE)", userId, email.String).Scan(&matches)
+
+		if err != nil {
+			return err
+		}
+
+		if !matches {
+			return ErrInvalidInvite
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE event_invites SET uses = uses + 1, last_used_at = ? WHERE id = ?", now, inviteId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO event_invite_uses(invite_id, user_id, used_at) VALUES (?, ?, ?)", inviteId, userId, now)
+	return err
+}
+
+func deleteInvites(ctx context.Context, tx *sql.Tx, eventId int64) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM event_invite_uses WHERE invite_id IN (SELECT id FROM event_invites WHERE event_id = ?)", eventId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM event_invites WHERE event_id = ?", eventId)
+	return err
+}
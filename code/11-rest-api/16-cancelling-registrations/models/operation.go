@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+
+	"example.com/rest-api/db"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("example.com/rest-api/models")
+
+// startOperation bounds ctx by the timeout configured for operation in the
+// db package and starts a client span for it. The returned function records
+// the operation's error, ends the span and releases the timeout; it takes a
+// pointer so it can be deferred before the error is known.
+func startOperation(ctx context.Context, operation, query string) (context.Context, func(*error)) {
+	ctx, cancel := context.WithTimeout(ctx, db.QueryTimeout(operation))
+	ctx, end := startSpan(ctx, operation, query)
+
+	return ctx, func(err *error) {
+		end(err)
+		cancel()
+	}
+}
+
+// startSpan is startOperation without the timeout, for operations that
+// bound each of their queries instead.
+func startSpan(ctx context.Context, operation, query string) (context.Context, func(*error)) {
+	ctx, span := tracer.Start(ctx, "models."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite, semconv.DBStatement(query)),
+	)
+
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+
+		span.End()
+	}
+}
@@ -0,0 +1,404 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+// Organization roles, from most to least privileged. Owners manage the
+// members, admins manage the events of the organization and members can
+// create events for it.
+const (
+	OrgOwner  = "owner"
+	OrgAdmin  = "admin"
+	OrgMember = "member"
+)
+
+// InvitationTTL is how long an invitation to an organization can be
+// accepted.
+const InvitationTTL = 7 * 24 * time.Hour
+
+type Organization struct {
+	ID   int64
+	Name string `binding:"required"`
+}
+
+type OrgMembership struct {
+	UserID int64
+	Email  string
+	Role   string
+}
+
+type Invitation struct {
+	ID        int64
+	OrgID     int64
+	Email     string
+	Role      string
+	ExpiresAt time.Time
+}
+
+var (
+	ErrOrganizationNotFound = errors.New("Organization not found")
+	ErrNotOrgMember         = errors.New("User is not a member of the organization")
+	ErrInvalidOrgRole       = errors.New("Invalid organization role")
+	// ErrLastOwner protects organizations from ending up without an owner.
+	ErrLastOwner             = errors.New("Organization needs at least one owner")
+	ErrInvitationNotFound    = errors.New("Invitation not found or expired")
+	ErrInvitationEmailDiffer = errors.New("Invitation was sent to a different email address")
+)
+
+// orgRoleRanks orders the roles so they can be compared.
+var orgRoleRanks = map[string]int{OrgMember: 1, OrgAdmin: 2, OrgOwner: 3}
+
+// ValidOrgRole reports whether role is one of the organization roles.
+func ValidOrgRole(role string) bool {
+	return orgRoleRanks[role] > 0
+}
+
+// OrgRoleAtLeast reports whether role grants at least the rights of min. The
+// empty role of a non-member grants nothing.
+func OrgRoleAtLeast(role, min string) bool {
+	return role != "" && orgRoleRanks[role] >= orgRoleRanks[min]
+}
+
+// Save creates the organization with ownerId as its first owner.
+func (o *Organization) Save(ctx context.Context, ownerId int64) (err error) {
+	query := "INSERT INTO organizations(name) VALUES (?)"
+	ctx, end := startOperation(ctx, "Organization.Save", query)
+	defer end(&err)
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, o.Name)
+
+		if err != nil {
+			return err
+		}
+
+		o.ID, err = result.LastInsertId()
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO org_members(org_id, user_id, role) VALUES (?, ?, ?)", o.ID, ownerId, OrgOwner)
+		return err
+	})
+}
+
+func GetOrganizationByID(ctx context.Context, id int64) (_ *Organization, err error) {
+	query := "SELECT id, name FROM organizations WHERE id = ?"
+	ctx, end := startOperation(ctx, "GetOrganizationByID", query)
+	defer end(&err)
+
+	var org Organization
+	err = db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&org.ID, &org.Name)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// GetOrganizationsForUser returns the organizations the user is a member of.
+func GetOrganizationsForUser(ctx context.Context, userId int64) (orgs []Organization, err error) {
+	query := `
+	SELECT organizations.id, organizations.name FROM organizations
+	JOIN org_members ON org_members.org_id = organizations.id
+	WHERE org_members.user_id = ?
+	ORDER BY organizations.name`
+	ctx, end := startOperation(ctx, "GetOrganizationsForUser", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	orgs = []Organization{}
+
+	for rows.Next() {
+		var org Organization
+		err := rows.Scan(&org.ID, &org.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+// OrgRole returns the role of the user in the organization, or "" if they
+// are not a member.
+func OrgRole(ctx context.Context, orgId, userId int64) (role string, err error) {
+	query := "SELECT role FROM org_members WHERE org_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "OrgRole", query)
+	defer end(&err)
+
+	err = db.Conn(ctx).QueryRowContext(ctx, query, orgId, userId).Scan(&role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// orgRoles returns the user's role in each of the organizations they are a
+// member of.
+func orgRoles(ctx context.Context, userId int64, orgIds []int64) (roles map[int64]string, err error) {
+	query := "SELECT org_id, role FROM org_members WHERE user_id = ? AND org_id IN (%s)"
+	ctx, end := startOperation(ctx, "orgRoles", query)
+	defer end(&err)
+
+	roles = map[int64]string{}
+
+	err = eachIDBatch(orgIds, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), append([]any{userId}, args...)...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var orgId int64
+			var role string
+			err := rows.Scan(&orgId, &role)
+
+			if err != nil {
+				return err
+			}
+
+			roles[orgId] = role
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func GetOrgMembers(ctx context.Context, orgId int64) (members []OrgMembership, err error) {
+	query := `
+	SELECT users.id, users.email, org_members.role FROM org_members
+	JOIN users ON users.id = org_members.user_id
+	WHERE org_members.org_id = ?
+	ORDER BY users.email`
+	ctx, end := startOperation(ctx, "GetOrgMembers", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, orgId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members = []OrgMembership{}
+
+	for rows.Next() {
+		var member OrgMembership
+		err := rows.Scan(&member.UserID, &member.Email, &member.Role)
+
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetOrgRole changes the role of a member.
+func SetOrgRole(ctx context.Context, orgId, userId int64, role string) (err error) {
+	query := "UPDATE org_members SET role = ? WHERE org_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "SetOrgRole", query)
+	defer end(&err)
+
+	if !ValidOrgRole(role) {
+		return ErrInvalidOrgRole
+	}
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, role, orgId, userId)
+
+		if err != nil {
+			return err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotOrgMember
+		}
+
+		return checkOrgHasOwner(ctx, tx, orgId)
+	})
+}
+
+// RemoveOrgMember takes the user out of the organization. The events they
+// created for it stay with the organization.
+func RemoveOrgMember(ctx context.Context, orgId, userId int64) (err error) {
+	query := "DELETE FROM org_members WHERE org_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "RemoveOrgMember", query)
+	defer end(&err)
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, orgId, userId)
+
+		if err != nil {
+			return err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotOrgMember
+		}
+
+		return checkOrgHasOwner(ctx, tx, orgId)
+	})
+}
+
+func checkOrgHasOwner(ctx context.Context, tx *sql.Tx, orgId int64) error {
+	var owners int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM org_members WHERE org_id = ? AND role = ?", orgId, OrgOwner).Scan(&owners)
+
+	if err != nil {
+		return err
+	}
+
+	if owners == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// InviteToOrg creates an invitation for email and returns the token that
+// accepts it. Only a hash of the token is stored.
+func InviteToOrg(ctx context.Context, orgId int64, email, role string, invitedBy int64) (_ *Invitation, token string, err error) {
+	query := `
+	INSERT INTO org_invitations(org_id, email, role, token_hash, invited_by, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+	ctx, end := startOperation(ctx, "InviteToOrg", query)
+	defer end(&err)
+
+	if !ValidOrgRole(role) {
+		return nil, "", ErrInvalidOrgRole
+	}
+
+	token, hash, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation := Invitation{
+		OrgID:     orgId,
+		Email:     strings.TrimSpace(email),
+		Role:      role,
+		ExpiresAt: time.Now().UTC().Add(InvitationTTL),
+	}
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, orgId, invitation.Email, role, hash, invitedBy, invitation.ExpiresAt)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &invitation, token, nil
+}
+
+// AcceptInvitation makes the user a member of the organization they were
+// invited to. The invitation must have been sent to the user's email
+// address and can only be used once. Users that are already members keep
+// their role if it is higher.
+func AcceptInvitation(ctx context.Context, token string, userId int64) (_ *Invitation, err error) {
+	query := `
+	SELECT id, org_id, email, role, expires_at FROM org_invitations
+	WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > ?`
+	ctx, end := startOperation(ctx, "AcceptInvitation", query)
+	defer end(&err)
+
+	var invitation Invitation
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now().UTC()
+		err := tx.QueryRowContext(ctx, query, utils.HashRandomToken(token), now).
+			Scan(&invitation.ID, &invitation.OrgID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvitationNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var email string
+		err = tx.QueryRowContext(ctx, "SELECT email FROM users WHERE id = ?", userId).Scan(&email)
+
+		if err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(email, invitation.Email) {
+			return ErrInvitationEmailDiffer
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE org_invitations SET accepted_at = ? WHERE id = ?", now, invitation.ID)
+
+		if err != nil {
+			return err
+		}
+
+		var role string
+		err = tx.QueryRowContext(ctx, "SELECT role FROM org_members WHERE org_id = ? AND user_id = ?", invitation.OrgID, userId).Scan(&role)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.ExecContext(ctx, "INSERT INTO org_members(org_id, user_id, role) VALUES (?, ?, ?)", invitation.OrgID, userId, invitation.Role)
+			return err
+		}
+
+		if err != nil || OrgRoleAtLeast(role, invitation.Role) {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE org_members SET role = ? WHERE org_id = ? AND user_id = ?", invitation.Role, invitation.OrgID, userId)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/rest-api/db"
+)
+
+// Permission is something a user may be allowed to do with an event.
+type Permission string
+
+// Permissions that can be granted to co-hosts.
+const (
+	PermEditEvent     Permission = "edit_details"
+	PermViewAttendees Permission = "view_attendees"
+	PermCheckIn       Permission = "check_in"
+)
+
+// Permissions reserved for the owners of an event.
+const (
+	PermDeleteEvent   Permission = "delete"
+	PermManageHosts   Permission = "manage_hosts"
+	PermManageInvites Permission = "manage_invites"
+)
+
+// HostPermissions lists the permissions a co-host can be given.
+var HostPermissions = []Permission{PermEditEvent, PermViewAttendees, PermCheckIn}
+
+func (p Permission) grantable() bool {
+	for _, permission := range HostPermissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can reports whether the user has the permission on the event. This is the
+// one place deciding who may do what with an event:
+//
+//   - The owner (event.UserID) may do everything. For an organization's
+//     event that holds only while they stay a member, and the
+//     organization's owners and admins count as owners too.
+//   - Co-hosts may do what they were granted, see HostPermissions.
+func (e Event) Can(ctx context.Context, userId int64, permission Permission) (bool, error) {
+	owner, err := e.isOwnedBy(ctx, userId)
+
+	if err != nil || owner {
+		return owner, err
+	}
+
+	if !permission.grantable() {
+		return false, nil
+	}
+
+	granted, err := hostPermissions(ctx, e.ID, userId)
+
+	if err != nil {
+		return false, err
+	}
+
+	return hasPermission(granted, permission), nil
+}
+
+// CanEach is Can for many events at once. It returns the ids of the events
+// the user has the permission on, reading the user's organization roles
+// and co-host grants with one query each rather than per event.
+func CanEach(ctx context.Context, userId int64, permission Permission, events []Event) (allowed map[int64]bool, err error) {
+	query := "SELECT event_id, permissions FROM event_hosts WHERE user_id = ? AND event_id IN (%s)"
+	ctx, end := startOperation(ctx, "CanEach", query)
+	defer end(&err)
+
+	var orgIds, eventIds []int64
+
+	for _, event := range events {
+		if event.OrgID != 0 {
+			orgIds = append(orgIds, event.OrgID)
+		}
+
+		eventIds = append(eventIds, event.ID)
+	}
+
+	roles, err := orgRoles(ctx, userId, orgIds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	granted := map[int64][]Permission{}
+
+	if permission.grantable() {
+		err = eachIDBatch(eventIds, func(in string, args []any) error {
+			rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), append([]any{userId}, args...)...)
+
+			if err != nil {
+				return err
+			}
+
+			defer rows.Close()
+
+			for rows.Next() {
+				var eventId int64
+				var stored string
+				err := rows.Scan(&eventId, &stored)
+
+				if err != nil {
+					return err
+				}
+
+				granted[eventId] = parsePermissions(stored)
+			}
+
+			return rows.Err()
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	allowed = make(map[int64]bool, len(events))
+
+	for _, event := range events {
+		if event.ownedWithRole(userId, roles[event.OrgID]) || hasPermission(granted[event.ID], permission) {
+			allowed[event.ID] = true
+		}
+	}
+
+	return allowed, nil
+}
+
+func hasPermission(granted []Permission, permission Permission) bool {
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrNotAllowed is returned by GetEventWith when the user can see the
+// event but lacks the permission.
+var ErrNotAllowed = errors.New("Not allowed")
+
+// GetEventWith fetches the event and checks that the user has the
+// permission on it. An empty permission skips the check. Events the user
+// can't see are reported as ErrEventNotFound, like missing ones, and
+// ErrNotAllowed is returned if the permission check fails.
+func GetEventWith(ctx context.Context, eventId, userId int64, permission Permission) (*Event, error) {
+	event, err := GetVisibleEvent(ctx, eventId, userId, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if permission == "" {
+		return event, nil
+	}
+
+	allowed, err := event.Can(ctx, userId, permission)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, ErrNotAllowed
+	}
+
+	return event, nil
+}
+
+// CheckCreator returns ErrNotOrgMember if the event belongs to an
+// organization its creator (event.UserID) is not a member of.
+func (e Event) CheckCreator(ctx context.Context) error {
+	if e.OrgID == 0 {
+		return nil
+	}
+
+	role, err := OrgRole(ctx, e.OrgID, e.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		return ErrNotOrgMember
+	}
+
+	return nil
+}
+
+func (e Event) isOwnedBy(ctx context.Context, userId int64) (bool, error) {
+	if e.OrgID == 0 {
+		return e.UserID == userId, nil
+	}
+
+	role, err := OrgRole(ctx, e.OrgID, userId)
+
+	if err != nil {
+		return false, err
+	}
+
+	return e.ownedWithRole(userId, role), nil
+}
+
+// ownedWithRole is isOwnedBy for a user with the role in the event's
+// organization.
+func (e Event) ownedWithRole(userId int64, role string) bool {
+	if e.OrgID == 0 {
+		return e.UserID == userId
+	}
+
+	return OrgRoleAtLeast(role, OrgAdmin) || (role != "" && e.UserID == userId)
+}
+
+// isHostedBy reports whether the user owns or co-hosts the event.
+func (e Event) isHostedBy(ctx context.Context, userId int64) (bool, error) {
+	owner, err := e.isOwnedBy(ctx, userId)
+
+	if err != nil || owner {
+		return owner, err
+	}
+
+	permissions, err := hostPermissions(ctx, e.ID, userId)
+	return permissions != nil, err
+}
+
+// hostPermissions returns what the user was granted as a co-host of the
+// event, or nil.
+func hostPermissions(ctx context.Context, eventId, userId int64) (permissions []Permission, err error) {
+	query := "SELECT permissions FROM event_hosts WHERE event_id = ? AND user_id = ?"
+	ctx, end := startOperation(ctx, "hostPermissions", query)
+	defer end(&err)
+
+	var stored string
+	err = db.Conn(ctx).QueryRowContext(ctx, query, eventId, userId).Scan(&stored)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePermissions(stored), nil
+}
+
+func parsePermissions(stored string) []Permission {
+	permissions := []Permission{}
+
+	for _, p := range strings.Split(stored, ",") {
+		if p != "" {
+			permissions = append(permissions, Permission(p))
+		}
+	}
+
+	return permissions
+}
+
+func formatPermissions(permissions []Permission) string {
+	names := make([]string, len(permissions))
+
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEventPermissions(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	err := event.SetHost(ctx, 2, []Permission{PermViewAttendees, PermViewAttendees})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		user       int64
+		permission Permission
+		want       bool
+	}{
+		{1, PermEditEvent, true},
+		{1, PermDeleteEvent, true},
+		{1, PermManageHosts, true},
+		{2, PermViewAttendees, true},
+		{2, PermEditEvent, false},
+		{2, PermCheckIn, false},
+		{2, PermDeleteEvent, false},
+		{3, PermViewAttendees, false},
+	} {
+		got, err := event.Can(ctx, test.user, test.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("user %d, %s: got %v, want %v", test.user, test.permission, got, test.want)
+		}
+	}
+
+	if err := event.SetHost(ctx, 2, []Permission{PermDeleteEvent}); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("granting delete: got error %v, want ErrInvalidPermission", err)
+	}
+
+	if err := event.SetHost(ctx, 1, []Permission{PermCheckIn}); !errors.Is(err, ErrHostIsOwner) {
+		t.Errorf("owner as co-host: got error %v, want ErrHostIsOwner", err)
+	}
+}
+
+func TestGetEventWith(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	if _, err := GetEventWith(ctx, event.ID, 1, PermDeleteEvent); err != nil {
+		t.Errorf("owner: got error %v", err)
+	}
+
+	if _, err := GetEventWith(ctx, event.ID, 2, PermEditEvent); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("stranger: got error %v, want ErrNotAllowed", err)
+	}
+
+	if _, err := GetEventWith(ctx, event.ID, 2, ""); err != nil {
+		t.Errorf("no permission needed: got error %v", err)
+	}
+
+	if _, err := GetEventWith(ctx, event.ID+1, 1, PermEditEvent); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("missing event: got error %v, want ErrEventNotFound", err)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	err := event.SetHost(ctx, 2, []Permission{PermEditEvent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = event.TransferOwnership(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetEventByID(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.UserID != 2 {
+		t.Errorf("owner is %d, want 2", stored.UserID)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM event_hosts WHERE event_id = ?", event.ID); n != 0 {
+		t.Errorf("new owner is still listed as co-host")
+	}
+
+	if ok, _ := stored.Can(ctx, 1, PermEditEvent); ok {
+		t.Error("previous owner can still edit the event")
+	}
+}
+
+func TestGetVisibleEvent(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	event.Visibility = VisibilityPrivate
+	err := event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetVisibleEvent(ctx, event.ID, 1, ""); err != nil {
+		t.Errorf("owner: got error %v", err)
+	}
+
+	if _, err := GetVisibleEvent(ctx, event.ID, 2, ""); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("stranger: got error %v, want ErrEventNotFound", err)
+	}
+
+	if _, err := GetVisibleEvent(ctx, event.ID+1, 1, ""); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("missing event: got error %v, want ErrEventNotFound", err)
+	}
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/jobs"
+	"example.com/rest-api/notify"
+)
+
+// ReminderJob is the job kind of event reminders.
+const ReminderJob = "event.reminder"
+
+// ReminderOffsets are how long before an event its attendees are reminded.
+var ReminderOffsets = []time.Duration{24 * time.Hour, time.Hour}
+
+type reminderPayload struct {
+	EventID int64         `json:"eventId"`
+	Before  time.Duration `json:"before"`
+	// DateTime is when the event started as the reminder was scheduled.
+	// Reminders for an older DateTime are stale and skipped.
+	DateTime time.Time `json:"dateTime"`
+}
+
+func reminderKey(eventId int64, before time.Duration) string {
+	return fmt.Sprintf("event-reminder:%d:%s", eventId, before)
+}
+
+// scheduleReminders queues the reminders for the event, replacing the ones
+// queued for its previous DateTime. Reminders that would be due already are
+// dropped, so a late change doesn't remind anyone twice.
+func scheduleReminders(ctx context.Context, eventId int64, dateTime time.Time) error {
+	now := time.Now()
+
+	for _, before := range ReminderOffsets {
+		runAt := dateTime.Add(-before)
+		key := reminderKey(eventId, before)
+
+		if !runAt.After(now) {
+			err := jobs.Cancel(ctx, key)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		payload := reminderPayload{EventID: eventId, Before: before, DateTime: dateTime.UTC()}
+		_, err := jobs.EnqueueJSON(ctx, ReminderJob, payload, runAt, key)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cancelReminders(ctx context.Context, eventId int64) error {
+	for _, before := range ReminderOffsets {
+		err := jobs.Cancel(ctx, reminderKey(eventId, before))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReminderHandler returns the job handler sending event reminders to the
+// attendees registered when it runs. Each delivery is recorded, so a job
+// retried after a failed delivery only reminds the attendees it missed.
+func ReminderHandler(notifier notify.Notifier) jobs.Handler {
+	return func(ctx context.Context, job jobs.Job) error {
+		var payload reminderPayload
+		err := json.Unmarshal(job.Payload, &payload)
+
+		if err != nil {
+			return err
+		}
+
+		event, err := GetEventByID(ctx, payload.EventID)
+
+		if errors.Is(err, ErrEventNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !event.DateTime.Equal(payload.DateTime) {
+			return nil
+		}
+
+		recipients, err := unremindedAttendees(ctx, payload)
+
+		if err != nil {
+			return err
+		}
+
+		subject := fmt.Sprintf("Reminder: %s starts in %s", event.Name, formatLead(payload.Before))
+		body := fmt.Sprintf("%s starts on %s at %s.\n\n%s\n",
+			event.Name, event.DateTime.UTC().Format("Monday, 2 January 2006 at 15:04 MST"), event.Location, event.Description)
+
+		notified := map[int64]bool{}
+
+		for _, recipient := range recipients {
+			if !notified[recipient.UserID] {
+				err := notifier.Notify(ctx, notify.Notification{UserID: recipient.UserID, Email: recipient.Email, Subject: subject, Body: body})
+
+				if err != nil {
+					return fmt.Errorf("notifying user %d: %w", recipient.UserID, err)
+				}
+
+				notified[recipient.UserID] = true
+			}
+
+			err := recordReminder(ctx, recipient.RegistrationID, payload)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+type reminderRecipient struct {
+	RegistrationID int64
+	UserID         int64
+	Email          string
+}
+
+// unremindedAttendees returns the registrations of the event that haven't
+// been sent the reminder yet.
+func unremindedAttendees(ctx context.Context, payload reminderPayload) (recipients []reminderRecipient, err error) {
+	query := `
+	SELECT registrations.id, users.id, users.email FROM registrations
+	JOIN users ON users.id = registrations.user_id
+	WHERE registrations.event_id = ? AND NOT EXISTS(
+		SELECT 1 FROM reminder_deliveries
+		WHERE registration_id = registrations.id AND lead = ? AND starts_at = ?
+	)
+	ORDER BY registrations.id`
+	ctx, end := startOperation(ctx, "unremindedAttendees", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, payload.EventID, int64(payload.Before), payload.DateTime.Unix())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipient reminderRecipient
+		err := rows.Scan(&recipient.RegistrationID, &recipient.UserID, &recipient.Email)
+
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+// recordReminder notes that the registration was sent the reminder.
+func recordReminder(ctx context.Context, registrationId int64, payload reminderPayload) (err error) {
+	query := "INSERT OR IGNORE INTO reminder_deliveries(registration_id, lead, starts_at, sent_at) VALUES (?, ?, ?, ?)"
+	ctx, end := startOperation(ctx, "recordReminder", query)
+	defer end(&err)
+
+	_, err = db.Conn(ctx).ExecContext(ctx, query, registrationId, int64(payload.Before), payload.DateTime.Unix(), time.Now().UTC())
+	return err
+}
+
+func formatLead(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+}
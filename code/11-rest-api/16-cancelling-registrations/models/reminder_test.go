@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/jobs"
+	"example.com/rest-api/notify"
+)
+
+func reminderRunAt(t *testing.T, eventId int64, before time.Duration) (time.Time, bool) {
+	t.Helper()
+
+	job, err := jobs.GetByKey(context.Background(), reminderKey(eventId, before))
+
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		return time.Time{}, false
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return job.RunAt, true
+}
+
+func TestRemindersFollowDateTime(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	if n := count(t, "SELECT COUNT(*) FROM jobs"); n != 0 {
+		t.Errorf("got %d jobs for a past event, want none", n)
+	}
+
+	event.DateTime = time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	err := event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, before := range ReminderOffsets {
+		if runAt, ok := reminderRunAt(t, event.ID, before); !ok || !runAt.Equal(event.DateTime.Add(-before)) {
+			t.Errorf("%s reminder: got %v, %v, want %v", before, runAt, ok, event.DateTime.Add(-before))
+		}
+	}
+
+	// Only the 1 hour reminder is still ahead.
+	event.DateTime = time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+
+	err = event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := reminderRunAt(t, event.ID, 24*time.Hour); ok {
+		t.Error("24 hour reminder is still scheduled")
+	}
+
+	if runAt, ok := reminderRunAt(t, event.ID, time.Hour); !ok || !runAt.Equal(event.DateTime.Add(-time.Hour)) {
+		t.Errorf("1 hour reminder: got %v, %v, want %v", runAt, ok, event.DateTime.Add(-time.Hour))
+	}
+
+	err = event.Delete(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM jobs"); n != 0 {
+		t.Errorf("got %d jobs for a deleted event, want none", n)
+	}
+}
+
+func TestRemindersStayWhenOtherFieldsChange(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+
+	event.DateTime = time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	err := event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := jobs.GetByKey(ctx, reminderKey(event.ID, 24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The reminder was sent.
+	_, err = db.DB.Exec("UPDATE jobs SET status = ? WHERE id = ?", jobs.StatusDone, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event.Name = "Renamed"
+
+	err = event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err := jobs.Get(ctx, job.ID); err != nil || job.Status != jobs.StatusDone {
+		t.Errorf("got %+v, %v, want the sent reminder left done", job, err)
+	}
+}
+
+func TestReminderHandler(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+	recorder := &notify.Recorder{}
+	handler := ReminderHandler(recorder)
+
+	err := event.Register(ctx, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := jobs.Job{Payload: []byte(`{"eventId":1,"before":3600000000000,"dateTime":"2024-01-01T15:30:00Z"}`)}
+
+	err = handler(ctx, stale)
+	if err != nil || len(recorder.Notifications()) != 0 {
+		t.Errorf("stale reminder: got %v and %d notifications, want none", err, len(recorder.Notifications()))
+	}
+
+	current := jobs.Job{Payload: []byte(`{"eventId":1,"before":3600000000000,"dateTime":"2025-01-01T15:30:00Z"}`)}
+
+	err = handler(ctx, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := recorder.Notifications()
+
+	if len(notifications) != 1 || notifications[0].Email != "guest@example.com" || notifications[0].Subject != "Reminder: Meetup starts in 1 hour" {
+		t.Errorf("got notifications %+v, want one 1 hour reminder for the guest", notifications)
+	}
+}
+
+// failingNotifier fails for one email address until fail is cleared.
+type failingNotifier struct {
+	notify.Recorder
+	email string
+	fail  bool
+}
+
+func (n *failingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	if n.fail && notification.Email == n.email {
+		return errors.New("mail server down")
+	}
+
+	return n.Recorder.Notify(ctx, notification)
+}
+
+func TestRetriedReminderSkipsNotifiedAttendees(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+	notifier := &failingNotifier{email: "owner@example.com", fail: true}
+	handler := ReminderHandler(notifier)
+
+	for _, userId := range []int64{2, 1} {
+		err := event.Register(ctx, userId, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := jobs.Job{Payload: []byte(`{"eventId":1,"before":3600000000000,"dateTime":"2025-01-01T15:30:00Z"}`)}
+
+	if err := handler(ctx, job); err == nil {
+		t.Fatal("expected the failed delivery to fail the job")
+	}
+
+	notifier.fail = false
+
+	err := handler(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := notifier.Notifications()
+
+	if len(notifications) != 2 || notifications[0].Email != "guest@example.com" || notifications[1].Email != "owner@example.com" {
+		t.Errorf("got notifications %+v, want the guest and then the owner once each", notifications)
+	}
+}
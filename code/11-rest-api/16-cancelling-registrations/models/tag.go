@@ -0,0 +1,344 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+type Tag struct {
+	ID   int64
+	Name string `binding:"required"`
+	// Category groups tags for browsing, e.g. "format" for "online".
+	Category string
+}
+
+// TagCount is a facet: how many of the matching events have the tag.
+type TagCount struct {
+	Name     string
+	Category string
+	Count    int
+}
+
+var ErrTagNotFound = errors.New("Tag not found")
+
+var ErrTagExists = errors.New("Tag already exists")
+
+// ErrInvalidTag is returned by Save and Update for a tag whose name is
+// empty once trimmed.
+var ErrInvalidTag = errors.New("Tag name is required")
+
+// ErrUnknownTag is returned when an event is given a tag that doesn't exist.
+var ErrUnknownTag = errors.New("Unknown tag")
+
+// normalizeTags lowercases and trims tag names and drops duplicates and
+// empty names. It keeps nil as nil.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// eachIDBatch calls fn with the placeholders and arguments for an IN list
+// of at most idBatchSize of the ids at a time.
+func eachIDBatch(ids []int64, fn func(in string, args []any) error) error {
+	for start := 0; start < len(ids); start += idBatchSize {
+		batch := ids[start:min(start+idBatchSize, len(ids))]
+		args := make([]any, len(batch))
+
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		err := fn(placeholders(len(batch)), args)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Tag) Save(ctx context.Context) (err error) {
+	query := "INSERT INTO tags(name, category) VALUES (?, ?)"
+	ctx, end := startOperation(ctx, "Tag.Save", query)
+	defer end(&err)
+
+	t.Name = strings.ToLower(strings.TrimSpace(t.Name))
+
+	if t.Name == "" {
+		return ErrInvalidTag
+	}
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, t.Name, t.Category)
+
+	if db.IsUniqueViolation(err) {
+		return ErrTagExists
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+func GetAllTags(ctx context.Context) (tags []Tag, err error) {
+	query := "SELECT id, name, category FROM tags ORDER BY category, name"
+	ctx, end := startOperation(ctx, "GetAllTags", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags = []Tag{}
+
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.ID, &tag.Name, &tag.Category)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func GetTagByID(ctx context.Context, id int64) (_ *Tag, err error) {
+	query := "SELECT id, name, category FROM tags WHERE id = ?"
+	ctx, end := startOperation(ctx, "GetTagByID", query)
+	defer end(&err)
+
+	var tag Tag
+	err = db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&tag.ID, &tag.Name, &tag.Category)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// Update renames or recategorizes the tag. Events carrying it count as
+// modified.
+func (t *Tag) Update(ctx context.Context) (err error) {
+	query := "UPDATE tags SET name = ?, category = ? WHERE id = ?"
+	ctx, end := startOperation(ctx, "Tag.Update", query)
+	defer end(&err)
+	defer purgeEventCache(ctx)
+
+	t.Name = strings.ToLower(strings.TrimSpace(t.Name))
+
+	if t.Name == "" {
+		return ErrInvalidTag
+	}
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, t.Name, t.Category, t.ID)
+
+		if db.IsUniqueViolation(err) {
+			return ErrTagExists
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrTagNotFound
+		}
+
+		return touchTaggedEvents(ctx, tx, t.ID)
+	})
+}
+
+// Delete removes the tag from all events and deletes it.
+func (t Tag) Delete(ctx context.Context) (err error) {
+	query := "DELETE FROM tags WHERE id = ?"
+	ctx, end := startOperation(ctx, "Tag.Delete", query)
+	defer end(&err)
+	defer purgeEventCache(ctx)
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := touchTaggedEvents(ctx, tx, t.ID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM event_tags WHERE tag_id = ?", t.ID)
+
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, query, t.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrTagNotFound
+		}
+
+		return nil
+	})
+}
+
+// touchTaggedEvents bumps updated_at of every event carrying the tag, so
+// HTTP caches notice the changed tag list.
+func touchTaggedEvents(ctx context.Context, tx *sql.Tx, tagId int64) error {
+	_, err := tx.ExecContext(ctx,
+		"UPDATE events SET updated_at = ? WHERE id IN (SELECT event_id FROM event_tags WHERE tag_id = ?)",
+		time.Now().UTC(), tagId)
+	return err
+}
+
+// setEventTags replaces the tags of an event and returns the normalized
+// names. All tags must exist.
+func setEventTags(ctx context.Context, tx *sql.Tx, eventId int64, tags []string) ([]string, error) {
+	tags = normalizeTags(tags)
+
+	_, err := tx.ExecContext(ctx, "DELETE FROM event_tags WHERE event_id = ?", eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO event_tags(event_id, tag_id) SELECT ?, id FROM tags WHERE name = ?",
+			eventId, tag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTag, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// idBatchSize keeps the IN lists of eachIDBatch well below SQLite's limit
+// on query parameters.
+const idBatchSize = 500
+
+// loadTags fills in the Tags of events with one query per batch of events.
+func loadTags(ctx context.Context, events []Event) error {
+	index := make(map[int64]int, len(events))
+	ids := make([]int64, len(events))
+
+	for i := range events {
+		events[i].Tags = []string{}
+		index[events[i].ID] = i
+		ids[i] = events[i].ID
+	}
+
+	return eachIDBatch(ids, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, `
+		SELECT event_tags.event_id, tags.name FROM event_tags
+		JOIN tags ON tags.id = event_tags.tag_id
+		WHERE event_tags.event_id IN (`+in+`)
+		ORDER BY tags.name`, args...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var eventId int64
+			var name string
+			err := rows.Scan(&eventId, &name)
+
+			if err != nil {
+				return err
+			}
+
+			i := index[eventId]
+			events[i].Tags = append(events[i].Tags, name)
+		}
+
+		return rows.Err()
+	})
+}
+
+// TagFacets counts, for every tag, how many events matching filter have it.
+func TagFacets(ctx context.Context, filter EventFilter) (facets []TagCount, err error) {
+	where, args := filter.where()
+	query := `
+	SELECT tags.name, tags.category, COUNT(*) FROM event_tags
+	JOIN tags ON tags.id = event_tags.tag_id
+	WHERE event_tags.event_id IN (SELECT events.id FROM events` + where + `)
+	GROUP BY tags.id
+	ORDER BY COUNT(*) DESC, tags.name`
+	ctx, end := startOperation(ctx, "TagFacets", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	facets = []TagCount{}
+
+	for rows.Next() {
+		var facet TagCount
+		err := rows.Scan(&facet.Name, &facet.Category, &facet.Count)
+
+		if err != nil {
+			return nil, err
+		}
+
+		facets = append(facets, facet)
+	}
+
+	return facets, rows.Err()
+}
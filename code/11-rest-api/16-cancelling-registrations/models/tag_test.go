@@ -0,0 +1,195 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+)
+
+func saveTags(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		tag := Tag{Name: name}
+		err := tag.Save(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEventTags(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+	saveTags(t, "go", "online")
+
+	event.Tags = []string{" Go", "online", "go"}
+	err := event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetEventByID(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"go", "online"}; !reflect.DeepEqual(stored.Tags, want) {
+		t.Errorf("got tags %v, want %v", stored.Tags, want)
+	}
+
+	event.Tags = nil
+	err = event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM event_tags WHERE event_id = ?", event.ID); n != 2 {
+		t.Errorf("update without tags left %d tags, want 2", n)
+	}
+
+	event.Tags = []string{"go", "unknown"}
+	err = event.Update(ctx)
+	if !errors.Is(err, ErrUnknownTag) {
+		t.Errorf("got error %v, want ErrUnknownTag", err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM event_tags WHERE event_id = ?", event.ID); n != 2 {
+		t.Errorf("failed update left %d tags, want 2", n)
+	}
+}
+
+func TestFindEventsByTags(t *testing.T) {
+	first := setupEvent(t)
+	ctx := context.Background()
+	saveTags(t, "go", "online", "berlin")
+
+	second := first
+	second.Tags = []string{"go", "berlin"}
+	err := second.Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Tags = []string{"go", "online"}
+	err = first.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		tags []string
+		want []int64
+	}{
+		{nil, []int64{first.ID, second.ID}},
+		{[]string{"go"}, []int64{first.ID, second.ID}},
+		{[]string{"go", "Online"}, []int64{first.ID}},
+		{[]string{"online", "berlin"}, nil},
+	} {
+		events, err := FindEvents(ctx, EventFilter{Tags: test.tags})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int64
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("tags %v: got events %v, want %v", test.tags, ids, test.want)
+		}
+	}
+
+	facets, err := TagFacets(ctx, EventFilter{Tags: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TagCount{{Name: "go", Count: 2}, {Name: "berlin", Count: 1}, {Name: "online", Count: 1}}
+	if !reflect.DeepEqual(facets, want) {
+		t.Errorf("got facets %v, want %v", facets, want)
+	}
+}
+
+func TestDeleteTagRemovesItFromEvents(t *testing.T) {
+	event := setupEvent(t)
+	ctx := context.Background()
+	saveTags(t, "go")
+
+	event.Tags = []string{"go"}
+	err := event.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Tag{ID: 1}.Delete(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetEventByID(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored.Tags) != 0 {
+		t.Errorf("deleted tag is still on the event: %v", stored.Tags)
+	}
+
+	err = Tag{ID: 1}.Delete(ctx)
+	if !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("deleting twice: got error %v, want ErrTagNotFound", err)
+	}
+}
+
+func TestBlankTagName(t *testing.T) {
+	setupEvent(t)
+	ctx := context.Background()
+	saveTags(t, "go")
+
+	if err := (&Tag{Name: "  "}).Save(ctx); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("save: got error %v, want %v", err, ErrInvalidTag)
+	}
+
+	if err := (&Tag{ID: 1, Name: " "}).Update(ctx); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("update: got error %v, want %v", err, ErrInvalidTag)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM tags WHERE name = ''"); n != 0 {
+		t.Errorf("got %d blank tags, want none", n)
+	}
+}
+
+func TestTagWritesChangeEvents(t *testing.T) {
+	setupEvent(t)
+	ctx := context.Background()
+	saveTags(t, "go")
+	past := time.Now().Add(-time.Hour).UTC()
+
+	for _, write := range []struct {
+		name string
+		run  func() error
+	}{
+		{"rename", func() error { return (&Tag{ID: 1, Name: "golang"}).Update(ctx) }},
+		{"delete", func() error { return Tag{ID: 1}.Delete(ctx) }},
+	} {
+		_, err := db.DB.Exec("UPDATE collection_changes SET changed_at = ? WHERE name = 'events'", past)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = write.run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if changedAt, err := EventsChangedAt(ctx); err != nil || !changedAt.After(past) {
+			t.Errorf("%s: got %v, %v, want a change after %v", write.name, changedAt, err, past)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/logging"
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/payments"
+)
+
+// TicketType is a kind of ticket sold for an event. Events with ticket
+// types can only be joined by ordering a ticket.
+type TicketType struct {
+	ID      int64
+	EventID int64
+	Name    string `binding:"required"`
+	// Price is in the minor unit of Currency, e.g. cents. Tickets with a
+	// price of 0 are confirmed without payment.
+	Price    int64  `binding:"min=0"`
+	Currency string `binding:"required,len=3"`
+	Quantity int    `binding:"required,min=1"`
+	// Available is how many tickets are neither sold nor on hold.
+	Available int
+}
+
+// Order is one ticket bought by a user. It goes from reserved to pending
+// once checkout starts and to paid when the provider confirms the payment.
+// Unpaid orders hold their ticket until ExpiresAt. A payment that can't be
+// honoured is refund_pending until the provider accepts the refund.
+type Order struct {
+	ID           int64
+	EventID      int64
+	TicketTypeID int64
+	UserID       int64
+	Status       string
+	Amount       int64
+	Currency     string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// CheckoutURL is where the user pays. It is only set by StartCheckout.
+	CheckoutURL string `json:",omitempty"`
+}
+
+// Order statuses.
+const (
+	OrderReserved      = "reserved"
+	OrderPending       = "pending"
+	OrderPaid          = "paid"
+	OrderFailed        = "failed"
+	OrderExpired       = "expired"
+	OrderRefunded      = "refunded"
+	OrderRefundPending = "refund_pending"
+	OrderCancelled     = "cancelled"
+)
+
+// HoldDuration is how long an unpaid order keeps its ticket.
+var HoldDuration = 15 * time.Minute
+
+var (
+	ErrTicketTypeNotFound = errors.New("Ticket type not found")
+	ErrSoldOut            = errors.New("Tickets sold out")
+	ErrOrderNotFound      = errors.New("Order not found")
+	// ErrOrderNotPayable is returned for orders that are paid already or
+	// whose hold expired.
+	ErrOrderNotPayable = errors.New("Order can't be paid")
+	// ErrTicketRequired is returned by Register for events that sell
+	// tickets.
+	ErrTicketRequired     = errors.New("Event requires a ticket")
+	ErrPaymentsDisabled   = errors.New("No payment provider configured")
+	ErrEventHasPaidOrders = errors.New("Event has paid orders")
+)
+
+// paymentProvider takes the money for paid tickets. Without one only free
+// tickets can be ordered.
+var paymentProvider payments.PaymentProvider
+
+// SetPaymentProvider sets the provider used for checkouts and refunds.
+func SetPaymentProvider(p payments.PaymentProvider) {
+	paymentProvider = p
+}
+
+// takenTickets counts the sold tickets and the unexpired holds of the
+// ticket type given as the first argument; the second is the current time.
+const takenTickets = `
+	SELECT COUNT(*) FROM orders
+	WHERE ticket_type_id = ? AND (status = 'paid' OR (status IN ('reserved', 'pending') AND expires_at > ?))`
+
+const orderColumns = "id, event_id, ticket_type_id, user_id, status, amount, currency, expires_at, created_at, updated_at"
+
+func scanOrder(row scanner, order *Order) error {
+	return row.Scan(&order.ID, &order.EventID, &order.TicketTypeID, &order.UserID, &order.Status,
+		&order.Amount, &order.Currency, &order.ExpiresAt, &order.CreatedAt, &order.UpdatedAt)
+}
+
+func (t *TicketType) Save(ctx context.Context) (err error) {
+	query := "INSERT INTO ticket_types(event_id, name, price, currency, quantity) VALUES (?, ?, ?, ?, ?)"
+	ctx, end := startOperation(ctx, "TicketType.Save", query)
+	defer end(&err)
+
+	t.Currency = strings.ToUpper(t.Currency)
+	result, err := db.Conn(ctx).ExecContext(ctx, query, t.EventID, t.Name, t.Price, t.Currency, t.Quantity)
+
+	if err != nil {
+		return err
+	}
+
+	t.ID, err = result.LastInsertId()
+	t.Available = t.Quantity
+	return err
+}
+
+// GetTicketTypes returns the ticket types of the event with their current
+// availability.
+func GetTicketTypes(ctx context.Context, eventId int64) (ticketTypes []TicketType, err error) {
+	query := `
+	SELECT id, event_id, name, price, currency, quantity,
+		quantity - (` + strings.Replace(takenTickets, "ticket_type_id = ?", "ticket_type_id = ticket_types.id", 1) + `)
+	FROM ticket_types WHERE event_id = ? ORDER BY id`
+	ctx, end := startOperation(ctx, "GetTicketTypes", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, time.Now().UTC(), eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ticketTypes = []TicketType{}
+
+	for rows.Next() {
+		var t TicketType
+		err := rows.Scan(&t.ID, &t.EventID, &t.Name, &t.Price, &t.Currency, &t.Quantity, &t.Available)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ticketTypes = append(ticketTypes, t)
+	}
+
+	return ticketTypes, rows.Err()
+}
+
+// Reserve puts a ticket of the type on hold for the user. Free tickets are
+// confirmed and registered right away. Like Register, it needs an invite
+// for private events and records its use; the use counts even if the hold
+// runs out unpaid. Users who are registered or hold a ticket already get
+// ErrAlreadyRegistered.
+func Reserve(ctx context.Context, ticketTypeId, userId int64, inviteToken string) (_ *Order, err error) {
+	query := `
+	INSERT INTO orders(event_id, ticket_type_id, user_id, status, amount, currency, expires_at, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	ctx, end := startOperation(ctx, "Reserve", query)
+	defer end(&err)
+
+	now := time.Now().UTC()
+	order := Order{
+		TicketTypeID: ticketTypeId,
+		UserID:       userId,
+		Status:       OrderReserved,
+		ExpiresAt:    now.Add(HoldDuration),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var quantity, taken int
+		err := tx.QueryRowContext(ctx, "SELECT event_id, price, currency, quantity FROM ticket_types WHERE id = ?", ticketTypeId).
+			Scan(&order.EventID, &order.Amount, &order.Currency, &quantity)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTicketTypeNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = checkNotRegistered(ctx, tx, order.EventID, userId, now)
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, takenTickets, ticketTypeId, now).Scan(&taken)
+
+		if err != nil {
+			return err
+		}
+
+		if taken >= quantity {
+			return ErrSoldOut
+		}
+
+		var event Event
+		err = scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE id = ?", order.EventID), &event)
+
+		if err != nil {
+			return err
+		}
+
+		err = checkInvite(ctx, tx, event, userId, inviteToken)
+
+		if err != nil {
+			return err
+		}
+
+		if order.Amount == 0 {
+			order.Status = OrderPaid
+		}
+
+		result, err := tx.ExecContext(ctx, query, order.EventID, order.TicketTypeID, order.UserID, order.Status,
+			order.Amount, order.Currency, order.ExpiresAt, order.CreatedAt, order.UpdatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		order.ID, err = result.LastInsertId()
+
+		if err != nil || order.Status != OrderPaid {
+			return err
+		}
+
+		return registerOrder(ctx, tx, order)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// checkNotRegistered returns ErrAlreadyRegistered if the user is registered
+// for the event or holds a ticket for it that is paid or still on hold, so
+// repeated orders can't charge them twice.
+func checkNotRegistered(ctx context.Context, tx *sql.Tx, eventId, userId int64, now time.Time) error {
+	var registered bool
+	err := tx.QueryRowContext(ctx, `
+	SELECT EXISTS(SELECT 1 FROM registrations WHERE event_id = ? AND user_id = ?)
+		OR EXISTS(
+			SELECT 1 FROM orders WHERE event_id = ? AND user_id = ?
+			AND (status = 'paid' OR (status IN ('reserved', 'pending') AND expires_at > ?))
+		)`,
+		eventId, userId, eventId, userId, now).Scan(&registered)
+
+	if err != nil {
+		return err
+	}
+
+	if registered {
+		return ErrAlreadyRegistered
+	}
+
+	return nil
+}
+
+func registerOrder(ctx context.Context, tx *sql.Tx, order Order) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO registrations(event_id, user_id, order_id) VALUES (?, ?, ?)", order.EventID, order.UserID, order.ID)
+
+	if err != nil {
+		return err
+	}
+
+	db.AfterCommit(ctx, metrics.EventRegistered)
+	return nil
+}
+
+func GetOrderByID(ctx context.Context, id int64) (_ *Order, err error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = ?"
+	ctx, end := startOperation(ctx, "GetOrderByID", query)
+	defer end(&err)
+
+	var order Order
+	err = scanOrder(db.Conn(ctx).QueryRowContext(ctx, query, id), &order)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// StartCheckout opens a checkout session with the payment provider and
+// sets CheckoutURL. It can be called again to get a new session while the
+// hold lasts.
+func (o *Order) StartCheckout(ctx context.Context, description string) (err error) {
+	query := "UPDATE orders SET status = ?, payment_session_id = ?, updated_at = ? WHERE id = ? AND status IN ('reserved', 'pending') AND expires_at > ?"
+	ctx, end := startOperation(ctx, "Order.StartCheckout", query)
+	defer end(&err)
+
+	if paymentProvider == nil {
+		return ErrPaymentsDisabled
+	}
+
+	now := time.Now().UTC()
+
+	if (o.Status != OrderReserved && o.Status != OrderPending) || !o.ExpiresAt.After(now) {
+		return ErrOrderNotPayable
+	}
+
+	// The provider is called outside of any transaction, so a slow
+	// provider doesn't hold the database lock.
+	session, err := paymentProvider.CreateCheckout(ctx, payments.Checkout{
+		Reference:   "order-" + strconv.FormatInt(o.ID, 10),
+		Amount:      o.Amount,
+		Currency:    o.Currency,
+		Description: description,
+	})
+
+	if err != nil {
+		return fmt.Errorf("creating checkout: %w", err)
+	}
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, OrderPending, session.ID, now, o.ID, now)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOrderNotPayable
+	}
+
+	o.Status = OrderPending
+	o.UpdatedAt = now
+	o.CheckoutURL = session.URL
+	return nil
+}
+
+// HandlePaymentWebhook authenticates a webhook call from the payment
+// provider and applies it. A successful payment confirms the order and
+// registers the user; if the hold ran out and the tickets were sold or the
+// user registered in the meantime, or the order no longer exists or costs
+// something else, the payment is refunded instead. Repeated calls are
+// harmless, and retry refunds that failed before.
+func HandlePaymentWebhook(ctx context.Context, header http.Header, body []byte) (err error) {
+	if paymentProvider == nil {
+		return ErrPaymentsDisabled
+	}
+
+	event, err := paymentProvider.ParseWebhook(header, body)
+
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case payments.PaymentSucceeded:
+		return confirmPayment(ctx, event)
+	case payments.PaymentFailed:
+		query := "UPDATE orders SET status = ?, updated_at = ? WHERE payment_session_id = ? AND status IN ('reserved', 'pending')"
+		ctx, end := startOperation(ctx, "HandlePaymentWebhook", query)
+		defer end(&err)
+
+		_, err = db.Conn(ctx).ExecContext(ctx, query, OrderFailed, time.Now().UTC(), event.SessionID)
+		return err
+	}
+
+	return nil
+}
+
+func confirmPayment(ctx context.Context, event *payments.WebhookEvent) (err error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE payment_session_id = ?"
+	ctx, end := startOperation(ctx, "confirmPayment", query)
+	defer end(&err)
+
+	var order Order
+	var found, matches, refund bool
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		found, matches, refund = false, false, false
+		err := scanOrder(tx.QueryRowContext(ctx, query, event.SessionID), &order)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		found = true
+		matches = event.Amount == order.Amount && strings.EqualFold(event.Currency, order.Currency)
+
+		// A payment for another amount doesn't pay for the order, which
+		// stays open for a new checkout.
+		if !matches {
+			return nil
+		}
+
+		if order.Status == OrderRefundPending {
+			refund = true
+			return nil
+		}
+
+		if order.Status == OrderPaid || order.Status == OrderRefunded || order.Status == OrderCancelled {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		held := (order.Status == OrderReserved || order.Status == OrderPending) && order.ExpiresAt.After(now)
+
+		if !held {
+			var quantity, taken int
+			err = tx.QueryRowContext(ctx, "SELECT quantity FROM ticket_types WHERE id = ?", order.TicketTypeID).Scan(&quantity)
+
+			if err != nil {
+				return err
+			}
+
+			err = tx.QueryRowContext(ctx, takenTickets, order.TicketTypeID, now).Scan(&taken)
+
+			if err != nil {
+				return err
+			}
+
+			refund = taken >= quantity
+
+			// The expired hold no longer kept the user from registering
+			// another way.
+			if !refund {
+				err = checkNotRegistered(ctx, tx, order.EventID, order.UserID, now)
+				refund = errors.Is(err, ErrAlreadyRegistered)
+
+				if err != nil && !refund {
+					return err
+				}
+			}
+		}
+
+		status := OrderPaid
+
+		if refund {
+			status = OrderRefundPending
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE orders SET status = ?, payment_id = ?, updated_at = ? WHERE id = ?", status, event.PaymentID, now, order.ID)
+
+		if err != nil || refund {
+			return err
+		}
+
+		return registerOrder(ctx, tx, order)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// The order was deleted with its event, or the payment doesn't match
+	// it, so nothing was bought. The provider reports what was paid.
+	if !found || !matches {
+		logging.FromContext(ctx).Warn("Refunding payment that doesn't pay for an order", "session", event.SessionID, "found", found, "amount", event.Amount, "currency", event.Currency)
+
+		return paymentProvider.Refund(ctx, payments.Refund{
+			PaymentID:      event.PaymentID,
+			Amount:         event.Amount,
+			Currency:       event.Currency,
+			IdempotencyKey: "refund-session-" + event.SessionID,
+		})
+	}
+
+	if !refund {
+		return nil
+	}
+
+	// The order stays refund_pending until the provider takes the refund,
+	// so a failure here is retried with the provider's next webhook.
+	err = paymentProvider.Refund(ctx, orderRefund(order.ID, event.PaymentID, order.Amount, order.Currency))
+
+	if err != nil {
+		return fmt.Errorf("refunding order %d: %w", order.ID, err)
+	}
+
+	_, err = db.Conn(ctx).ExecContext(ctx, "UPDATE orders SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		OrderRefunded, time.Now().UTC(), order.ID, OrderRefundPending)
+	return err
+}
+
+func orderRefund(orderId int64, paymentId string, amount int64, currency string) payments.Refund {
+	return payments.Refund{
+		PaymentID:      paymentId,
+		Amount:         amount,
+		Currency:       currency,
+		IdempotencyKey: "refund-order-" + strconv.FormatInt(orderId, 10),
+	}
+}
+
+// ExpireHolds marks unpaid orders whose hold ran out as expired and
+// returns how many there were. Expired holds already don't count against
+// availability; this keeps the order statuses accurate.
+func ExpireHolds(ctx context.Context) (expired int64, err error) {
+	query := "UPDATE orders SET status = ?, updated_at = ? WHERE status IN ('reserved', 'pending') AND expires_at <= ?"
+	ctx, end := startOperation(ctx, "ExpireHolds", query)
+	defer end(&err)
+
+	now := time.Now().UTC()
+	result, err := db.Conn(ctx).ExecContext(ctx, query, OrderExpired, now, now)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// RunHoldExpiry calls ExpireHolds every interval until ctx is cancelled.
+// It is meant to run as a lifecycle worker.
+func RunHoldExpiry(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_, err := ExpireHolds(ctx)
+
+			if err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Warn("Could not expire ticket holds", "error", err)
+			}
+		}
+	}
+}
+
+// refundOrders refunds the paid orders behind the user's registration for
+// the event and returns them. Orders for free tickets are returned without
+// calling the provider.
+func refundOrders(ctx context.Context, eventId, userId int64) ([]Order, error) {
+	rows, err := db.Conn(ctx).QueryContext(ctx, `
+	SELECT orders.id, orders.amount, orders.currency, COALESCE(orders.payment_id, '') FROM orders
+	JOIN registrations ON registrations.order_id = orders.id
+	WHERE registrations.event_id = ? AND registrations.user_id = ? AND orders.status = 'paid'`, eventId, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var orders []Order
+	var paymentIds []string
+
+	for rows.Next() {
+		var order Order
+		var paymentId string
+		err := rows.Scan(&order.ID, &order.Amount, &order.Currency, &paymentId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order)
+		paymentIds = append(paymentIds, paymentId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		if order.Amount == 0 {
+			continue
+		}
+
+		if paymentProvider == nil {
+			return nil, ErrPaymentsDisabled
+		}
+
+		// The idempotency key makes retrying after a failure safe.
+		err := paymentProvider.Refund(ctx, orderRefund(order.ID, paymentIds[i], order.Amount, order.Currency))
+
+		if err != nil {
+			return nil, fmt.Errorf("refunding order %d: %w", order.ID, err)
+		}
+	}
+
+	return orders, nil
+}
+
+// closeOrders marks orders returned by refundOrders as refunded, or as
+// cancelled for free tickets.
+func closeOrders(ctx context.Context, tx *sql.Tx, orders []Order) error {
+	now := time.Now().UTC()
+
+	for _, order := range orders {
+		status := OrderRefunded
+
+		if order.Amount == 0 {
+			status = OrderCancelled
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE orders SET status = ?, updated_at = ? WHERE id = ?", status, now, order.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteOrders removes the ticket types and orders of an event that is being
+// deleted. It fails with ErrEventHasPaidOrders while money is still held.
+// Payments for deleted orders that arrive later are refunded by the webhook.
+func deleteOrders(ctx context.Context, tx *sql.Tx, eventId int64) error {
+	var paid bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM orders WHERE event_id = ? AND status IN ('paid', 'refund_pending') AND amount > 0)", eventId).Scan(&paid)
+
+	if err != nil {
+		return err
+	}
+
+	if paid {
+		return ErrEventHasPaidOrders
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM orders WHERE event_id = ?", eventId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM ticket_types WHERE event_id = ?", eventId)
+	return err
+}
@@ -0,0 +1,413 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// TwoFactorIssuer is the account label authenticator apps show.
+const TwoFactorIssuer = "Events API"
+
+// LoginChallengeTTL is how long the second step of a login may take.
+const LoginChallengeTTL = 5 * time.Minute
+
+// maxChallengeAttempts is how many wrong codes a login challenge takes
+// before it is dropped.
+const maxChallengeAttempts = 5
+
+// Every twoFactorLockoutAfter wrong codes in a row lock the user's second
+// factor, whichever challenge they were sent with, for twoFactorLockout at
+// first and twice as long each further time, up to maxTwoFactorLockout.
+// New challenges only take a correct password, so without this six digits
+// could be guessed.
+const (
+	twoFactorLockoutAfter = 5
+	twoFactorLockout      = time.Minute
+	maxTwoFactorLockout   = 24 * time.Hour
+)
+
+// recoveryCodeCount is how many recovery codes enabling 2FA hands out.
+const recoveryCodeCount = 10
+
+// totpOpts are what authenticator apps assume: 6 digits every 30 seconds,
+// and one step of clock drift is forgiven either way.
+var totpOpts = totp.ValidateOpts{Period: 30, Skew: 1, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+var (
+	ErrTwoFactorEnabled    = errors.New("Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("Two-factor authentication is not enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming without having
+	// started enrollment.
+	ErrTwoFactorNotEnrolled = errors.New("Two-factor enrollment not started")
+	// ErrInvalidTwoFactorCode covers wrong, reused and used up codes.
+	ErrInvalidTwoFactorCode = errors.New("Two-factor code is not valid")
+	// ErrLoginChallengeNotFound covers unknown, expired and exhausted
+	// challenges.
+	ErrLoginChallengeNotFound = errors.New("Login challenge not found or expired")
+	// ErrTwoFactorLocked is returned while too many wrong codes keep the
+	// user's second factor locked. Codes aren't checked meanwhile.
+	ErrTwoFactorLocked = errors.New("Two-factor authentication is locked")
+)
+
+// EnrollTwoFactor creates a new TOTP secret for the user and returns it
+// for the authenticator app. It only takes effect once ConfirmTwoFactor
+// proves the app has it; enrolling again replaces an unconfirmed secret.
+func EnrollTwoFactor(ctx context.Context, userId int64, email string) (_ *otp.Key, err error) {
+	query := `
+	INSERT INTO user_totp(user_id, key_url) VALUES (?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET key_url = excluded.key_url WHERE enabled_at IS NULL`
+	ctx, end := startOperation(ctx, "EnrollTwoFactor", query)
+	defer end(&err)
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: TwoFactorIssuer, AccountName: email})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, userId, key.URL())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return key, nil
+}
+
+// PendingTwoFactorKey returns the secret from EnrollTwoFactor while it
+// waits for confirmation.
+func PendingTwoFactorKey(ctx context.Context, userId int64) (_ *otp.Key, err error) {
+	query := "SELECT key_url FROM user_totp WHERE user_id = ? AND enabled_at IS NULL"
+	ctx, end := startOperation(ctx, "PendingTwoFactorKey", query)
+	defer end(&err)
+
+	var keyURL string
+	err = db.Conn(ctx).QueryRowContext(ctx, query, userId).Scan(&keyURL)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return otp.NewKeyFromURL(keyURL)
+}
+
+// ConfirmTwoFactor enables 2FA once code shows the authenticator app has
+// the pending secret. It returns the recovery codes, which are only stored
+// hashed and can't be shown again.
+func ConfirmTwoFactor(ctx context.Context, userId int64, code string) (recoveryCodes []string, err error) {
+	query := "UPDATE user_totp SET enabled_at = ?, last_used_step = ? WHERE user_id = ? AND enabled_at IS NULL"
+	ctx, end := startOperation(ctx, "ConfirmTwoFactor", query)
+	defer end(&err)
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var keyURL string
+		err := tx.QueryRowContext(ctx, "SELECT key_url FROM user_totp WHERE user_id = ? AND enabled_at IS NULL", userId).Scan(&keyURL)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnrolled
+		}
+
+		if err != nil {
+			return err
+		}
+
+		step, ok := matchTOTP(keyURL, code, 0)
+
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		_, err = tx.ExecContext(ctx, query, time.Now().UTC(), step, userId)
+
+		if err != nil {
+			return err
+		}
+
+		recoveryCodes, err = replaceRecoveryCodes(ctx, tx, userId)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// TwoFactorEnabled reports whether logging in as the user needs a code.
+func TwoFactorEnabled(ctx context.Context, userId int64) (enabled bool, err error) {
+	query := "SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = ? AND enabled_at IS NOT NULL)"
+	ctx, end := startOperation(ctx, "TwoFactorEnabled", query)
+	defer end(&err)
+
+	err = db.Conn(ctx).QueryRowContext(ctx, query, userId).Scan(&enabled)
+	return enabled, err
+}
+
+// DisableTwoFactor turns 2FA off after checking a current TOTP or recovery
+// code, so a stolen session alone can't remove it.
+func DisableTwoFactor(ctx context.Context, userId int64, code string) (err error) {
+	query := "DELETE FROM user_totp WHERE user_id = ?"
+	ctx, end := startOperation(ctx, "DisableTwoFactor", query)
+	defer end(&err)
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := checkTwoFactorCode(ctx, tx, userId, code)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query, userId)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId)
+		return err
+	})
+
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return errors.Join(err, recordTwoFactorFailure(ctx, userId))
+	}
+
+	return err
+}
+
+// CreateLoginChallenge returns a token standing in for a correct password
+// until the second factor is checked by CompleteLoginChallenge.
+func CreateLoginChallenge(ctx context.Context, userId int64) (token string, err error) {
+	query := "INSERT INTO login_challenges(token_hash, user_id, expires_at) VALUES (?, ?, ?)"
+	ctx, end := startOperation(ctx, "CreateLoginChallenge", query)
+	defer end(&err)
+
+	token, hash, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = db.Conn(ctx).ExecContext(ctx, "DELETE FROM login_challenges WHERE expires_at <= ?", now)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Conn(ctx).ExecContext(ctx, query, hash, userId, now.Add(LoginChallengeTTL))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// CompleteLoginChallenge checks code, a TOTP or recovery code, against the
+// challenge's user and returns the user's id. A challenge works once and
+// is dropped after too many wrong codes.
+func CompleteLoginChallenge(ctx context.Context, token, code string) (userId int64, err error) {
+	query := "SELECT user_id, attempts FROM login_challenges WHERE token_hash = ? AND expires_at > ?"
+	ctx, end := startOperation(ctx, "CompleteLoginChallenge", query)
+	defer end(&err)
+
+	hash := utils.HashRandomToken(token)
+
+	// A wrong code must count against the challenge and the user, so it is
+	// recorded in its own transaction rather than rolled back with the check.
+	var attempts int
+	err = db.Conn(ctx).QueryRowContext(ctx, query, hash, time.Now().UTC()).Scan(&userId, &attempts)
+
+	if errors.Is(err, sql.ErrNoRows) || attempts >= maxChallengeAttempts {
+		return 0, ErrLoginChallengeNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		err := checkTwoFactorCode(ctx, tx, userId, code)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM login_challenges WHERE token_hash = ?", hash)
+		return err
+	})
+
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		_, updateErr := db.Conn(ctx).ExecContext(ctx, "UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ?", hash)
+		return 0, errors.Join(err, updateErr, recordTwoFactorFailure(ctx, userId))
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+// checkTwoFactorCode accepts a TOTP code newer than the last one used, or
+// an unused recovery code, and marks it used. Callers record wrong codes
+// with recordTwoFactorFailure once the transaction is rolled back.
+func checkTwoFactorCode(ctx context.Context, tx *sql.Tx, userId int64, code string) error {
+	var keyURL string
+	var lastStep int64
+	var lockedUntil sql.NullTime
+	err := tx.QueryRowContext(ctx, "SELECT key_url, last_used_step, locked_until FROM user_totp WHERE user_id = ? AND enabled_at IS NOT NULL", userId).
+		Scan(&keyURL, &lastStep, &lockedUntil)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	if lockedUntil.Valid && lockedUntil.Time.After(now) {
+		return ErrTwoFactorLocked
+	}
+
+	if step, ok := matchTOTP(keyURL, code, lastStep); ok {
+		_, err = tx.ExecContext(ctx, "UPDATE user_totp SET last_used_step = ?, failed_attempts = 0, locked_until = NULL WHERE user_id = ?", step, userId)
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		now, userId, utils.HashRandomToken(normalizeRecoveryCode(code)))
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE user_totp SET failed_attempts = 0, locked_until = NULL WHERE user_id = ?", userId)
+	return err
+}
+
+// recordTwoFactorFailure counts a wrong code against the user and locks
+// their second factor after every twoFactorLockoutAfter failures in a row.
+func recordTwoFactorFailure(ctx context.Context, userId int64) error {
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var failures int
+		err := tx.QueryRowContext(ctx, "SELECT failed_attempts + 1 FROM user_totp WHERE user_id = ?", userId).Scan(&failures)
+
+		if err != nil {
+			return err
+		}
+
+		var lockedUntil *time.Time
+
+		if failures%twoFactorLockoutAfter == 0 {
+			lockout := twoFactorLockout
+
+			for i := twoFactorLockoutAfter; i < failures && lockout < maxTwoFactorLockout; i += twoFactorLockoutAfter {
+				lockout *= 2
+			}
+
+			until := time.Now().UTC().Add(min(lockout, maxTwoFactorLockout))
+			lockedUntil = &until
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE user_totp SET failed_attempts = ?, locked_until = COALESCE(?, locked_until) WHERE user_id = ?",
+			failures, lockedUntil, userId)
+		return err
+	})
+}
+
+// matchTOTP returns the time step code is valid for. Steps up to lastStep
+// are refused, so a code can't be replayed.
+func matchTOTP(keyURL, code string, lastStep int64) (int64, bool) {
+	key, err := otp.NewKeyFromURL(keyURL)
+
+	if err != nil || len(code) != totpOpts.Digits.Length() {
+		return 0, false
+	}
+
+	now := time.Now()
+
+	for skew := -int64(totpOpts.Skew); skew <= int64(totpOpts.Skew); skew++ {
+		at := now.Add(time.Duration(skew*int64(totpOpts.Period)) * time.Second)
+		step := at.Unix() / int64(totpOpts.Period)
+
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(key.Secret(), at, totpOpts)
+
+		if err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// replaceRecoveryCodes stores new recovery codes for the user in place of
+// the old ones. Each has 80 random bits, so a fast hash is fine.
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int64) ([]string, error) {
+	_, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO recovery_codes(user_id, code_hash) VALUES (?, ?)",
+			userId, utils.HashRandomToken(normalizeRecoveryCode(codes[i])))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case and dashes, which are easy to get
+// wrong when typing a code.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -1,35 +1,82 @@
 package models
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"example.com/rest-api/db"
+	"example.com/rest-api/logging"
 	"example.com/rest-api/utils"
 )
 
+var ErrInvalidCredentials = errors.New("Credentials invalid")
+
+var ErrUserNotFound = errors.New("User not found")
+
+// ErrUserDisabled is returned when a disabled user tries to sign in.
+var ErrUserDisabled = errors.New("User disabled")
+
+// ErrSessionRevoked is returned for login tokens issued before the user's
+// sessions were revoked.
+var ErrSessionRevoked = errors.New("Session revoked")
+
+// ErrInvalidRole is returned for roles other than RoleUser and RoleAdmin.
+var ErrInvalidRole = errors.New("Invalid role")
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID       int64
 	Email    string `binding:"required"`
 	Password string `binding:"required"`
+	// Role is RoleUser or RoleAdmin. It can't be set through the API.
+	Role string `json:"-"`
 }
 
-func (u User) Save() error {
-	query := "INSERT INTO users(email, password) VALUES (?, ?)"
-	stmt, err := db.DB.Prepare(query)
+// NormalizeEmail returns email the way it is stored: trimmed and in lower
+// case. Lookups also compare with COLLATE NOCASE, like the unique index on
+// users.email.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// dummyPasswordHash is checked instead of a user's hash when there is
+// none, so unknown emails take as long to reject as wrong passwords.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("no such user")
+	return hash
+})
+
+func (u User) Save(ctx context.Context) (err error) {
+	// Hash before starting the operation so the slow hash doesn't count
+	// against the query timeout.
+	hashedPassword, err := utils.HashPassword(u.Password)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	query := "INSERT INTO users(email, password) VALUES (?, ?)"
+	ctx, end := startOperation(ctx, "User.Save", query)
+	defer end(&err)
 
-	hashedPassword, err := utils.HashPassword(u.Password)
+	stmt, err := db.Conn(ctx).PrepareContext(ctx, query)
 
 	if err != nil {
 		return err
 	}
 
-	result, err := stmt.Exec(u.Email, hashedPassword)
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, NormalizeEmail(u.Email), hashedPassword)
 
 	if err != nil {
 		return err
@@ -41,21 +88,263 @@ func (u User) Save() error {
 	return err
 }
 
-func (u *User) ValidateCredentials() error {
-	query := "SELECT id, password FROM users WHERE email = ?"
-	row := db.DB.QueryRow(query, u.Email)
+func (u *User) ValidateCredentials(ctx context.Context) error {
+	retrievedPassword, disabled, err := u.storedPassword(ctx)
 
-	var retrievedPassword string
-	err := row.Scan(&u.ID, &retrievedPassword)
+	// Users who only sign in through an identity provider have no
+	// password.
+	if errors.Is(err, ErrInvalidCredentials) || (err == nil && retrievedPassword == "") {
+		utils.CheckPasswordHash(u.Password, dummyPasswordHash())
+		return ErrInvalidCredentials
+	}
 
 	if err != nil {
-		return errors.New("Credentials invalid")
+		return err
 	}
 
+	// The slow hash comparison and rehash run outside the operation, so
+	// they don't count against the query timeout.
 	passwordIsValid := utils.CheckPasswordHash(u.Password, retrievedPassword)
 
 	if !passwordIsValid {
-		return errors.New("Credentials invalid")
+		return ErrInvalidCredentials
+	}
+
+	if disabled {
+		return ErrUserDisabled
+	}
+
+	// The password is only known now, so this is the chance to move the
+	// hash to the current algorithm and parameters. Logging in works
+	// either way.
+	if utils.PasswordNeedsRehash(retrievedPassword) {
+		rehashErr := rehashPassword(ctx, u.ID, u.Password, retrievedPassword)
+
+		if rehashErr != nil {
+			logging.FromContext(ctx).Warn("Could not upgrade password hash", "userId", u.ID, "error", rehashErr)
+		}
+	}
+
+	return nil
+}
+
+// storedPassword reads the user's id, password hash and whether they are
+// disabled by email.
+func (u *User) storedPassword(ctx context.Context) (hash string, disabled bool, err error) {
+	query := "SELECT id, password, disabled_at IS NOT NULL FROM users WHERE email = ? COLLATE NOCASE"
+	ctx, end := startOperation(ctx, "User.ValidateCredentials", query)
+	defer end(&err)
+
+	u.Email = NormalizeEmail(u.Email)
+
+	err = db.Conn(ctx).QueryRowContext(ctx, query, u.Email).Scan(&u.ID, &hash, &disabled)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, ErrInvalidCredentials
+	}
+
+	return hash, disabled, err
+}
+
+// rehashPassword replaces the user's password hash with a current one,
+// unless the password changed since oldHash was read.
+func rehashPassword(ctx context.Context, userId int64, password, oldHash string) (err error) {
+	hashedPassword, err := utils.HashPassword(password)
+
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE users SET password = ? WHERE id = ? AND password = ?"
+	ctx, end := startOperation(ctx, "User.RehashPassword", query)
+	defer end(&err)
+
+	_, err = db.Conn(ctx).ExecContext(ctx, query, hashedPassword, userId, oldHash)
+	return err
+}
+
+// GetUserByID returns the user without its password.
+func GetUserByID(ctx context.Context, id int64) (_ *User, err error) {
+	query := "SELECT id, email, role FROM users WHERE id = ?"
+	ctx, end := startOperation(ctx, "GetUserByID", query)
+	defer end(&err)
+
+	var user User
+	err = db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &user.Role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUsersByIDs returns the users with the ids, without their passwords.
+// Unknown ids are missing from the map.
+func GetUsersByIDs(ctx context.Context, ids []int64) (users map[int64]User, err error) {
+	query := "SELECT id, email, role FROM users WHERE id IN (%s)"
+	ctx, end := startOperation(ctx, "GetUsersByIDs", query)
+	defer end(&err)
+
+	users = make(map[int64]User, len(ids))
+
+	err = eachIDBatch(ids, func(in string, args []any) error {
+		rows, err := db.Conn(ctx).QueryContext(ctx, fmt.Sprintf(query, in), args...)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var user User
+			err := rows.Scan(&user.ID, &user.Email, &user.Role)
+
+			if err != nil {
+				return err
+			}
+
+			users[user.ID] = user
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetUserByEmail returns the user without its password. Emails are
+// compared case-insensitively.
+func GetUserByEmail(ctx context.Context, email string) (_ *User, err error) {
+	query := "SELECT id, email, role FROM users WHERE email = ? COLLATE NOCASE"
+	ctx, end := startOperation(ctx, "GetUserByEmail", query)
+	defer end(&err)
+
+	var user User
+	err = db.Conn(ctx).QueryRowContext(ctx, query, NormalizeEmail(email)).Scan(&user.ID, &user.Email, &user.Role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SetUserRole changes the role of the user with the email address.
+func SetUserRole(ctx context.Context, email, role string) (err error) {
+	query := "UPDATE users SET role = ? WHERE email = ? COLLATE NOCASE"
+	ctx, end := startOperation(ctx, "SetUserRole", query)
+	defer end(&err)
+
+	if role != RoleUser && role != RoleAdmin {
+		return ErrInvalidRole
+	}
+
+	result, err := db.Conn(ctx).ExecContext(ctx, query, role, NormalizeEmail(email))
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DisableUser stops the user with the email address from signing in and
+// revokes their API tokens. Login tokens they hold stop working too, as
+// CheckSession is checked on every request, and AuthenticateAPIToken
+// rejects any API token of a disabled user.
+func DisableUser(ctx context.Context, email string) (err error) {
+	query := "UPDATE users SET disabled_at = COALESCE(disabled_at, ?) WHERE email = ? COLLATE NOCASE RETURNING id"
+	ctx, end := startOperation(ctx, "DisableUser", query)
+	defer end(&err)
+
+	now := time.Now().UTC()
+
+	return db.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var userId int64
+		err := tx.QueryRowContext(ctx, query, now, NormalizeEmail(email)).Scan(&userId)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userId)
+		return err
+	})
+}
+
+// CheckUserActive returns ErrUserDisabled or ErrUserNotFound if the user
+// may no longer use the tokens issued to them.
+func CheckUserActive(ctx context.Context, id int64) (err error) {
+	query := "SELECT disabled_at IS NOT NULL FROM users WHERE id = ?"
+	ctx, end := startOperation(ctx, "CheckUserActive", query)
+	defer end(&err)
+
+	var disabled bool
+	err = db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&disabled)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		return ErrUserDisabled
+	}
+
+	return nil
+}
+
+// CheckSession is CheckUserActive for a login token issued at issuedAt. It
+// also returns ErrSessionRevoked if the user's sessions were revoked after
+// the token was issued.
+func CheckSession(ctx context.Context, id int64, issuedAt time.Time) (err error) {
+	query := "SELECT disabled_at IS NOT NULL, sessions_valid_after FROM users WHERE id = ?"
+	ctx, end := startOperation(ctx, "CheckSession", query)
+	defer end(&err)
+
+	var disabled bool
+	var validAfter sql.NullTime
+	err = db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&disabled, &validAfter)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		return ErrUserDisabled
+	}
+
+	if validAfter.Valid && issuedAt.Before(validAfter.Time) {
+		return ErrSessionRevoked
 	}
 
 	return nil
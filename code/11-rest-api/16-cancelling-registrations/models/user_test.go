@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/utils"
+)
+
+func TestValidateCredentialsUpgradesHash(t *testing.T) {
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+	t.Cleanup(func() { utils.SetPasswordHasher(utils.DefaultArgon2id) })
+
+	utils.SetPasswordHasher(utils.Bcrypt{Cost: 4})
+	err := User{Email: "user@example.com", Password: "secret"}.Save(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utils.SetPasswordHasher(utils.DefaultArgon2id)
+
+	wrong := User{Email: "user@example.com", Password: "wrong"}
+
+	if err := wrong.ValidateCredentials(context.Background()); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM users WHERE password LIKE '$2a$%'"); n != 1 {
+		t.Error("a wrong password upgraded the hash")
+	}
+
+	user := User{Email: "user@example.com", Password: "secret"}
+
+	if err := user.ValidateCredentials(context.Background()); err != nil {
+		t.Fatalf("bcrypt hash: got %v", err)
+	}
+
+	var hash string
+	err = db.DB.QueryRow("SELECT password FROM users WHERE id = ?", user.ID).Scan(&hash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Errorf("got hash %q after login, want argon2id", hash)
+	}
+
+	if err := user.ValidateCredentials(context.Background()); err != nil {
+		t.Errorf("upgraded hash: got %v", err)
+	}
+}
+
+func TestEmailsAreNormalized(t *testing.T) {
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+	t.Cleanup(func() { db.DB.Close() })
+	t.Cleanup(func() { utils.SetPasswordHasher(utils.DefaultArgon2id) })
+	utils.SetPasswordHasher(utils.Bcrypt{Cost: 4})
+
+	err := User{Email: " Bob@Example.com ", Password: "secret"}.Save(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, "SELECT COUNT(*) FROM users WHERE email = 'bob@example.com'"); n != 1 {
+		t.Error("the email wasn't stored trimmed and in lower case")
+	}
+
+	user := User{Email: "BOB@example.com", Password: "secret"}
+
+	if err := user.ValidateCredentials(context.Background()); err != nil || user.Email != "bob@example.com" {
+		t.Errorf("login with another case: got %q, %v", user.Email, err)
+	}
+
+	if err := (User{Email: "bob@EXAMPLE.com", Password: "other"}).Save(context.Background()); !db.IsUniqueViolation(err) {
+		t.Errorf("signup with another case: got %v, want a unique violation", err)
+	}
+
+	unknown := User{Email: "nobody@example.com", Password: "secret"}
+
+	if err := unknown.ValidateCredentials(context.Background()); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown email: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAPITokenOfDisabledUser(t *testing.T) {
+	setupEvent(t)
+	ctx := context.Background()
+
+	token := APIToken{UserID: 2, Name: "CI", Scopes: []Scope{ScopeEventsRead}}
+	secret, err := token.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateAPIToken(ctx, secret); err != nil {
+		t.Fatalf("active user: got error %v", err)
+	}
+
+	// Disabled without revoking the token, as a token created while
+	// DisableUser runs would be.
+	_, err = db.DB.Exec("UPDATE users SET disabled_at = CURRENT_TIMESTAMP WHERE id = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuthenticateAPIToken(ctx, secret); !errors.Is(err, ErrInvalidAPIToken) {
+		t.Errorf("disabled user: got error %v, want %v", err, ErrInvalidAPIToken)
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/geo"
+)
+
+// Venue is the structured location of an event. Lat and Lng are either
+// both set or both nil; an address without coordinates can't be found by
+// FindEventsNear.
+type Venue struct {
+	Address string
+	Lat     *float64 `binding:"omitempty,min=-90,max=90"`
+	Lng     *float64 `binding:"omitempty,min=-180,max=180"`
+}
+
+// NearbyEvent is an event together with its distance from the searched
+// point.
+type NearbyEvent struct {
+	Event
+	DistanceKm float64
+}
+
+var ErrIncompleteVenue = errors.New("Venue needs both latitude and longitude")
+
+// HasCoordinates reports whether the venue has been placed on the map.
+func (v *Venue) HasCoordinates() bool {
+	return v != nil && v.Lat != nil && v.Lng != nil
+}
+
+// Point returns the coordinates of the venue. Check HasCoordinates first.
+func (v *Venue) Point() geo.Point {
+	return geo.Point{Lat: *v.Lat, Lng: *v.Lng}
+}
+
+func (v *Venue) complete() bool {
+	return v == nil || (v.Lat == nil) == (v.Lng == nil)
+}
+
+// columns returns the venue_address, venue_lat and venue_lng values to
+// store. An empty venue is stored as NULLs.
+func (v *Venue) columns() (address sql.NullString, lat, lng sql.NullFloat64) {
+	if v == nil {
+		return
+	}
+
+	address.String = strings.TrimSpace(v.Address)
+	address.Valid = address.String != ""
+
+	if v.HasCoordinates() {
+		lat = sql.NullFloat64{Float64: *v.Lat, Valid: true}
+		lng = sql.NullFloat64{Float64: *v.Lng, Valid: true}
+	}
+
+	return
+}
+
+func venueFromColumns(address sql.NullString, lat, lng sql.NullFloat64) *Venue {
+	if !address.Valid && !lat.Valid {
+		return nil
+	}
+
+	venue := &Venue{Address: address.String}
+
+	if lat.Valid && lng.Valid {
+		venue.Lat = &lat.Float64
+		venue.Lng = &lng.Float64
+	}
+
+	return venue
+}
+
+// FindEventsNear returns the events matching filter whose venue is within
+// radiusKm of center, nearest first. SQL only narrows the candidates down
+// to a bounding box; the exact distance is computed here.
+func FindEventsNear(ctx context.Context, center geo.Point, radiusKm float64, filter EventFilter) (events []NearbyEvent, err error) {
+	box := geo.BoundingBox(center, radiusKm)
+	conditions, args := filter.conditions()
+	conditions = append(conditions, "venue_lat BETWEEN ? AND ?")
+	args = append(args, box.MinLat, box.MaxLat)
+
+	if box.MinLng <= box.MaxLng {
+		conditions = append(conditions, "venue_lng BETWEEN ? AND ?")
+	} else {
+		conditions = append(conditions, "(venue_lng >= ? OR venue_lng <= ?)")
+	}
+
+	args = append(args, box.MinLng, box.MaxLng)
+
+	query := "SELECT " + eventColumns + " FROM events WHERE " + strings.Join(conditions, " AND ")
+	ctx, end := startOperation(ctx, "FindEventsNear", query)
+	defer end(&err)
+
+	rows, err := db.Conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []Event
+
+	for rows.Next() {
+		var event Event
+		err := scanEvent(rows, &event)
+
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadTags(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	events = []NearbyEvent{}
+
+	for _, event := range candidates {
+		distance := geo.Distance(center, event.Venue.Point())
+
+		if distance <= radiusKm {
+			events = append(events, NearbyEvent{Event: event, DistanceKm: distance})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].DistanceKm < events[j].DistanceKm
+	})
+
+	return events, nil
+}
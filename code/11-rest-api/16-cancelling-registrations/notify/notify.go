@@ -0,0 +1,52 @@
+// Package notify delivers notifications, such as event reminders, to users.
+package notify
+
+import (
+	"context"
+	"sync"
+
+	"example.com/rest-api/mail"
+)
+
+type Notification struct {
+	UserID  int64
+	Email   string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// EmailNotifier sends notifications as email.
+type EmailNotifier struct {
+	Mailer mail.Mailer
+}
+
+func (e EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	return e.Mailer.Send(ctx, mail.Message{To: n.Email, Subject: n.Subject, Body: n.Body})
+}
+
+// Recorder keeps every notification in memory. It is meant for tests.
+type Recorder struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (r *Recorder) Notify(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+// Notifications returns the notifications delivered so far.
+func (r *Recorder) Notifications() []Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Notification(nil), r.notifications...)
+}
@@ -0,0 +1,184 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeProvider is a PaymentProvider that runs in process. Checkouts are
+// completed by calling Complete or Fail, or by POSTing to the session URL
+// served by Handler, which sends a signed webhook to WebhookURL like a
+// real provider would.
+type FakeProvider struct {
+	// Secret signs the webhooks.
+	Secret string
+	// WebhookURL receives the webhooks.
+	WebhookURL string
+	// CheckoutURL is the base of the session URLs, where Handler is
+	// mounted.
+	CheckoutURL string
+	Client      *http.Client
+
+	mu        sync.Mutex
+	sessions  map[string]Checkout
+	refunds   map[string]Refund
+	refundErr error
+	next      int
+}
+
+func (f *FakeProvider) CreateCheckout(ctx context.Context, checkout Checkout) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.sessions == nil {
+		f.sessions = map[string]Checkout{}
+	}
+
+	f.next++
+	id := fmt.Sprintf("cs_fake_%d", f.next)
+	f.sessions[id] = checkout
+
+	return &Session{ID: id, URL: strings.TrimSuffix(f.CheckoutURL, "/") + "/" + id + "/complete"}, nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, refund Refund) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.refundErr != nil {
+		return f.refundErr
+	}
+
+	if f.refunds == nil {
+		f.refunds = map[string]Refund{}
+	}
+
+	f.refunds[refund.IdempotencyKey] = refund
+	return nil
+}
+
+// FailRefunds makes Refund return err until it is called again with nil.
+func (f *FakeProvider) FailRefunds(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refundErr = err
+}
+
+// Refunds returns the refunds made so far, one per idempotency key.
+func (f *FakeProvider) Refunds() []Refund {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	refunds := make([]Refund, 0, len(f.refunds))
+
+	for _, refund := range f.refunds {
+		refunds = append(refunds, refund)
+	}
+
+	return refunds
+}
+
+func (f *FakeProvider) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	err := VerifyWebhook(f.Secret, header.Get(SignatureHeader), body, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	err = json.Unmarshal(body, &event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Complete pays the session and notifies WebhookURL.
+func (f *FakeProvider) Complete(ctx context.Context, sessionId string) error {
+	return f.notify(ctx, WebhookEvent{Type: PaymentSucceeded, SessionID: sessionId, PaymentID: "pay_" + sessionId})
+}
+
+// Fail declines the session and notifies WebhookURL.
+func (f *FakeProvider) Fail(ctx context.Context, sessionId string) error {
+	return f.notify(ctx, WebhookEvent{Type: PaymentFailed, SessionID: sessionId})
+}
+
+func (f *FakeProvider) notify(ctx context.Context, event WebhookEvent) error {
+	f.mu.Lock()
+	checkout, ok := f.sessions[event.SessionID]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("payments: unknown session %q", event.SessionID)
+	}
+
+	if event.Type == PaymentSucceeded {
+		event.Amount, event.Currency = checkout.Amount, checkout.Currency
+	}
+
+	body, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.WebhookURL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, SignWebhook(f.Secret, time.Now(), body))
+
+	client := f.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("payments: webhook answered %s", res.Status)
+	}
+
+	return nil
+}
+
+// Handler completes a session on POST /{session id}/complete, standing in
+// for the provider's checkout page. Mount it under CheckoutURL.
+func (f *FakeProvider) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		if r.Method != http.MethodPost || len(parts) < 2 || parts[len(parts)-1] != "complete" {
+			http.NotFound(w, r)
+			return
+		}
+
+		err := f.Complete(r.Context(), parts[len(parts)-2])
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		fmt.Fprintln(w, "Payment completed.")
+	})
+}
@@ -0,0 +1,120 @@
+// Package payments abstracts the payment service that takes the money for
+// paid tickets.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook event types.
+const (
+	PaymentSucceeded = "payment.succeeded"
+	PaymentFailed    = "payment.failed"
+)
+
+// SignatureHeader carries the signature of webhook calls.
+const SignatureHeader = "X-Payment-Signature"
+
+// WebhookTolerance is how old a signed webhook may be, which limits
+// replays.
+const WebhookTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("payments: invalid webhook signature")
+
+// Checkout describes what the customer pays for. Amount is in the minor
+// unit of the currency, e.g. cents.
+type Checkout struct {
+	Reference   string
+	Amount      int64
+	Currency    string
+	Description string
+}
+
+// Session is a started checkout. The customer pays at URL.
+type Session struct {
+	ID  string
+	URL string
+}
+
+// Refund pays back a payment. Providers must treat a repeated
+// IdempotencyKey as the same refund.
+type Refund struct {
+	PaymentID      string
+	Amount         int64
+	Currency       string
+	IdempotencyKey string
+}
+
+// WebhookEvent is what a provider reports about a checkout session. Amount
+// and Currency are what the customer paid.
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId"`
+	PaymentID string `json:"paymentId"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+// PaymentProvider is a payment service.
+type PaymentProvider interface {
+	CreateCheckout(ctx context.Context, checkout Checkout) (*Session, error)
+	Refund(ctx context.Context, refund Refund) error
+	// ParseWebhook authenticates a webhook call and decodes its event.
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+// SignWebhook returns the signature header value for body sent at t, in
+// the form "t=<unix seconds>,v1=<hex HMAC-SHA256 of "t.body">".
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + webhookMAC(secret, timestamp, body)
+}
+
+// VerifyWebhook checks a signature made by SignWebhook and rejects
+// signatures older than WebhookTolerance.
+func VerifyWebhook(secret, signature string, body []byte, now time.Time) error {
+	var timestamp, mac string
+
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			mac = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil || mac == "" {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(webhookMAC(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func webhookMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"payment.succeeded"}`)
+	signature := SignWebhook("secret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		body      []byte
+		now       time.Time
+		valid     bool
+	}{
+		{"valid", "secret", signature, body, now, true},
+		{"slightly late", "secret", signature, body, now.Add(WebhookTolerance / 2), true},
+		{"wrong secret", "other", signature, body, now, false},
+		{"tampered body", "secret", signature, []byte(`{"type":"payment.failed"}`), now, false},
+		{"too old", "secret", signature, body, now.Add(WebhookTolerance + time.Second), false},
+		{"missing", "secret", "", body, now, false},
+		{"malformed", "secret", "v1=abc", body, now, false},
+	}
+
+	for _, tt := range tests {
+		err := VerifyWebhook(tt.secret, tt.signature, tt.body, tt.now)
+
+		if tt.valid && err != nil {
+			t.Errorf("%s: got %v, want valid", tt.name, err)
+		}
+
+		if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
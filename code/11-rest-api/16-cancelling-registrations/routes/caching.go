@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondCacheable sends body as JSON with a strong ETag derived from the
+// encoded body and, if lastModified is set, a Last-Modified header. It
+// answers 304 Not Modified when the client's copy is still current.
+// Bodies only some viewers may see are marked private, so shared caches
+// don't keep them.
+func respondCacheable(context *gin.Context, body any, lastModified time.Time, private bool) {
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not encode response.", err)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	lastModified = lastModified.UTC().Truncate(time.Second)
+
+	context.Header("ETag", etag)
+	if private {
+		context.Header("Cache-Control", "private, no-cache")
+	} else {
+		context.Header("Cache-Control", "no-cache")
+	}
+
+	if !lastModified.IsZero() {
+		context.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if notModified(context.Request, etag, lastModified) {
+		context.Status(http.StatusNotModified)
+		return
+	}
+
+	context.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// notModified evaluates If-None-Match and, only if that is absent,
+// If-Modified-Since (RFC 9110, section 13.2.2).
+func notModified(request *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(since)
+}
@@ -0,0 +1,122 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func createEvent(t *testing.T, server *testutil.Server, token, name string) {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/events", gin.H{
+		"name":        name,
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+	}, token)
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create event: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestEventETag(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+	createEvent(t, server, token, "Meetup")
+
+	for _, path := range []string{"/events", "/events/1"} {
+		t.Run(path, func(t *testing.T) {
+			res := server.Do(http.MethodGet, path, nil, "")
+			etag := res.Header.Get("ETag")
+
+			if res.StatusCode != http.StatusOK || etag == "" {
+				t.Fatalf("got status %d and ETag %q", res.StatusCode, etag)
+			}
+
+			res = server.DoWithHeader(http.MethodGet, path, nil, http.Header{"If-None-Match": {etag}})
+			if res.StatusCode != http.StatusNotModified {
+				t.Errorf("matching If-None-Match: got status %d, want %d", res.StatusCode, http.StatusNotModified)
+			}
+
+			if len(res.Body) != 0 {
+				t.Errorf("304 response has a body: %s", res.Body)
+			}
+
+			res = server.DoWithHeader(http.MethodGet, path, nil, http.Header{"If-None-Match": {`"stale"`}})
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("stale If-None-Match: got status %d, want %d", res.StatusCode, http.StatusOK)
+			}
+		})
+	}
+
+	before := server.Do(http.MethodGet, "/events/1", nil, "").Header.Get("ETag")
+
+	res := server.Do(http.MethodPut, "/events/1", gin.H{
+		"name":        "Updated meetup",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+	}, token)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("update: got status %d", res.StatusCode)
+	}
+
+	res = server.DoWithHeader(http.MethodGet, "/events/1", nil, http.Header{"If-None-Match": {before}})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("after update: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestEventLastModified(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+	createEvent(t, server, token, "Meetup")
+
+	res := server.Do(http.MethodGet, "/events", nil, "")
+	lastModified := res.Header.Get("Last-Modified")
+
+	if _, err := http.ParseTime(lastModified); err != nil {
+		t.Fatalf("invalid Last-Modified %q: %v", lastModified, err)
+	}
+
+	res = server.DoWithHeader(http.MethodGet, "/events", nil, http.Header{"If-Modified-Since": {lastModified}})
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("If-Modified-Since = Last-Modified: got status %d, want %d", res.StatusCode, http.StatusNotModified)
+	}
+
+	past := time.Now().Add(-24 * time.Hour).UTC().Format(http.TimeFormat)
+	res = server.DoWithHeader(http.MethodGet, "/events", nil, http.Header{"If-Modified-Since": {past}})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("If-Modified-Since in the past: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestPrivateEventsAreNotCachedByOthers(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+	createEvent(t, server, token, "Meetup")
+
+	if res := server.Do(http.MethodGet, "/events/1", nil, ""); res.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("public event: got Cache-Control %q, want no-cache", res.Header.Get("Cache-Control"))
+	}
+
+	res := server.Do(http.MethodPut, "/events/1", gin.H{
+		"name":        "Meetup",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+		"visibility":  "private",
+	}, token)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("update: got status %d", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodGet, "/events/1", nil, token); res.Header.Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("private event: got Cache-Control %q, want private, no-cache", res.Header.Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrSize is the width and height of the check-in QR codes in pixels.
+const qrSize = 256
+
+// getMyRegistrations lists the current user's registrations with the
+// check-in code for each.
+func getMyRegistrations(context *gin.Context) {
+	registrations, err := models.GetRegistrationsForUser(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch registrations.", err)
+		return
+	}
+
+	type registrationWithCode struct {
+		models.Registration
+		CheckInCode string
+	}
+
+	result := make([]registrationWithCode, len(registrations))
+
+	for i, registration := range registrations {
+		result[i] = registrationWithCode{registration, models.CheckInCode(registration.ID)}
+	}
+
+	context.JSON(http.StatusOK, result)
+}
+
+// getRegistrationQR renders the check-in code of one of the current user's
+// registrations as a QR code PNG.
+func getRegistrationQR(context *gin.Context) {
+	registrationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse registration id.", err)
+		return
+	}
+
+	registration, err := models.GetRegistrationByID(context.Request.Context(), registrationId)
+
+	if errors.Is(err, models.ErrRegistrationNotFound) || (err == nil && registration.UserID != context.GetInt64("userId")) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not find registration."})
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch registration.", err)
+		return
+	}
+
+	png, err := qrcode.Encode(models.CheckInCode(registration.ID), qrcode.Medium, qrSize)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not render QR code.", err)
+		return
+	}
+
+	context.Header("Cache-Control", "private, no-store")
+	context.Data(http.StatusOK, "image/png", png)
+}
+
+// checkInAttendee validates a scanned check-in code and records the
+// arrival. Each code works once.
+func checkInAttendee(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermCheckIn)
+
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Code string `binding:"required"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	checkIn, err := event.CheckIn(context.Request.Context(), request.Code)
+
+	if errors.Is(err, models.ErrInvalidCheckInCode) {
+		respondWithError(context, http.StatusUnprocessableEntity, "The code is not valid for this event.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyCheckedIn) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Already checked in.", "checkIn": checkIn})
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not check in.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Checked in!", "checkIn": checkIn})
+}
+
+// getEventStats returns the live registration and attendance counts. Both
+// co-hosts who see the attendees and those working the door may read them.
+func getEventStats(context *gin.Context) {
+	userId := context.GetInt64("userId")
+	event, ok := loadEvent(context, func(ctx stdcontext.Context, eventId int64) (*models.Event, error) {
+		event, err := models.GetEventWith(ctx, eventId, userId, models.PermViewAttendees)
+
+		if errors.Is(err, models.ErrNotAllowed) {
+			event, err = models.GetEventWith(ctx, eventId, userId, models.PermCheckIn)
+		}
+
+		return event, err
+	})
+
+	if !ok {
+		return
+	}
+
+	stats, err := event.Stats(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch stats.", err)
+		return
+	}
+
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusOK, stats)
+}
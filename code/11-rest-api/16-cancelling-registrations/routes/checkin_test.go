@@ -0,0 +1,111 @@
+package routes_test
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+type myRegistration struct {
+	ID          int64
+	EventID     int64
+	CheckInCode string
+}
+
+func myRegistrations(t *testing.T, server *testutil.Server, token string) []myRegistration {
+	t.Helper()
+
+	var registrations []myRegistration
+	server.Do(http.MethodGet, "/me/registrations", nil, token).JSON(t, &registrations)
+	return registrations
+}
+
+func TestCheckIn(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	door := server.SignupAndLogin("door@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+
+	createEvent(t, server, owner, "Meetup")
+	createEvent(t, server, owner, "Other meetup")
+	server.Do(http.MethodPost, "/events/1/hosts", gin.H{"email": "door@example.com", "permissions": []string{"check_in"}}, owner)
+	server.Do(http.MethodPost, "/events/1/register", nil, guest)
+	server.Do(http.MethodPost, "/events/1/register", nil, other)
+	server.Do(http.MethodPost, "/events/2/register", nil, other)
+
+	registrations := myRegistrations(t, server, guest)
+
+	if len(registrations) != 1 || registrations[0].EventID != 1 || registrations[0].CheckInCode == "" {
+		t.Fatalf("got registrations %+v, want one for event 1 with a code", registrations)
+	}
+
+	code := registrations[0].CheckInCode
+	qrPath := "/me/registrations/" + strconv.FormatInt(registrations[0].ID, 10) + "/qr"
+
+	res := server.Do(http.MethodGet, qrPath, nil, guest)
+
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "image/png" || !bytes.HasPrefix(res.Body, []byte("\x89PNG")) {
+		t.Errorf("QR code: got status %d, type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	if res := server.Do(http.MethodGet, qrPath, nil, other); res.StatusCode != http.StatusNotFound {
+		t.Errorf("someone else's QR code: got status %d, want 404", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/checkin", gin.H{"code": code}, guest); res.StatusCode != http.StatusForbidden {
+		t.Errorf("check-in by a guest: got status %d, want 403", res.StatusCode)
+	}
+
+	var otherEventCode string
+
+	for _, registration := range myRegistrations(t, server, other) {
+		if registration.EventID == 2 {
+			otherEventCode = registration.CheckInCode
+		}
+	}
+
+	for _, invalid := range []string{"1.forged", "garbage", otherEventCode} {
+		if res := server.Do(http.MethodPost, "/events/1/checkin", gin.H{"code": invalid}, door); res.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("check-in with %q: got status %d, want 422", invalid, res.StatusCode)
+		}
+	}
+
+	var checkedIn struct {
+		CheckIn struct {
+			Email       string
+			CheckedInAt string
+		}
+	}
+	res = server.Do(http.MethodPost, "/events/1/checkin", gin.H{"code": code}, door)
+	res.JSON(t, &checkedIn)
+
+	if res.StatusCode != http.StatusOK || checkedIn.CheckIn.Email != "guest@example.com" || checkedIn.CheckIn.CheckedInAt == "" {
+		t.Fatalf("check-in: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var duplicate struct {
+		CheckIn struct{ CheckedInAt string }
+	}
+	res = server.Do(http.MethodPost, "/events/1/checkin", gin.H{"code": code}, owner)
+	res.JSON(t, &duplicate)
+
+	if res.StatusCode != http.StatusConflict || duplicate.CheckIn.CheckedInAt != checkedIn.CheckIn.CheckedInAt {
+		t.Errorf("second check-in: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var stats struct{ Registrations, CheckedIn int }
+	server.Do(http.MethodGet, "/events/1/stats", nil, door).JSON(t, &stats)
+
+	if stats.Registrations != 2 || stats.CheckedIn != 1 {
+		t.Errorf("got stats %+v, want 2 registrations and 1 checked in", stats)
+	}
+
+	if res := server.Do(http.MethodGet, "/events/1/stats", nil, guest); res.StatusCode != http.StatusForbidden {
+		t.Errorf("stats for a guest: got status %d, want 403", res.StatusCode)
+	}
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	stdcontext "context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"example.com/rest-api/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is the non-standard status (popularized by
+// nginx) for requests the client abandoned before the response was ready.
+const StatusClientClosedRequest = 499
+
+// respondWithError logs err together with the request ID and sends only the
+// sanitized message to the client. Server errors caused by a cancelled or
+// timed out request context are reported as 499 and 503 instead of 500,
+// and bodies over the route's LimitBody as 413.
+func respondWithError(context *gin.Context, status int, message string, err error) {
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+		message = "Request body too large."
+	}
+
+	if status >= 500 {
+		switch {
+		case errors.Is(err, stdcontext.Canceled):
+			status = StatusClientClosedRequest
+			message = "Request cancelled."
+		case errors.Is(err, stdcontext.DeadlineExceeded):
+			status = http.StatusServiceUnavailable
+			message = "Request timed out. Try again later."
+		}
+	}
+
+	level := slog.LevelWarn
+
+	if status >= 500 {
+		level = slog.LevelError
+	}
+
+	ctx := context.Request.Context()
+	logging.FromContext(ctx).Log(ctx, level, message, "status", status, "error", err)
+
+	context.JSON(status, gin.H{"message": message})
+}
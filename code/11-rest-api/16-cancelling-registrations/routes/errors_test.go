@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondWithError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   int
+	}{
+		{"server error", http.StatusInternalServerError, errors.New("boom"), http.StatusInternalServerError},
+		{"client went away", http.StatusInternalServerError, context.Canceled, StatusClientClosedRequest},
+		{"query timed out", http.StatusInternalServerError, fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{"client error is kept", http.StatusBadRequest, context.Canceled, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			respondWithError(c, tt.status, "Something failed.", tt.err)
+
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
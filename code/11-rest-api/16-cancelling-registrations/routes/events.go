@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,116 +10,148 @@ import (
 )
 
 func getEvents(context *gin.Context) {
-	events, err := models.GetAllEvents()
+	changedAt, err := models.EventsChangedAt(context.Request.Context())
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events. Try again later."})
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch events. Try again later.", err)
 		return
 	}
-	context.JSON(http.StatusOK, events)
-}
 
-func getEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	events, err := models.FindEvents(context.Request.Context(), eventFilter(context))
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch events. Try again later.", err)
 		return
 	}
+	respondCacheable(context, events, changedAt, false)
+}
 
-	event, err := models.GetEventByID(eventId)
+func getEvent(context *gin.Context) {
+	// Private events are reported as missing, so their ids don't reveal
+	// that they exist.
+	event, ok := loadVisibleEvent(context)
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event."})
+	if !ok {
 		return
 	}
 
-	context.JSON(http.StatusOK, event)
+	respondCacheable(context, event, event.UpdatedAt, event.Visibility == models.VisibilityPrivate)
 }
 
 func createEvent(context *gin.Context) {
 	var event models.Event
 	err := context.ShouldBindJSON(&event)
 
+	if err == nil {
+		err = event.Validate()
+	}
+
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
 		return
 	}
 
-	userId := context.GetInt64("userId")
-	event.UserID = userId
+	if !geocodeVenue(context, event.Venue) {
+		return
+	}
 
-	err = event.Save()
+	event.UserID = context.GetInt64("userId")
+	err = event.CheckCreator(context.Request.Context())
+
+	if errors.Is(err, models.ErrNotOrgMember) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Not a member of the organization."})
+		return
+	}
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create event. Try again later."})
+		respondWithError(context, http.StatusInternalServerError, "Could not check permissions.", err)
 		return
 	}
 
-	context.JSON(http.StatusCreated, gin.H{"message": "Event created!", "event": event})
-}
+	err = event.Save(context.Request.Context())
 
-func updateEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+	if errors.Is(err, models.ErrUnknownTag) {
+		respondWithError(context, http.StatusBadRequest, "Unknown tag.", err)
 		return
 	}
 
-	userId := context.GetInt64("userId")
-	event, err := models.GetEventByID(eventId)
+	if errors.Is(err, models.ErrIncompleteVenue) {
+		respondWithError(context, http.StatusBadRequest, "Venue needs both latitude and longitude.", err)
+		return
+	}
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event."})
+		respondWithError(context, http.StatusInternalServerError, "Could not create event. Try again later.", err)
 		return
 	}
 
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to update event."})
+	context.JSON(http.StatusCreated, gin.H{"message": "Event created!", "event": event})
+}
+
+func updateEvent(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermEditEvent)
+
+	if !ok {
 		return
 	}
 
 	var updatedEvent models.Event
-	err = context.ShouldBindJSON(&updatedEvent)
+	err := context.ShouldBindJSON(&updatedEvent)
+
+	if err == nil {
+		err = updatedEvent.Validate()
+	}
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
 		return
 	}
 
-	updatedEvent.ID = eventId
-	err = updatedEvent.Update()
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update event."})
+	if !geocodeVenue(context, updatedEvent.Venue) {
 		return
 	}
-	context.JSON(http.StatusOK, gin.H{"message": "Event updated successfully!"})
-}
 
-func deleteEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	updatedEvent.ID = event.ID
+	err = updatedEvent.Update(context.Request.Context())
+	if errors.Is(err, models.ErrUnknownTag) {
+		respondWithError(context, http.StatusBadRequest, "Unknown tag.", err)
+		return
+	}
+	if errors.Is(err, models.ErrIncompleteVenue) {
+		respondWithError(context, http.StatusBadRequest, "Venue needs both latitude and longitude.", err)
+		return
+	}
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+		respondWithError(context, http.StatusInternalServerError, "Could not update event.", err)
 		return
 	}
+	context.JSON(http.StatusOK, gin.H{"message": "Event updated successfully!"})
+}
 
-	userId := context.GetInt64("userId")
-	event, err := models.GetEventByID(eventId)
+func deleteEvent(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermDeleteEvent)
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event."})
+	if !ok {
 		return
 	}
 
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to delete event."})
+	err := event.Delete(context.Request.Context())
+
+	if errors.Is(err, models.ErrEventHasPaidOrders) {
+		respondWithError(context, http.StatusConflict, "The event has paid tickets that must be refunded first.", err)
 		return
 	}
 
-	err = event.Delete()
-
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete the event."})
+		respondWithError(context, http.StatusInternalServerError, "Could not delete the event.", err)
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully!"})
 }
+
+// eventFilter reads the event filter from the query string. Tags are
+// repeated, as in ?tag=go&tag=online, and events must have all of them.
+// ?org= limits the events to one organization.
+func eventFilter(context *gin.Context) models.EventFilter {
+	orgId, _ := strconv.ParseInt(context.Query("org"), 10, 64)
+	return models.EventFilter{Tags: context.QueryArray("tag"), OrgID: orgId}
+}
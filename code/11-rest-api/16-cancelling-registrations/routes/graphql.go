@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"example.com/rest-api/gql"
+	"example.com/rest-api/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// newGraphQLHandler parses the schema, which fails only if the schema and
+// the resolvers disagree.
+func newGraphQLHandler() (gin.HandlerFunc, error) {
+	handler, err := gql.NewHandler()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(context *gin.Context) {
+		ctx := gql.WithUserID(context.Request.Context(), context.GetInt64("userId"))
+		ctx = gql.WithAPIToken(ctx, middlewares.APIToken(context))
+		handler.ServeHTTP(context.Writer, context.Request.WithContext(ctx))
+	}, nil
+}
@@ -0,0 +1,101 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage
+	Errors []struct{ Message string }
+}
+
+func graphql(t *testing.T, server *testutil.Server, token, query string, variables gin.H) graphqlResponse {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/graphql", gin.H{"query": query, "variables": variables}, token)
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("graphql: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var body graphqlResponse
+	res.JSON(t, &body)
+	return body
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	const create = `mutation($input: EventInput!) { createEvent(input: $input) { id name host { email } } }`
+	input := gin.H{"input": gin.H{"name": "Meetup", "description": "A meetup", "location": "Berlin", "dateTime": "2025-01-01T15:30:00Z"}}
+
+	if res := graphql(t, server, "", create, input); len(res.Errors) != 1 || res.Errors[0].Message != "Not authorized." {
+		t.Errorf("anonymous createEvent: got %+v, want Not authorized.", res.Errors)
+	}
+
+	res := graphql(t, server, owner, create, input)
+
+	if len(res.Errors) > 0 || string(res.Data) != `{"createEvent":{"id":"1","name":"Meetup","host":{"email":"owner@example.com"}}}` {
+		t.Fatalf("createEvent: got %s, %+v", res.Data, res.Errors)
+	}
+
+	if res := graphql(t, server, guest, `mutation { deleteEvent(id: "1") }`, nil); len(res.Errors) != 1 || res.Errors[0].Message != "Not allowed." {
+		t.Errorf("guest deleteEvent: got %+v, want Not allowed.", res.Errors)
+	}
+
+	if res := graphql(t, server, guest, `mutation { registerForEvent(eventId: "1") }`, nil); len(res.Errors) > 0 {
+		t.Fatalf("registerForEvent: got %+v", res.Errors)
+	}
+
+	const attendees = `{ event(id: "1") { attendeeCount registrations { user { email } } } }`
+
+	if res := graphql(t, server, guest, attendees, nil); len(res.Errors) != 1 || res.Errors[0].Message != "Not allowed." {
+		t.Errorf("guest listing registrations: got %+v, want Not allowed.", res.Errors)
+	}
+
+	res = graphql(t, server, owner, attendees, nil)
+
+	if len(res.Errors) > 0 || string(res.Data) != `{"event":{"attendeeCount":1,"registrations":[{"user":{"email":"guest@example.com"}}]}}` {
+		t.Errorf("owner listing registrations: got %s, %+v", res.Data, res.Errors)
+	}
+
+	const update = `mutation($input: EventInput!) { updateEvent(id: "1", input: $input) { name visibility } }`
+	input = gin.H{"input": gin.H{"name": "Renamed", "description": "A meetup", "location": "Berlin", "dateTime": "2025-01-01T15:30:00Z", "visibility": "private"}}
+
+	if res := graphql(t, server, owner, update, input); len(res.Errors) > 0 || string(res.Data) != `{"updateEvent":{"name":"Renamed","visibility":"private"}}` {
+		t.Errorf("updateEvent: got %s, %+v", res.Data, res.Errors)
+	}
+
+	if res := graphql(t, server, "", `{ event(id: "1") { name } }`, nil); string(res.Data) != `{"event":null}` {
+		t.Errorf("anonymous query of a private event: got %s", res.Data)
+	}
+
+	if res := graphql(t, server, guest, `mutation { cancelRegistration(eventId: "1") }`, nil); len(res.Errors) > 0 {
+		t.Errorf("cancelRegistration: got %+v", res.Errors)
+	}
+
+	if res := graphql(t, server, guest, `mutation { registerForEvent(eventId: "1") }`, nil); len(res.Errors) != 1 || res.Errors[0].Message != "Could not find event." {
+		t.Errorf("registering for a hidden private event: got %+v, want Could not find event.", res.Errors)
+	}
+
+	if res := graphql(t, server, owner, `mutation { deleteEvent(id: "1") }`, nil); len(res.Errors) > 0 || string(res.Data) != `{"deleteEvent":true}` {
+		t.Errorf("deleteEvent: got %s, %+v", res.Data, res.Errors)
+	}
+}
+
+func TestGraphQLInvalidToken(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	// Like the optional REST authentication, an invalid token makes the
+	// request anonymous.
+	if res := graphql(t, server, "not-a-token", `{ me { id } }`, nil); string(res.Data) != `{"me":null}` {
+		t.Errorf("got %s, want no user", res.Data)
+	}
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"net/http"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/lifecycle"
+	"github.com/gin-gonic/gin"
+)
+
+// healthz tells the orchestrator the process is alive. It deliberately does
+// not touch the database, so a database outage doesn't cause restarts.
+func healthz(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz tells the load balancer whether this instance can serve traffic.
+// It fails as soon as the server starts shutting down.
+func readyz(context *gin.Context) {
+	if lifecycle.Draining() {
+		context.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "message": "Shutting down."})
+		return
+	}
+
+	err := db.Ready(context.Request.Context())
+
+	if err != nil {
+		context.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "message": "Database not ready."})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,222 @@
+package routes
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getEventHosts(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageHosts)
+
+	if !ok {
+		return
+	}
+
+	hosts, err := event.Hosts(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch co-hosts.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, hosts)
+}
+
+// setEventHost adds a co-host by email, or changes what an existing one
+// may do.
+func setEventHost(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageHosts)
+
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email       string              `binding:"required,email"`
+		Permissions []models.Permission `binding:"required"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(context.Request.Context(), request.Email)
+
+	if errors.Is(err, models.ErrUserNotFound) {
+		respondWithError(context, http.StatusNotFound, "No user with this email.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch user.", err)
+		return
+	}
+
+	err = event.SetHost(context.Request.Context(), user.ID, request.Permissions)
+
+	if errors.Is(err, models.ErrInvalidPermission) {
+		respondWithError(context, http.StatusBadRequest, "Permissions must be edit_details, view_attendees or check_in.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrHostIsOwner) {
+		respondWithError(context, http.StatusBadRequest, "The owner can't be a co-host.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not save co-host.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Co-host saved!"})
+}
+
+// removeEventHost lets owners remove co-hosts and co-hosts step down.
+func removeEventHost(context *gin.Context) {
+	hostId, err := strconv.ParseInt(context.Param("userId"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse user id.", err)
+		return
+	}
+
+	permission := models.PermManageHosts
+
+	if hostId == context.GetInt64("userId") {
+		permission = ""
+	}
+
+	event, ok := loadEventWith(context, permission)
+
+	if !ok {
+		return
+	}
+
+	err = event.RemoveHost(context.Request.Context(), hostId)
+
+	if errors.Is(err, models.ErrHostNotFound) {
+		respondWithError(context, http.StatusNotFound, "User is not a co-host of the event.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not remove co-host.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Co-host removed!"})
+}
+
+func transferEventOwnership(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageHosts)
+
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email string `binding:"required,email"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	user, err := models.GetUserByEmail(context.Request.Context(), request.Email)
+
+	if errors.Is(err, models.ErrUserNotFound) {
+		respondWithError(context, http.StatusNotFound, "No user with this email.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch user.", err)
+		return
+	}
+
+	err = event.TransferOwnership(context.Request.Context(), user.ID)
+
+	if errors.Is(err, models.ErrNotOrgMember) {
+		respondWithError(context, http.StatusBadRequest, "The new owner must be a member of the organization.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not transfer the event.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Event transferred!", "event": event})
+}
+
+func getEventAttendees(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermViewAttendees)
+
+	if !ok {
+		return
+	}
+
+	attendees, err := event.Attendees(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch attendees.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, attendees)
+}
+
+// loadEventWith fetches the event in the :id parameter and checks that the
+// current user has the permission on it. An empty permission skips the
+// check. It writes the error response and returns false if either fails.
+func loadEventWith(context *gin.Context, permission models.Permission) (*models.Event, bool) {
+	return loadEvent(context, func(ctx stdcontext.Context, eventId int64) (*models.Event, error) {
+		return models.GetEventWith(ctx, eventId, context.GetInt64("userId"), permission)
+	})
+}
+
+// loadVisibleEvent fetches the event in the :id parameter if the current
+// user, or the ?invite= token, may see it.
+func loadVisibleEvent(context *gin.Context) (*models.Event, bool) {
+	return loadEvent(context, func(ctx stdcontext.Context, eventId int64) (*models.Event, error) {
+		return models.GetVisibleEvent(ctx, eventId, context.GetInt64("userId"), context.Query("invite"))
+	})
+}
+
+// loadEvent parses the :id parameter, fetches the event with get and
+// writes the error response if that fails.
+func loadEvent(context *gin.Context, get func(ctx stdcontext.Context, eventId int64) (*models.Event, error)) (*models.Event, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse event id.", err)
+		return nil, false
+	}
+
+	event, err := get(context.Request.Context(), eventId)
+
+	if errors.Is(err, models.ErrEventNotFound) {
+		respondWithError(context, http.StatusNotFound, "Could not find event.", err)
+		return nil, false
+	}
+
+	if errors.Is(err, models.ErrNotAllowed) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Not allowed."})
+		return nil, false
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch event.", err)
+		return nil, false
+	}
+
+	return event, true
+}
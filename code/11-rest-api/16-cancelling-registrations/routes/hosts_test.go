@@ -0,0 +1,110 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCoHosts(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	cohost := server.SignupAndLogin("cohost@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+	createEvent(t, server, owner, "Meetup")
+
+	server.Do(http.MethodPost, "/events/1/register", nil, guest)
+
+	res := server.Do(http.MethodPost, "/events/1/hosts", gin.H{
+		"email":       "cohost@example.com",
+		"permissions": []string{"edit_details", "view_attendees"},
+	}, guest)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("add co-host by guest: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/hosts", gin.H{
+		"email":       "cohost@example.com",
+		"permissions": []string{"edit_details", "view_attendees"},
+	}, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("add co-host: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/hosts", gin.H{
+		"email":       "guest@example.com",
+		"permissions": []string{"delete"},
+	}, owner)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("grant delete: got status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	event := gin.H{
+		"name":        "Renamed",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+	}
+
+	res = server.Do(http.MethodPut, "/events/1", event, cohost)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("update by co-host: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, cohost)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by co-host: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	var attendees []struct{ Email string }
+	res = server.Do(http.MethodGet, "/events/1/attendees", nil, cohost)
+	res.JSON(t, &attendees)
+
+	if len(attendees) != 1 || attendees[0].Email != "guest@example.com" {
+		t.Errorf("got attendees %+v", attendees)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1/attendees", nil, guest)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("attendees for guest: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/transfer", gin.H{"email": "cohost@example.com"}, cohost)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("transfer by co-host: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/transfer", gin.H{"email": "cohost@example.com"}, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("transfer: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, owner)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by previous owner: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, cohost)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("delete by new owner: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestPrivateEventHostsLookMissing(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	stranger := server.SignupAndLogin("stranger@example.com", "secret")
+	createEventWithVisibility(t, server, owner, "Secret", "private")
+
+	res := server.Do(http.MethodGet, "/events/1/hosts", nil, stranger)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("hosts of a private event: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1/hosts", nil, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("hosts for the owner: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
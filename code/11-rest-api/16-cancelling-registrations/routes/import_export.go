@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"mime"
+	"net/http"
+
+	"example.com/rest-api/eventio"
+	"example.com/rest-api/logging"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportRows  = 5000
+	maxImportBytes = 10 << 20
+)
+
+// importEvents creates events from a CSV or NDJSON upload. Every row is
+// validated first; with ?dry_run=true only the validation report is
+// returned, otherwise the events are created all at once or not at all.
+func importEvents(context *gin.Context) {
+	userId := context.GetInt64("userId")
+
+	var rows []eventio.Row
+	var err error
+
+	switch importFormat(context) {
+	case "csv":
+		rows, err = eventio.ReadCSV(context.Request.Body, maxImportRows)
+	case "ndjson":
+		rows, err = eventio.ReadNDJSON(context.Request.Body, maxImportRows)
+	default:
+		context.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Send text/csv or application/x-ndjson."})
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse import data.", err)
+		return
+	}
+
+	rowErrors := eventio.Errors(rows)
+
+	if context.Query("dry_run") == "true" {
+		context.JSON(http.StatusOK, gin.H{"valid": len(rowErrors) == 0, "rows": len(rows), "errors": rowErrors})
+		return
+	}
+
+	if len(rowErrors) > 0 {
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Import rejected, no events were created.", "errors": rowErrors})
+		return
+	}
+
+	events := make([]models.Event, len(rows))
+
+	for i, row := range rows {
+		events[i] = row.Event
+		events[i].UserID = userId
+	}
+
+	err = models.SaveAll(context.Request.Context(), events)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not import events. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Events imported!", "imported": len(events)})
+}
+
+func importFormat(context *gin.Context) string {
+	if format := context.Query("format"); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(context.ContentType())
+
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson":
+		return "ndjson"
+	}
+
+	return ""
+}
+
+// exportEvents streams all public events as CSV or as a JSON array. It needs
+// no login, as the same events are listed by GET /events.
+func exportEvents(context *gin.Context) {
+	var writer eventio.Writer
+
+	switch context.DefaultQuery("format", "json") {
+	case "csv":
+		context.Header("Content-Type", "text/csv; charset=utf-8")
+		context.Header("Content-Disposition", `attachment; filename="events.csv"`)
+		writer = eventio.NewCSVWriter(context.Writer)
+	case "json":
+		context.Header("Content-Type", "application/json; charset=utf-8")
+		writer = eventio.NewJSONWriter(context.Writer)
+	default:
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unknown export format, use csv or json."})
+		return
+	}
+
+	context.Status(http.StatusOK)
+
+	err := models.EachEvent(context.Request.Context(), func(event models.Event) error {
+		if event.Visibility != models.VisibilityPublic {
+			return nil
+		}
+
+		return writer.Write(event)
+	})
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		// The status line is already sent, so the client sees a truncated
+		// document rather than an error response.
+		ctx := context.Request.Context()
+		logging.FromContext(ctx).Error("Could not export events", "error", err)
+		context.Abort()
+	}
+}
@@ -0,0 +1,143 @@
+package routes_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+
+	"example.com/rest-api/testutil"
+)
+
+const validCSV = `name,description,location,dateTime
+Meetup,A meetup,Berlin,2025-01-01T15:30:00Z
+Workshop,A workshop,Online,2025-02-01T10:00:00Z
+`
+
+const invalidCSV = `name,description,location,dateTime
+Meetup,A meetup,Berlin,2025-01-01T15:30:00Z
+Workshop,,Online,next week
+`
+
+func importCSV(server *testutil.Server, token, query, body string) *testutil.Response {
+	return server.DoWithHeader(http.MethodPost, "/events/import"+query, []byte(body), http.Header{
+		"Authorization": {token},
+		"Content-Type":  {"text/csv"},
+	})
+}
+
+func countEvents(t *testing.T, server *testutil.Server) int {
+	t.Helper()
+
+	var events []map[string]any
+	server.Do(http.MethodGet, "/events", nil, "").JSON(t, &events)
+	return len(events)
+}
+
+func TestImportDryRun(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+
+	res := importCSV(server, token, "?dry_run=true", invalidCSV)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var report struct {
+		Valid  bool
+		Rows   int
+		Errors []struct {
+			Line    int
+			Message string
+		}
+	}
+	res.JSON(t, &report)
+
+	if report.Valid || report.Rows != 2 || len(report.Errors) != 1 || report.Errors[0].Line != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if n := countEvents(t, server); n != 0 {
+		t.Errorf("dry run created %d events", n)
+	}
+}
+
+func TestImportIsAllOrNothing(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+
+	res := importCSV(server, token, "", invalidCSV)
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("invalid import: got status %d, want %d", res.StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	if n := countEvents(t, server); n != 0 {
+		t.Fatalf("rejected import created %d events", n)
+	}
+
+	res = importCSV(server, token, "", validCSV)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("valid import: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if n := countEvents(t, server); n != 2 {
+		t.Errorf("got %d events, want 2", n)
+	}
+}
+
+func TestImportNDJSON(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+
+	body := `{"name": "Meetup", "description": "A meetup", "location": "Berlin", "dateTime": "2025-01-01T15:30:00Z"}`
+	res := server.DoWithHeader(http.MethodPost, "/events/import", []byte(body), http.Header{
+		"Authorization": {token},
+		"Content-Type":  {"application/x-ndjson"},
+	})
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.DoWithHeader(http.MethodPost, "/events/import", []byte(body), http.Header{
+		"Authorization": {token},
+		"Content-Type":  {"application/xml"},
+	})
+
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("unknown format: got status %d, want %d", res.StatusCode, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestExport(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+	importCSV(server, token, "", validCSV)
+
+	res := server.Do(http.MethodGet, "/events/export?format=csv", nil, "")
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: got status %d, content type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(res.Body))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || records[1][1] != "Meetup" || records[2][1] != "Workshop" {
+		t.Errorf("unexpected csv export %q", records)
+	}
+
+	var events []map[string]any
+	res = server.Do(http.MethodGet, "/events/export?format=json", nil, "")
+	res.JSON(t, &events)
+
+	if len(events) != 2 {
+		t.Errorf("json: got %d events, want 2", len(events))
+	}
+
+	res = server.Do(http.MethodGet, "/events/export?format=xml", nil, "")
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown format: got status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
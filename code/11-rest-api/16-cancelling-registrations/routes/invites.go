@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/rest-api/mail"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getEventInvites(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageInvites)
+
+	if !ok {
+		return
+	}
+
+	invites, err := event.Invites(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch invites.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, invites)
+}
+
+// createEventInvite creates a shareable invite link, or emails a personal
+// invite when an email address is given. The link token is only returned
+// for link invites; personal invites reach their recipient by email.
+func createEventInvite(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageInvites)
+
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email   string `binding:"omitempty,email"`
+		MaxUses int    `binding:"min=0"`
+		// ExpiresInHours is how long the invite can be used, 0 for ever.
+		ExpiresInHours int `binding:"min=0"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	var expiresAt *time.Time
+
+	if request.ExpiresInHours > 0 {
+		t := time.Now().UTC().Add(time.Duration(request.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	invite, err := event.CreateInvite(context.Request.Context(), context.GetInt64("userId"), request.Email, request.MaxUses, expiresAt)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create invite.", err)
+		return
+	}
+
+	token := models.InviteToken(invite.ID)
+
+	if invite.Email == "" {
+		context.JSON(http.StatusCreated, gin.H{"message": "Invite created!", "invite": invite, "token": token})
+		return
+	}
+
+	err = mailer.Send(context.Request.Context(), mail.Message{
+		To:      invite.Email,
+		Subject: fmt.Sprintf("You are invited to %s", event.Name),
+		Body: fmt.Sprintf("You have been invited to %s on %s.\n\n"+
+			"See the event at\n\n    GET /events/%d?invite=%s\n\n"+
+			"and register with\n\n    POST /events/%d/register?invite=%s\n",
+			event.Name, event.DateTime.Format("2 January 2006 15:04 MST"), event.ID, token, event.ID, token),
+	})
+
+	if err != nil {
+		respondWithError(context, http.StatusBadGateway, "Could not send the invite email. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Invite sent!", "invite": invite})
+}
+
+func revokeEventInvite(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermManageInvites)
+
+	if !ok {
+		return
+	}
+
+	inviteId, err := strconv.ParseInt(context.Param("inviteId"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse invite id.", err)
+		return
+	}
+
+	err = event.RevokeInvite(context.Request.Context(), inviteId)
+
+	if errors.Is(err, models.ErrInviteNotFound) {
+		respondWithError(context, http.StatusNotFound, "Invite not found.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not revoke invite.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Invite revoked!"})
+}
@@ -0,0 +1,224 @@
+package routes_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/mail"
+	"example.com/rest-api/routes"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func createEventWithVisibility(t *testing.T, server *testutil.Server, token, name, visibility string) {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/events", gin.H{
+		"name":        name,
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+		"visibility":  visibility,
+	}, token)
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create event: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestEventVisibility(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEventWithVisibility(t, server, owner, "Public", "public")
+	createEventWithVisibility(t, server, owner, "Unlisted", "unlisted")
+	createEventWithVisibility(t, server, owner, "Private", "private")
+
+	var events []struct{ Name string }
+	server.Do(http.MethodGet, "/events", nil, "").JSON(t, &events)
+
+	if len(events) != 1 || events[0].Name != "Public" {
+		t.Errorf("listed events %+v, want only the public one", events)
+	}
+
+	// Hidden events answer like missing ones, whatever the method.
+	for _, test := range []struct {
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{http.MethodGet, "/events/2", "", http.StatusOK},
+		{http.MethodGet, "/events/3", "", http.StatusNotFound},
+		{http.MethodGet, "/events/3", guest, http.StatusNotFound},
+		{http.MethodGet, "/events/3", owner, http.StatusOK},
+		{http.MethodGet, "/events/99", guest, http.StatusNotFound},
+		{http.MethodDelete, "/events/2", guest, http.StatusForbidden},
+		{http.MethodDelete, "/events/3", guest, http.StatusNotFound},
+		{http.MethodDelete, "/events/99", guest, http.StatusNotFound},
+	} {
+		res := server.Do(test.method, test.path, nil, test.token)
+		if res.StatusCode != test.want {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.path, res.StatusCode, test.want)
+		}
+	}
+
+	res := server.Do(http.MethodPost, "/events/2/register", nil, guest)
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("register for unlisted event: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/events/3/register", nil, guest)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("register for private event without invite: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestInviteLinks(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	first := server.SignupAndLogin("first@example.com", "secret")
+	second := server.SignupAndLogin("second@example.com", "secret")
+	createEventWithVisibility(t, server, owner, "Private", "private")
+
+	res := server.Do(http.MethodPost, "/events/1/invites", gin.H{"maxUses": 1}, first)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("invite by stranger: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	var created struct{ Token string }
+	res = server.Do(http.MethodPost, "/events/1/invites", gin.H{"maxUses": 1}, owner)
+	res.JSON(t, &created)
+
+	if res.StatusCode != http.StatusCreated || created.Token == "" {
+		t.Fatalf("create invite: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1?invite="+created.Token, nil, "")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("view with invite: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+created.Token+"x", nil, first)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("register with forged invite: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+created.Token, nil, first)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("register with invite: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1", nil, first)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("view as registered user: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+created.Token, nil, second)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("register with used up invite: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	server.Do(http.MethodPost, "/events/1/invites", gin.H{}, owner).JSON(t, &created)
+
+	res = server.Do(http.MethodDelete, "/events/1/invites/2", nil, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("revoke: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+created.Token, nil, second)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("register with revoked invite: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	var invites []struct {
+		Uses       int
+		LastUsedAt *string
+		RevokedAt  *string
+	}
+	server.Do(http.MethodGet, "/events/1/invites", nil, owner).JSON(t, &invites)
+
+	if len(invites) != 2 || invites[0].Uses != 1 || invites[0].LastUsedAt == nil || invites[1].RevokedAt == nil {
+		t.Errorf("got invites %+v", invites)
+	}
+}
+
+func TestEmailInvites(t *testing.T) {
+	outbox := &mail.Outbox{}
+	routes.SetMailer(outbox)
+	t.Cleanup(func() { routes.SetMailer(mail.LogMailer{}) })
+
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	invitee := server.SignupAndLogin("invitee@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+	createEventWithVisibility(t, server, owner, "Private", "private")
+
+	res := server.Do(http.MethodPost, "/events/1/invites", gin.H{"email": "Invitee@example.com"}, owner)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create email invite: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	messages := outbox.Messages()
+	if len(messages) != 1 || messages[0].To != "Invitee@example.com" {
+		t.Fatalf("got messages %+v", messages)
+	}
+
+	token := mailedInviteToken(t, messages[0])
+
+	// Sign up doesn't verify email addresses, so having the invited address
+	// isn't enough without the mailed token.
+	res = server.Do(http.MethodGet, "/events/1", nil, invitee)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("view as invitee without token: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register", nil, invitee)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("register as invitee without token: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1?invite="+token, nil, invitee)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("view as invitee: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+token, nil, other)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("register with someone else's invite: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events/1/register?invite="+token, nil, invitee)
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("register as invitee: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	// An expired email invite no longer shows the event.
+	server.Do(http.MethodPost, "/events/1/invites", gin.H{"email": "other@example.com"}, owner)
+	token = mailedInviteToken(t, outbox.Messages()[1])
+
+	_, err := db.DB.Exec("UPDATE event_invites SET expires_at = ? WHERE email = 'other@example.com'", time.Now().UTC().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = server.Do(http.MethodGet, "/events/1?invite="+token, nil, other)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("view with expired invite: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func mailedInviteToken(t *testing.T, msg mail.Message) string {
+	t.Helper()
+
+	match := regexp.MustCompile(`invite=(\S+)`).FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no invite token in %q", msg.Body)
+	}
+
+	return match[1]
+}
@@ -0,0 +1,11 @@
+package routes
+
+import "example.com/rest-api/mail"
+
+// mailer sends the emails triggered by requests, such as invitations.
+var mailer mail.Mailer = mail.LogMailer{}
+
+// SetMailer sets the Mailer used for outgoing email.
+func SetMailer(m mail.Mailer) {
+	mailer = m
+}
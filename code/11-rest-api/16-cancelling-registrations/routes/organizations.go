@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/mail"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func createOrganization(context *gin.Context) {
+	var org models.Organization
+	err := context.ShouldBindJSON(&org)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	err = org.Save(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create organization. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Organization created!", "organization": org})
+}
+
+func getMyOrganizations(context *gin.Context) {
+	orgs, err := models.GetOrganizationsForUser(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch organizations. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, orgs)
+}
+
+func getOrganization(context *gin.Context) {
+	org, role, ok := loadOrganization(context)
+
+	if !ok {
+		return
+	}
+
+	if role == "" {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Not a member of the organization."})
+		return
+	}
+
+	members, err := models.GetOrgMembers(context.Request.Context(), org.ID)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch members.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"organization": org, "members": members})
+}
+
+// inviteToOrganization emails an invitation link. Admins may invite
+// members and admins; only owners may invite owners.
+func inviteToOrganization(context *gin.Context) {
+	org, role, ok := loadOrganization(context)
+
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email string `binding:"required,email"`
+		Role  string
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	if request.Role == "" {
+		request.Role = models.OrgMember
+	}
+
+	if !models.ValidOrgRole(request.Role) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Role must be owner, admin or member."})
+		return
+	}
+
+	if !models.OrgRoleAtLeast(role, models.OrgAdmin) || !models.OrgRoleAtLeast(role, request.Role) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to invite members with this role."})
+		return
+	}
+
+	invitation, token, err := models.InviteToOrg(context.Request.Context(), org.ID, request.Email, request.Role, context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create invitation.", err)
+		return
+	}
+
+	err = mailer.Send(context.Request.Context(), mail.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You are invited to join %s", org.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\n\n"+
+			"Sign up or log in with this email address, then accept the invitation with\n\n"+
+			"    POST /invitations/%s/accept\n\n"+
+			"The invitation expires on %s.\n",
+			org.Name, invitation.Role, token, invitation.ExpiresAt.Format("2 January 2006")),
+	})
+
+	if err != nil {
+		respondWithError(context, http.StatusBadGateway, "Could not send the invitation email. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Invitation sent!", "invitation": invitation})
+}
+
+func acceptInvitation(context *gin.Context) {
+	invitation, err := models.AcceptInvitation(context.Request.Context(), context.Param("token"), context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrInvitationNotFound) {
+		respondWithError(context, http.StatusNotFound, "Invitation not found or expired.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInvitationEmailDiffer) {
+		respondWithError(context, http.StatusForbidden, "The invitation was sent to a different email address.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not accept invitation.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Invitation accepted!", "orgId": invitation.OrgID})
+}
+
+// setOrganizationMemberRole is reserved for owners.
+func setOrganizationMemberRole(context *gin.Context) {
+	org, role, ok := loadOrganization(context)
+
+	if !ok {
+		return
+	}
+
+	memberId, err := strconv.ParseInt(context.Param("userId"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse user id.", err)
+		return
+	}
+
+	var request struct {
+		Role string `binding:"required"`
+	}
+	err = context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	if role != models.OrgOwner {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Only owners can change roles."})
+		return
+	}
+
+	err = models.SetOrgRole(context.Request.Context(), org.ID, memberId, request.Role)
+
+	if !respondToMembershipError(context, err) {
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Role updated!"})
+}
+
+// removeOrganizationMember lets admins remove members and admins, owners
+// remove anyone, and every member leave.
+func removeOrganizationMember(context *gin.Context) {
+	org, role, ok := loadOrganization(context)
+
+	if !ok {
+		return
+	}
+
+	memberId, err := strconv.ParseInt(context.Param("userId"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse user id.", err)
+		return
+	}
+
+	if memberId != context.GetInt64("userId") {
+		memberRole, err := models.OrgRole(context.Request.Context(), org.ID, memberId)
+
+		if err != nil {
+			respondWithError(context, http.StatusInternalServerError, "Could not check permissions.", err)
+			return
+		}
+
+		if !models.OrgRoleAtLeast(role, models.OrgAdmin) || !models.OrgRoleAtLeast(role, memberRole) {
+			context.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to remove this member."})
+			return
+		}
+	}
+
+	err = models.RemoveOrgMember(context.Request.Context(), org.ID, memberId)
+
+	if !respondToMembershipError(context, err) {
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Member removed!"})
+}
+
+// loadOrganization fetches the organization in the :id parameter and the
+// role of the current user in it ("" for non-members). It writes the error
+// response and returns false if that fails.
+func loadOrganization(context *gin.Context) (*models.Organization, string, bool) {
+	orgId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse organization id.", err)
+		return nil, "", false
+	}
+
+	org, err := models.GetOrganizationByID(context.Request.Context(), orgId)
+
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		respondWithError(context, http.StatusNotFound, "Organization not found.", err)
+		return nil, "", false
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch organization.", err)
+		return nil, "", false
+	}
+
+	role, err := models.OrgRole(context.Request.Context(), orgId, context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not check permissions.", err)
+		return nil, "", false
+	}
+
+	return org, role, true
+}
+
+// respondToMembershipError writes the response for an error from
+// SetOrgRole or RemoveOrgMember and returns whether there was none.
+func respondToMembershipError(context *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrNotOrgMember):
+		respondWithError(context, http.StatusNotFound, "User is not a member of the organization.", err)
+	case errors.Is(err, models.ErrInvalidOrgRole):
+		respondWithError(context, http.StatusBadRequest, "Role must be owner, admin or member.", err)
+	case errors.Is(err, models.ErrLastOwner):
+		respondWithError(context, http.StatusConflict, "The organization needs at least one owner.", err)
+	default:
+		respondWithError(context, http.StatusInternalServerError, "Could not update membership.", err)
+	}
+
+	return false
+}
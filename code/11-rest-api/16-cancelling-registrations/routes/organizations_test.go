@@ -0,0 +1,177 @@
+package routes_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"example.com/rest-api/mail"
+	"example.com/rest-api/routes"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+var invitationToken = regexp.MustCompile(`/invitations/([^/\s]+)/accept`)
+
+// invite invites email to the organization and returns the token from the
+// invitation email.
+func invite(t *testing.T, server *testutil.Server, outbox *mail.Outbox, token, email, role string) string {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/orgs/1/invitations", gin.H{"email": email, "role": role}, token)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("invite %s: got status %d, body %s", email, res.StatusCode, res.Body)
+	}
+
+	messages := outbox.Messages()
+	last := messages[len(messages)-1]
+
+	if last.To != email {
+		t.Fatalf("invitation sent to %s, want %s", last.To, email)
+	}
+
+	match := invitationToken.FindStringSubmatch(last.Body)
+	if match == nil {
+		t.Fatalf("no invitation link in %q", last.Body)
+	}
+
+	return match[1]
+}
+
+func TestOrganizationEvents(t *testing.T) {
+	outbox := &mail.Outbox{}
+	routes.SetMailer(outbox)
+	t.Cleanup(func() { routes.SetMailer(mail.LogMailer{}) })
+
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	organizer := server.SignupAndLogin("organizer@example.com", "secret")
+	admin := server.SignupAndLogin("admin@example.com", "secret")
+	outsider := server.SignupAndLogin("outsider@example.com", "secret")
+
+	res := server.Do(http.MethodPost, "/orgs", gin.H{"name": "Gophers"}, owner)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create organization: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/orgs/1/invitations", gin.H{"email": "x@example.com"}, outsider)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("invite by outsider: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	organizerInvite := invite(t, server, outbox, owner, "organizer@example.com", "member")
+
+	res = server.Do(http.MethodPost, "/invitations/"+organizerInvite+"/accept", nil, outsider)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("accept by wrong user: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/invitations/"+organizerInvite+"/accept", nil, organizer)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("accept: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/invitations/"+organizerInvite+"/accept", nil, organizer)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("accept twice: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	adminInvite := invite(t, server, outbox, owner, "admin@example.com", "admin")
+	server.Do(http.MethodPost, "/invitations/"+adminInvite+"/accept", nil, admin)
+
+	event := gin.H{
+		"name":        "Gopher Meetup",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+		"orgId":       1,
+	}
+
+	res = server.Do(http.MethodPost, "/events", event, outsider)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("create for organization by outsider: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/events", event, organizer)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create for organization: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPut, "/events/1", event, admin)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("update by organization admin: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPut, "/events/1", event, outsider)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("update by outsider: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	// The organizer leaves: the event stays manageable by the organization
+	// but not by them.
+	res = server.Do(http.MethodDelete, "/orgs/1/members/2", nil, organizer)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("leave: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPut, "/events/1", event, organizer)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("update after leaving: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("delete by organization owner: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestOrganizationRoles(t *testing.T) {
+	outbox := &mail.Outbox{}
+	routes.SetMailer(outbox)
+	t.Cleanup(func() { routes.SetMailer(mail.LogMailer{}) })
+
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	admin := server.SignupAndLogin("admin@example.com", "secret")
+
+	server.Do(http.MethodPost, "/orgs", gin.H{"name": "Gophers"}, owner)
+	token := invite(t, server, outbox, owner, "admin@example.com", "admin")
+	server.Do(http.MethodPost, "/invitations/"+token+"/accept", nil, admin)
+
+	res := server.Do(http.MethodPost, "/orgs/1/invitations", gin.H{"email": "new@example.com", "role": "owner"}, admin)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("admin invites owner: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPut, "/orgs/1/members/1", gin.H{"role": "member"}, admin)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("admin changes role: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/orgs/1/members/1", nil, admin)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("admin removes owner: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/orgs/1/members/1", nil, owner)
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("last owner leaves: got status %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+
+	res = server.Do(http.MethodPut, "/orgs/1/members/2", gin.H{"role": "owner"}, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("promote to owner: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var org struct {
+		Members []struct {
+			Email string
+			Role  string
+		}
+	}
+	server.Do(http.MethodGet, "/orgs/1", nil, admin).JSON(t, &org)
+
+	if len(org.Members) != 2 || org.Members[0].Role != "owner" || org.Members[1].Role != "owner" {
+		t.Errorf("got members %+v", org.Members)
+	}
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,25 +9,46 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// registerForEvent registers the current user. Private events the user
+// can't see are reported as missing, as getEvent does, rather than as
+// invite-only.
 func registerForEvent(context *gin.Context) {
 	userId := context.GetInt64("userId")
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+	event, ok := loadVisibleEvent(context)
+
+	if !ok {
 		return
 	}
 
-	event, err := models.GetEventByID(eventId)
+	err := event.Register(context.Request.Context(), userId, context.Query("invite"))
 
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event."})
+	if errors.Is(err, models.ErrEventNotFound) {
+		respondWithError(context, http.StatusNotFound, "Could not find event.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInviteRequired) {
+		respondWithError(context, http.StatusForbidden, "This event is invite-only.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		respondWithError(context, http.StatusConflict, "Already registered.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrTicketRequired) {
+		respondWithError(context, http.StatusConflict, "This event requires a ticket.", err)
 		return
 	}
 
-	err = event.Register(userId)
+	if errors.Is(err, models.ErrInvalidInvite) {
+		respondWithError(context, http.StatusForbidden, "The invite is not valid.", err)
+		return
+	}
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register user for event."})
+		respondWithError(context, http.StatusInternalServerError, "Could not register user for event.", err)
 		return
 	}
 
@@ -36,14 +58,28 @@ func registerForEvent(context *gin.Context) {
 func cancelRegistration(context *gin.Context) {
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse event id.", err)
+		return
+	}
 
 	var event models.Event
 	event.ID = eventId
 
-	err = event.CancelRegistration(userId)
+	err = event.CancelRegistration(context.Request.Context(), userId)
+
+	if errors.Is(err, models.ErrRegistrationNotFound) {
+		respondWithError(context, http.StatusNotFound, "Not registered for this event.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrPaymentsDisabled) {
+		respondWithError(context, http.StatusServiceUnavailable, "Refunds are not available.", err)
+		return
+	}
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not cancel registration."})
+		respondWithError(context, http.StatusInternalServerError, "Could not cancel registration.", err)
 		return
 	}
 
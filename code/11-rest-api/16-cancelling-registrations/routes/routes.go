@@ -1,22 +1,104 @@
 package routes
 
 import (
+	"fmt"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/metrics"
 	"example.com/rest-api/middlewares"
+	"example.com/rest-api/models"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(server *gin.Engine) {
-	server.GET("/events", getEvents)    // GET, POST, PUT, PATCH, DELETE
-	server.GET("/events/:id", getEvent) // /events/1, /events/5
+// RegisterRoutes adds the middlewares and routes to the server. It fails if
+// the GraphQL schema can't be parsed.
+func RegisterRoutes(server *gin.Engine) error {
+	graphqlHandler, err := newGraphQLHandler()
+
+	if err != nil {
+		return fmt.Errorf("GraphQL schema: %w", err)
+	}
+
+	server.Use(middlewares.RequestID, middlewares.Trace, middlewares.AccessLog, metrics.Middleware)
+	server.Use(middlewares.SecurityHeaders(securityHeaders), middlewares.CORS(corsConfig), middlewares.LimitBody(maxBodyBytes))
+	metrics.RegisterDB(db.DB)
+
+	// API tokens only reach the routes their scopes allow.
+	readEvents := middlewares.RequireScope(models.ScopeEventsRead)
+	writeEvents := middlewares.RequireScope(models.ScopeEventsWrite)
+	readRegistrations := middlewares.RequireScope(models.ScopeRegistrationsRead)
+	writeRegistrations := middlewares.RequireScope(models.ScopeRegistrationsWrite)
+	readOrgs := middlewares.RequireScope(models.ScopeOrgsRead)
+	writeOrgs := middlewares.RequireScope(models.ScopeOrgsWrite)
+
+	server.GET("/events", getEvents)                                                  // GET, POST, PUT, PATCH, DELETE
+	server.GET("/events/:id", middlewares.OptionalAuthenticate, readEvents, getEvent) // /events/1, /events/5
+	server.GET("/events/export", exportEvents)
+	server.GET("/events/facets", getEventFacets)
+	server.GET("/events/nearby", getNearbyEvents)
+	server.GET("/tags", getTags)
+	server.GET("/events/:id/tickets", middlewares.OptionalAuthenticate, readEvents, getTicketTypes)
+	server.POST("/payments/webhook", middlewares.LimitBody(maxWebhookBytes), paymentWebhook)
+	// Each GraphQL field checks the scope it needs.
+	server.POST("/graphql", middlewares.OptionalAuthenticate, graphqlHandler)
 
 	authenticated := server.Group("/")
 	authenticated.Use(middlewares.Authenticate)
-	authenticated.POST("/events", createEvent)
-	authenticated.PUT("/events/:id", updateEvent)
-	authenticated.DELETE("/events/:id", deleteEvent)
-	authenticated.POST("/events/:id/register", registerForEvent)
-	authenticated.DELETE("/events/:id/register", cancelRegistration)
+	authenticated.POST("/events", writeEvents, createEvent)
+	authenticated.POST("/events/import", middlewares.LimitBody(maxImportBytes), writeEvents, importEvents)
+	authenticated.PUT("/events/:id", writeEvents, updateEvent)
+	authenticated.DELETE("/events/:id", writeEvents, deleteEvent)
+	authenticated.POST("/events/:id/register", writeRegistrations, registerForEvent)
+	authenticated.DELETE("/events/:id/register", writeRegistrations, cancelRegistration)
+	authenticated.GET("/events/:id/attendees", readEvents, getEventAttendees)
+	authenticated.GET("/events/:id/hosts", readEvents, getEventHosts)
+	authenticated.POST("/events/:id/hosts", writeEvents, setEventHost)
+	authenticated.DELETE("/events/:id/hosts/:userId", writeEvents, removeEventHost)
+	authenticated.POST("/events/:id/transfer", writeEvents, transferEventOwnership)
+	authenticated.GET("/events/:id/invites", readEvents, getEventInvites)
+	authenticated.POST("/events/:id/invites", writeEvents, createEventInvite)
+	authenticated.DELETE("/events/:id/invites/:inviteId", writeEvents, revokeEventInvite)
+	authenticated.POST("/events/:id/checkin", writeEvents, checkInAttendee)
+	authenticated.GET("/events/:id/stats", readEvents, getEventStats)
+	authenticated.POST("/events/:id/tickets", writeEvents, createTicketType)
+	authenticated.POST("/events/:id/tickets/:ticketId/orders", writeRegistrations, reserveTicket)
+	authenticated.GET("/orders/:id", readRegistrations, getOrder)
+	authenticated.POST("/orders/:id/pay", writeRegistrations, payOrder)
+	authenticated.GET("/me/registrations", readRegistrations, getMyRegistrations)
+	authenticated.GET("/me/registrations/:id/qr", readRegistrations, getRegistrationQR)
+	authenticated.POST("/orgs", writeOrgs, createOrganization)
+	authenticated.GET("/orgs", readOrgs, getMyOrganizations)
+	authenticated.GET("/orgs/:id", readOrgs, getOrganization)
+	authenticated.POST("/orgs/:id/invitations", writeOrgs, inviteToOrganization)
+	authenticated.PUT("/orgs/:id/members/:userId", writeOrgs, setOrganizationMemberRole)
+	authenticated.DELETE("/orgs/:id/members/:userId", writeOrgs, removeOrganizationMember)
+	authenticated.POST("/invitations/:token/accept", writeOrgs, acceptInvitation)
+
+	loginOnly := authenticated.Group("/")
+	loginOnly.Use(middlewares.RequireLogin)
+	loginOnly.GET("/me/tokens", getMyAPITokens)
+	loginOnly.POST("/me/tokens", createAPIToken)
+	loginOnly.DELETE("/me/tokens/:id", revokeAPIToken)
+	loginOnly.POST("/me/2fa/enroll", enrollTwoFactor)
+	loginOnly.GET("/me/2fa/qr", getTwoFactorQR)
+	loginOnly.POST("/me/2fa/verify", verifyTwoFactor)
+	loginOnly.DELETE("/me/2fa", disableTwoFactor)
+
+	admin := authenticated.Group("/")
+	admin.Use(middlewares.RequireAdmin, middlewares.RequireScope(models.ScopeTagsWrite))
+	admin.POST("/tags", createTag)
+	admin.PUT("/tags/:id", updateTag)
+	admin.DELETE("/tags/:id", deleteTag)
 
 	server.POST("/signup", signup)
 	server.POST("/login", login)
+	server.POST("/login/2fa", loginTwoFactor)
+	server.POST("/logout", logout)
+	server.GET("/auth/providers", getSSOProviders)
+	server.GET("/auth/:provider/login", startSSOLogin)
+	server.GET("/auth/:provider/callback", finishSSOLogin)
+
+	server.GET("/healthz", healthz)
+	server.GET("/readyz", readyz)
+	return nil
 }
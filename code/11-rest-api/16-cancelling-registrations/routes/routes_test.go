@@ -0,0 +1,220 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The api-test files depend on each other (login captures the token, creating
+// an event captures its id), so they run in this order against one server.
+var httpFiles = []string{
+	"create-user.http",
+	"login.http",
+	"create-event.http",
+	"get-events.http",
+	"get-single-event.http",
+	"update-event.http",
+	"register.http",
+	"cancel-registration.http",
+	"delete-event.http",
+}
+
+func TestHTTPFiles(t *testing.T) {
+	server := testutil.NewServer(t)
+	runner := testutil.NewHTTPFileRunner(server)
+
+	for _, name := range httpFiles {
+		t.Run(name, func(t *testing.T) {
+			runner.RunFile(t, filepath.Join("..", "api-test", name))
+		})
+	}
+}
+
+func TestOnlyOwnerCanChangeEvent(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+
+	event := gin.H{
+		"name":        "Meetup",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+	}
+
+	res := server.Do(http.MethodPost, "/events", event, owner)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPut, "/events/1", event, other)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("update by other user: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, other)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by other user: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodDelete, "/events/1", nil, owner)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("delete by owner: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestAuthenticatedRoutesRequireToken(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	res := server.Do(http.MethodPost, "/events", nil, "")
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no token: got status %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	res = server.Do(http.MethodPost, "/events", nil, "not-a-token")
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad token: got status %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestCancelRegistrationRejectsNonNumericEventId(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("user@example.com", "secret")
+
+	res := server.Do(http.MethodDelete, "/events/abc/register", nil, token)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestCancelRegistrationWithoutRegistration(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("user@example.com", "secret")
+	createEvent(t, server, token, "Meetup")
+
+	res := server.Do(http.MethodDelete, "/events/1/register", nil, token)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	res := server.Do(http.MethodGet, "/healthz", nil, "")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("healthz: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res = server.Do(http.MethodGet, "/readyz", nil, "")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("readyz: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	db.DB.Close()
+
+	res = server.Do(http.MethodGet, "/readyz", nil, "")
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readyz with closed database: got status %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	res = server.Do(http.MethodGet, "/healthz", nil, "")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("healthz with closed database: got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	server.Do(http.MethodGet, "/events", nil, "")
+	server.Do(http.MethodGet, "/events/abc", nil, "")
+	server.Do(http.MethodPost, "/login", gin.H{"email": "nobody@example.com", "password": "wrong"}, "")
+
+	// Registrations are counted whichever API made them.
+	token := server.SignupAndLogin("owner@example.com", "secret")
+	createEvent(t, server, token, "Meetup")
+	graphql(t, server, token, `mutation { registerForEvent(eventId: "1") }`, nil)
+
+	// Metrics are served on their own listener, not the public API.
+	if res := server.Do(http.MethodGet, "/metrics", nil, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("public metrics: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/events",status="200"}`,
+		`http_requests_total{method="GET",route="/events/:id",status="400"}`,
+		`http_request_duration_seconds_bucket{method="GET",route="/events",le="0.005"}`,
+		`auth_logins_total{result="failure"}`,
+		`go_sql_open_connections{db_name="api"}`,
+		`event_registrations_total{action="register"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
+
+func TestTracingCoversHandlersAndQueries(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	server := testutil.NewServer(t)
+	server.Do(http.MethodGet, "/events", nil, "")
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	handler, ok := byName["GET /events"]
+	if !ok {
+		t.Fatalf("no handler span in %d spans", len(spans))
+	}
+
+	query, ok := byName["models.FindEvents"]
+	if !ok {
+		t.Fatalf("no query span in %d spans", len(spans))
+	}
+
+	if query.Parent.SpanID() != handler.SpanContext.SpanID() {
+		t.Error("query span is not a child of the handler span")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	defaultTimeout := db.DefaultQueryTimeout
+	db.DefaultQueryTimeout = time.Nanosecond
+	t.Cleanup(func() {
+		db.DefaultQueryTimeout = defaultTimeout
+	})
+
+	res := server.Do(http.MethodGet, "/events/1", nil, "")
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+}
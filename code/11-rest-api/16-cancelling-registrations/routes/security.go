@@ -0,0 +1,24 @@
+package routes
+
+import "example.com/rest-api/middlewares"
+
+// maxBodyBytes bounds request bodies on routes without their own limit.
+const maxBodyBytes = 1 << 20
+
+// corsConfig says which browser apps on other origins may use the API.
+var corsConfig = middlewares.DefaultCORSConfig
+
+// securityHeaders are added to every response.
+var securityHeaders = middlewares.DefaultSecurityHeaders
+
+// SetCORS sets which origins may call the API from a browser. It must be
+// called before RegisterRoutes.
+func SetCORS(config middlewares.CORSConfig) {
+	corsConfig = config
+}
+
+// SetSecurityHeaders sets the security headers added to every response.
+// It must be called before RegisterRoutes.
+func SetSecurityHeaders(config middlewares.SecurityHeadersConfig) {
+	securityHeaders = config
+}
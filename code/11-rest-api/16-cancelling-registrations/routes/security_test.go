@@ -0,0 +1,24 @@
+package routes_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimitAndSecurityHeaders(t *testing.T) {
+	server := testutil.NewServer(t)
+
+	res := server.Do(http.MethodPost, "/signup", gin.H{"email": strings.Repeat("x", 2<<20), "password": "secret"}, "")
+
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("2 MiB signup: got status %d, want 413", res.StatusCode)
+	}
+
+	if got := res.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("got X-Content-Type-Options %q, want nosniff", got)
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/middlewares"
+	"example.com/rest-api/models"
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithFirstFactor answers a correct password or identity provider
+// sign-in. With 2FA it only earns a challenge, which /login/2fa exchanges
+// for the token along with a code.
+func respondWithFirstFactor(context *gin.Context, email string, userId int64) {
+	twoFactor, err := models.TwoFactorEnabled(context.Request.Context(), userId)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	if !twoFactor {
+		respondWithLogin(context, email, userId)
+		return
+	}
+
+	challenge, err := models.CreateLoginChallenge(context.Request.Context(), userId)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Two-factor code required.", "challenge": challenge})
+}
+
+// respondWithLogin finishes a successful login. The token is returned in
+// the body, unless ?session=cookie asks for a cookie session, which keeps
+// it out of reach of the page's scripts and returns the CSRF token instead.
+func respondWithLogin(context *gin.Context, email string, userId int64) {
+	token, err := utils.GenerateToken(email, userId)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	if context.Query("session") != "cookie" {
+		metrics.LoginSucceeded()
+		context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "token": token})
+		return
+	}
+
+	csrfToken, err := middlewares.StartSession(context, token)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	metrics.LoginSucceeded()
+	context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "csrfToken": csrfToken})
+}
+
+// logout ends a cookie session. Header tokens just expire.
+func logout(context *gin.Context) {
+	middlewares.EndSession(context)
+	context.JSON(http.StatusOK, gin.H{"message": "Logged out."})
+}
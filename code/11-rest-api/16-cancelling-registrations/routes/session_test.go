@@ -0,0 +1,135 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	"example.com/rest-api/middlewares"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// browser sends requests with a cookie jar, like a browser would.
+type browser struct {
+	t      *testing.T
+	server *testutil.Server
+	client *http.Client
+}
+
+func newBrowser(t *testing.T, server *testutil.Server) *browser {
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &browser{t: t, server: server, client: &http.Client{Jar: jar}}
+}
+
+func (b *browser) do(method, path string, body any, csrfToken string) *http.Response {
+	b.t.Helper()
+
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, b.server.URL+path, bytes.NewReader(data))
+
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+
+	res, err := b.client.Do(req)
+
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	b.t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func TestCookieSession(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Signup("user@example.com", "secret")
+	b := newBrowser(t, server)
+
+	res := b.do(http.MethodPost, "/login?session=cookie", gin.H{"email": "user@example.com", "password": "secret"}, "")
+
+	var login struct{ Token, CSRFToken string }
+	json.NewDecoder(res.Body).Decode(&login)
+
+	if res.StatusCode != http.StatusOK || login.Token != "" || login.CSRFToken == "" {
+		t.Fatalf("cookie login: got status %d and %+v, want only a CSRF token", res.StatusCode, login)
+	}
+
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "session" && !cookie.HttpOnly {
+			t.Error("the session cookie is readable by scripts")
+		}
+	}
+
+	if res := b.do(http.MethodGet, "/me/registrations", nil, ""); res.StatusCode != http.StatusOK {
+		t.Errorf("reading with the cookie: got status %d, want 200", res.StatusCode)
+	}
+
+	if res := b.do(http.MethodPost, "/orgs", gin.H{"name": "Acme"}, ""); res.StatusCode != http.StatusForbidden {
+		t.Errorf("writing without the CSRF token: got status %d, want 403", res.StatusCode)
+	}
+
+	if res := b.do(http.MethodPost, "/orgs", gin.H{"name": "Acme"}, "forged"); res.StatusCode != http.StatusForbidden {
+		t.Errorf("writing with a wrong CSRF token: got status %d, want 403", res.StatusCode)
+	}
+
+	if res := b.do(http.MethodPost, "/orgs", gin.H{"name": "Acme"}, login.CSRFToken); res.StatusCode != http.StatusCreated {
+		t.Errorf("writing with the CSRF token: got status %d, want 201", res.StatusCode)
+	}
+
+	b.do(http.MethodPost, "/logout", nil, "")
+
+	if res := b.do(http.MethodGet, "/me/registrations", nil, ""); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("after logout: got status %d, want 401", res.StatusCode)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("user@example.com", "secret")
+
+	for _, header := range []string{token, "Bearer " + token, "bearer " + token} {
+		if res := server.Do(http.MethodPost, "/orgs", gin.H{"name": "Acme"}, header); res.StatusCode != http.StatusCreated {
+			t.Errorf("Authorization %.10q...: got status %d, want 201", header, res.StatusCode)
+		}
+	}
+}
+
+func TestSessionCookiesAreSecure(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Signup("user@example.com", "secret")
+
+	// Behind a proxy terminating TLS the request is plain HTTP, and the
+	// cookies must still be Secure.
+	middlewares.SetSecureCookies(true)
+	res := newBrowser(t, server).do(http.MethodPost, "/login?session=cookie", gin.H{"email": "user@example.com", "password": "secret"}, "")
+
+	if len(res.Cookies()) != 2 {
+		t.Fatalf("got cookies %v, want the session and CSRF cookies", res.Cookies())
+	}
+
+	for _, cookie := range res.Cookies() {
+		if !cookie.Secure {
+			t.Errorf("cookie %s is not Secure", cookie.Name)
+		}
+	}
+}
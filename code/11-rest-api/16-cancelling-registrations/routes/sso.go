@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/middlewares"
+	"example.com/rest-api/models"
+	"example.com/rest-api/sso"
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// ssoStateCookie ties a sign-in to the browser that started it, so nobody
+// can get a victim signed in to the attacker's account by sending them a
+// callback link.
+const ssoStateCookie = "sso_state"
+
+// ssoProviders are the identity providers users can sign in with, by name.
+var ssoProviders = map[string]*sso.Provider{}
+
+// SetSSOProviders replaces the identity providers users can sign in with.
+func SetSSOProviders(providers []*sso.Provider) {
+	byName := make(map[string]*sso.Provider, len(providers))
+
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	ssoProviders = byName
+}
+
+func getSSOProviders(context *gin.Context) {
+	names := make([]string, 0, len(ssoProviders))
+
+	for name := range ssoProviders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	context.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+func loadSSOProvider(context *gin.Context) (*sso.Provider, bool) {
+	provider, ok := ssoProviders[context.Param("provider")]
+
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Unknown identity provider."})
+		return nil, false
+	}
+
+	return provider, true
+}
+
+// startSSOLogin sends the user to the provider's sign-in page.
+func startSSOLogin(context *gin.Context) {
+	provider, ok := loadSSOProvider(context)
+
+	if !ok {
+		return
+	}
+
+	state, _, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not start sign-in.", err)
+		return
+	}
+
+	nonce, _, err := utils.GenerateRandomToken()
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not start sign-in.", err)
+		return
+	}
+
+	login := models.SSOLogin{Provider: provider.Name, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}
+	err = models.SaveSSOLogin(context.Request.Context(), state, login)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not start sign-in.", err)
+		return
+	}
+
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(ssoStateCookie, state, int(models.SSOLoginTTL.Seconds()), "/auth/", "", middlewares.SecureCookies(), true)
+	context.Redirect(http.StatusFound, provider.AuthCodeURL(state, login.Nonce, login.Verifier))
+}
+
+// finishSSOLogin handles the provider's redirect back and responds like
+// /login.
+func finishSSOLogin(context *gin.Context) {
+	provider, ok := loadSSOProvider(context)
+
+	if !ok {
+		return
+	}
+
+	if reason := context.Query("error"); reason != "" {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Sign-in was cancelled or refused.", "error": reason})
+		return
+	}
+
+	state := context.Query("state")
+	cookie, err := context.Cookie(ssoStateCookie)
+
+	if err != nil || state == "" || cookie != state {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Sign-in expired or was started elsewhere. Start again."})
+		return
+	}
+
+	context.SetCookie(ssoStateCookie, "", -1, "/auth/", "", middlewares.SecureCookies(), true)
+
+	login, err := models.TakeSSOLogin(context.Request.Context(), provider.Name, state)
+
+	if errors.Is(err, models.ErrSSOLoginNotFound) {
+		respondWithError(context, http.StatusBadRequest, "Sign-in expired or was started elsewhere. Start again.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	identity, err := provider.Exchange(context.Request.Context(), context.Query("code"), login.Nonce, login.Verifier)
+
+	if err != nil {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusUnauthorized, "Could not authenticate user.", err)
+		return
+	}
+
+	user, err := models.SignInWithIdentity(context.Request.Context(), provider.Name, identity.Subject, identity.Email, identity.EmailVerified)
+
+	if errors.Is(err, models.ErrEmailNotVerified) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusForbidden, "The identity provider hasn't verified your email address.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrUserDisabled) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusForbidden, "Account disabled.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	respondWithFirstFactor(context, user.Email, user.ID)
+}
@@ -0,0 +1,234 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/routes"
+	"example.com/rest-api/sso"
+	"example.com/rest-api/sso/ssotest"
+	"example.com/rest-api/testutil"
+)
+
+// setupSSO configures the mock provider as "corp".
+func setupSSO(t *testing.T, server *testutil.Server) *ssotest.Server {
+	t.Helper()
+
+	idp := ssotest.NewServer(t)
+	provider, err := sso.NewProvider(context.Background(), idp.Config("corp", server.URL+"/auth/corp/callback"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes.SetSSOProviders([]*sso.Provider{provider})
+	t.Cleanup(func() { routes.SetSSOProviders(nil) })
+
+	return idp
+}
+
+type ssoResult struct {
+	status  int
+	message string
+	token   string
+}
+
+// signInWithSSO walks through the whole flow like a browser: start, the
+// provider's page and the callback.
+func signInWithSSO(t *testing.T, server *testutil.Server) ssoResult {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Jar: jar}
+	res, err := client.Get(server.URL + "/auth/corp/login")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Message string
+		Token   string
+	}
+	err = json.Unmarshal(data, &body)
+
+	if err != nil {
+		t.Fatalf("could not decode %q: %v", data, err)
+	}
+
+	return ssoResult{status: res.StatusCode, message: body.Message, token: body.Token}
+}
+
+func countUsers(t *testing.T) int {
+	t.Helper()
+
+	var n int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestSSOCreatesAndLinksUsers(t *testing.T) {
+	server := testutil.NewServer(t)
+	idp := setupSSO(t, server)
+
+	var providers struct{ Providers []string }
+	server.Do(http.MethodGet, "/auth/providers", nil, "").JSON(t, &providers)
+
+	if len(providers.Providers) != 1 || providers.Providers[0] != "corp" {
+		t.Errorf("got providers %v, want corp", providers.Providers)
+	}
+
+	// A new user is created on the first sign-in.
+	res := signInWithSSO(t, server)
+
+	if res.status != http.StatusOK || res.token == "" {
+		t.Fatalf("first sign-in: got %+v", res)
+	}
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, res.token); res.StatusCode != http.StatusOK {
+		t.Errorf("using the token: got status %d, want 200", res.StatusCode)
+	}
+
+	// The identity is linked to the existing account with the same email
+	// address, and stays linked when the email changes at the provider.
+	server.Signup("bob@example.com", "secret")
+	idp.SetUser(ssotest.User{Subject: "bob", Email: "Bob@example.com", EmailVerified: true})
+
+	if res := signInWithSSO(t, server); res.status != http.StatusOK {
+		t.Fatalf("linking sign-in: got %+v", res)
+	}
+
+	idp.SetUser(ssotest.User{Subject: "bob", Email: "robert@example.com", EmailVerified: false})
+
+	if res := signInWithSSO(t, server); res.status != http.StatusOK {
+		t.Fatalf("sign-in after an email change: got %+v", res)
+	}
+
+	if n := countUsers(t); n != 2 {
+		t.Errorf("got %d users, want alice and bob", n)
+	}
+}
+
+func TestSSOAsksForTheSecondFactor(t *testing.T) {
+	server := testutil.NewServer(t)
+	idp := setupSSO(t, server)
+
+	enableTwoFactor(t, server, server.SignupAndLogin("bob@example.com", "secret"))
+	idp.SetUser(ssotest.User{Subject: "bob", Email: "bob@example.com", EmailVerified: true})
+
+	if res := signInWithSSO(t, server); res.status != http.StatusOK || res.token != "" || res.message != "Two-factor code required." {
+		t.Errorf("sign-in with 2FA enabled: got %+v, want a challenge", res)
+	}
+}
+
+func TestSSOResetsAccountsCreatedBeforeLinking(t *testing.T) {
+	server := testutil.NewServer(t)
+	idp := setupSSO(t, server)
+
+	// Sign up doesn't verify the address, so anyone could have created this
+	// account ahead of its owner.
+	login := server.SignupAndLogin("victim@example.com", "secret")
+	apiToken := createAPIToken(t, server, login, "events:read")
+
+	idp.SetUser(ssotest.User{Subject: "victim", Email: "victim@example.com", EmailVerified: true})
+	res := signInWithSSO(t, server)
+
+	if res.status != http.StatusOK || res.token == "" {
+		t.Fatalf("linking sign-in: got %+v", res)
+	}
+
+	for name, token := range map[string]string{"login token": login, "API token": apiToken} {
+		if res := server.Do(http.MethodGet, "/me/registrations", nil, token); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s from before linking: got status %d, want 401", name, res.StatusCode)
+		}
+	}
+
+	if res := server.Do(http.MethodPost, "/login", map[string]string{"email": "victim@example.com", "password": "secret"}, ""); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("password login after linking: got status %d, want 401", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, res.token); res.StatusCode != http.StatusOK {
+		t.Errorf("token from the linking sign-in: got status %d, want 200", res.StatusCode)
+	}
+}
+
+func TestSSORejectsUnverifiedEmails(t *testing.T) {
+	server := testutil.NewServer(t)
+	idp := setupSSO(t, server)
+	server.Signup("victim@example.com", "secret")
+
+	idp.SetUser(ssotest.User{Subject: "mallory", Email: "victim@example.com", EmailVerified: false})
+
+	if res := signInWithSSO(t, server); res.status != http.StatusForbidden {
+		t.Errorf("unverified email: got %+v, want 403", res)
+	}
+
+	if n := countUsers(t); n != 1 {
+		t.Errorf("got %d users, want only the victim", n)
+	}
+}
+
+func TestSSOCallbackNeedsTheStartingBrowser(t *testing.T) {
+	server := testutil.NewServer(t)
+	setupSSO(t, server)
+
+	// Follow the redirects by hand without keeping the state cookie, as if
+	// the callback link was sent to someone else.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	location := server.URL + "/auth/corp/login"
+
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusFound {
+			t.Fatalf("step %d: got status %d, want a redirect", i, res.StatusCode)
+		}
+
+		location = res.Header.Get("Location")
+	}
+
+	res, err := client.Get(location)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("callback without the state cookie: got status %d, want 400", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodGet, "/auth/other/login", nil, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown provider: got status %d, want 404", res.StatusCode)
+	}
+}
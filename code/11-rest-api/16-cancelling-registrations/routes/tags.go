@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getTags(context *gin.Context) {
+	tags, err := models.GetAllTags(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch tags. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, tags)
+}
+
+func createTag(context *gin.Context) {
+	var tag models.Tag
+	err := context.ShouldBindJSON(&tag)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	err = tag.Save(context.Request.Context())
+
+	if errors.Is(err, models.ErrInvalidTag) {
+		respondWithError(context, http.StatusBadRequest, "Tag name is required.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrTagExists) {
+		respondWithError(context, http.StatusConflict, "Tag already exists.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create tag. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Tag created!", "tag": tag})
+}
+
+func updateTag(context *gin.Context) {
+	tagId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse tag id.", err)
+		return
+	}
+
+	var tag models.Tag
+	err = context.ShouldBindJSON(&tag)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	tag.ID = tagId
+	err = tag.Update(context.Request.Context())
+
+	if errors.Is(err, models.ErrTagNotFound) {
+		respondWithError(context, http.StatusNotFound, "Tag not found.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidTag) {
+		respondWithError(context, http.StatusBadRequest, "Tag name is required.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrTagExists) {
+		respondWithError(context, http.StatusConflict, "Tag already exists.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not update tag.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Tag updated successfully!"})
+}
+
+func deleteTag(context *gin.Context) {
+	tagId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse tag id.", err)
+		return
+	}
+
+	err = models.Tag{ID: tagId}.Delete(context.Request.Context())
+
+	if errors.Is(err, models.ErrTagNotFound) {
+		respondWithError(context, http.StatusNotFound, "Tag not found.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not delete the tag.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully!"})
+}
+
+// getEventFacets counts how many events carry each tag. It takes the same
+// ?tag= filter as GET /events, so the counts describe what narrowing the
+// current results by one more tag would leave.
+func getEventFacets(context *gin.Context) {
+	facets, err := models.TagFacets(context.Request.Context(), eventFilter(context))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch facets. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"tags": facets})
+}
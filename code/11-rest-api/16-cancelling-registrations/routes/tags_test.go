@@ -0,0 +1,111 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOnlyAdminsManageTags(t *testing.T) {
+	server := testutil.NewServer(t)
+	user := server.SignupAndLogin("user@example.com", "secret")
+	server.Signup("admin@example.com", "secret")
+	server.SetRole("admin@example.com", "admin")
+	admin := server.Login("admin@example.com", "secret")
+
+	res := server.Do(http.MethodPost, "/tags", gin.H{"name": "go"}, user)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("create by user: got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+
+	res = server.Do(http.MethodPost, "/tags", gin.H{"name": "Go", "category": "topic"}, admin)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create by admin: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res = server.Do(http.MethodPost, "/tags", gin.H{"name": "go"}, admin)
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate: got status %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+
+	res = server.Do(http.MethodPut, "/tags/1", gin.H{"name": "golang", "category": "topic"}, admin)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("update: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var tags []struct{ Name, Category string }
+	server.Do(http.MethodGet, "/tags", nil, "").JSON(t, &tags)
+
+	if len(tags) != 1 || tags[0].Name != "golang" || tags[0].Category != "topic" {
+		t.Errorf("got tags %+v", tags)
+	}
+
+	res = server.Do(http.MethodDelete, "/tags/2", nil, admin)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("delete missing tag: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestFilterEventsByTag(t *testing.T) {
+	server := testutil.NewServer(t)
+	server.Signup("admin@example.com", "secret")
+	server.SetRole("admin@example.com", "admin")
+	token := server.Login("admin@example.com", "secret")
+
+	for _, name := range []string{"go", "online"} {
+		server.Do(http.MethodPost, "/tags", gin.H{"name": name}, token)
+	}
+
+	for name, tags := range map[string][]string{
+		"Go Meetup": {"go"},
+		"Go Online": {"go", "online"},
+	} {
+		res := server.Do(http.MethodPost, "/events", gin.H{
+			"name":        name,
+			"description": "A meetup",
+			"location":    "Berlin",
+			"dateTime":    "2025-01-01T15:30:00Z",
+			"tags":        tags,
+		}, token)
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create event: got status %d, body %s", res.StatusCode, res.Body)
+		}
+	}
+
+	res := server.Do(http.MethodPost, "/events", gin.H{
+		"name":        "Rust Meetup",
+		"description": "A meetup",
+		"location":    "Berlin",
+		"dateTime":    "2025-01-01T15:30:00Z",
+		"tags":        []string{"rust"},
+	}, token)
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown tag: got status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	var events []struct {
+		Name string
+		Tags []string
+	}
+	server.Do(http.MethodGet, "/events?tag=go&tag=online", nil, "").JSON(t, &events)
+
+	if len(events) != 1 || events[0].Name != "Go Online" || len(events[0].Tags) != 2 {
+		t.Errorf("got events %+v", events)
+	}
+
+	var facets struct {
+		Tags []struct {
+			Name  string
+			Count int
+		}
+	}
+	server.Do(http.MethodGet, "/events/facets?tag=go", nil, "").JSON(t, &facets)
+
+	if len(facets.Tags) != 2 || facets.Tags[0].Name != "go" || facets.Tags[0].Count != 2 || facets.Tags[1].Count != 1 {
+		t.Errorf("got facets %+v", facets.Tags)
+	}
+}
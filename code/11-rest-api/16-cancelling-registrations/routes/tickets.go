@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/models"
+	"example.com/rest-api/payments"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBytes bounds the webhook bodies read into memory.
+const maxWebhookBytes = 64 << 10
+
+func getTicketTypes(context *gin.Context) {
+	event, ok := loadVisibleEvent(context)
+
+	if !ok {
+		return
+	}
+
+	ticketTypes, err := models.GetTicketTypes(context.Request.Context(), event.ID)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch tickets.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, ticketTypes)
+}
+
+func createTicketType(context *gin.Context) {
+	event, ok := loadEventWith(context, models.PermEditEvent)
+
+	if !ok {
+		return
+	}
+
+	var ticketType models.TicketType
+	err := context.ShouldBindJSON(&ticketType)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	ticketType.EventID = event.ID
+	err = ticketType.Save(context.Request.Context())
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create ticket.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Ticket created!", "ticket": ticketType})
+}
+
+// reserveTicket puts a ticket on hold for the current user. Paid tickets
+// must then be paid through POST /orders/:id/pay before the hold expires.
+// Private events take the same ?invite= token as registering does.
+func reserveTicket(context *gin.Context) {
+	event, ok := loadVisibleEvent(context)
+
+	if !ok {
+		return
+	}
+
+	ticketTypeId, err := strconv.ParseInt(context.Param("ticketId"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse ticket id.", err)
+		return
+	}
+
+	ticketTypes, err := models.GetTicketTypes(context.Request.Context(), event.ID)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch tickets.", err)
+		return
+	}
+
+	if !hasTicketType(ticketTypes, ticketTypeId) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not find ticket."})
+		return
+	}
+
+	order, err := models.Reserve(context.Request.Context(), ticketTypeId, context.GetInt64("userId"), context.Query("invite"))
+
+	if errors.Is(err, models.ErrTicketTypeNotFound) {
+		respondWithError(context, http.StatusNotFound, "Could not find ticket.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		respondWithError(context, http.StatusConflict, "Already registered or holding a ticket.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrSoldOut) {
+		respondWithError(context, http.StatusConflict, "Sold out.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInviteRequired) {
+		respondWithError(context, http.StatusForbidden, "This event is invite-only.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidInvite) {
+		respondWithError(context, http.StatusForbidden, "The invite is not valid.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not reserve ticket.", err)
+		return
+	}
+
+	if order.Status == models.OrderPaid {
+		context.JSON(http.StatusCreated, gin.H{"message": "Registered!", "order": order})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Ticket reserved!", "order": order})
+}
+
+func hasTicketType(ticketTypes []models.TicketType, id int64) bool {
+	for _, ticketType := range ticketTypes {
+		if ticketType.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getOrder(context *gin.Context) {
+	order, ok := loadOwnOrder(context)
+
+	if !ok {
+		return
+	}
+
+	context.JSON(http.StatusOK, order)
+}
+
+// payOrder starts the checkout for a reserved order and returns the URL
+// where the user pays. The order is confirmed by the provider's webhook.
+func payOrder(context *gin.Context) {
+	order, ok := loadOwnOrder(context)
+
+	if !ok {
+		return
+	}
+
+	event, err := models.GetEventByID(context.Request.Context(), order.EventID)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch event.", err)
+		return
+	}
+
+	err = order.StartCheckout(context.Request.Context(), event.Name)
+
+	if errors.Is(err, models.ErrOrderNotPayable) {
+		respondWithError(context, http.StatusConflict, "The order can't be paid anymore.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrPaymentsDisabled) {
+		respondWithError(context, http.StatusServiceUnavailable, "Payments are not available.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusBadGateway, "Could not start the payment.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Checkout started!", "order": order})
+}
+
+// paymentWebhook receives payment results from the provider. It is not
+// authenticated as a user; the provider's signature is checked instead.
+func paymentWebhook(context *gin.Context) {
+	body, err := io.ReadAll(context.Request.Body)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not read request body.", err)
+		return
+	}
+
+	err = models.HandlePaymentWebhook(context.Request.Context(), context.Request.Header, body)
+
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		respondWithError(context, http.StatusUnauthorized, "Invalid signature.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrOrderNotFound) {
+		respondWithError(context, http.StatusNotFound, "Could not find order.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrPaymentsDisabled) {
+		respondWithError(context, http.StatusServiceUnavailable, "Payments are not available.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not process the payment.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Received."})
+}
+
+// loadOwnOrder fetches the order in the :id parameter. Orders of other
+// users are reported as missing.
+func loadOwnOrder(context *gin.Context) (*models.Order, bool) {
+	orderId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse order id.", err)
+		return nil, false
+	}
+
+	order, err := models.GetOrderByID(context.Request.Context(), orderId)
+
+	if errors.Is(err, models.ErrOrderNotFound) || (err == nil && order.UserID != context.GetInt64("userId")) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Could not find order."})
+		return nil, false
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch order.", err)
+		return nil, false
+	}
+
+	return order, true
+}
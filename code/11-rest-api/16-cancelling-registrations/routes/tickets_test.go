@@ -0,0 +1,458 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/models"
+	"example.com/rest-api/payments"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+type order struct {
+	ID          int64
+	Status      string
+	Amount      int64
+	Currency    string
+	CheckoutURL string
+}
+
+func setupPayments(t *testing.T, server *testutil.Server) *payments.FakeProvider {
+	t.Helper()
+
+	provider := &payments.FakeProvider{Secret: "whsec_test", WebhookURL: server.URL + "/payments/webhook"}
+	models.SetPaymentProvider(provider)
+	t.Cleanup(func() { models.SetPaymentProvider(nil) })
+
+	return provider
+}
+
+func createTicketType(t *testing.T, server *testutil.Server, token string, price int64, quantity int) {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/events/1/tickets", gin.H{"name": "General", "price": price, "currency": "eur", "quantity": quantity}, token)
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create ticket: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
+
+func reserveTicket(t *testing.T, server *testutil.Server, token string) order {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/events/1/tickets/1/orders", nil, token)
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("reserve: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var body struct{ Order order }
+	res.JSON(t, &body)
+	return body.Order
+}
+
+func payOrder(t *testing.T, server *testutil.Server, token string, id int64) order {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/orders/"+strconv.FormatInt(id, 10)+"/pay", nil, token)
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("pay: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var body struct{ Order order }
+	res.JSON(t, &body)
+	return body.Order
+}
+
+func getOrderStatus(t *testing.T, server *testutil.Server, token string, id int64) string {
+	t.Helper()
+
+	var o order
+	server.Do(http.MethodGet, "/orders/"+strconv.FormatInt(id, 10), nil, token).JSON(t, &o)
+	return o.Status
+}
+
+func TestPaidTicketFlow(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 1)
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets", gin.H{"name": "VIP", "price": 100, "currency": "eur", "quantity": 1}, guest); res.StatusCode != http.StatusForbidden {
+		t.Errorf("guest creating tickets: got status %d, want 403", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/register", nil, guest); res.StatusCode != http.StatusConflict {
+		t.Errorf("register without ticket: got status %d, want 409", res.StatusCode)
+	}
+
+	reserved := reserveTicket(t, server, guest)
+
+	if reserved.Status != models.OrderReserved || reserved.Amount != 1500 || reserved.Currency != "EUR" {
+		t.Errorf("got order %+v, want reserved for 1500 EUR", reserved)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders", nil, other); res.StatusCode != http.StatusConflict {
+		t.Errorf("reserving a held ticket: got status %d, want 409", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders", nil, guest); res.StatusCode != http.StatusConflict {
+		t.Errorf("reserving twice: got status %d, want 409", res.StatusCode)
+	}
+
+	var ticketTypes []struct{ Available int }
+	server.Do(http.MethodGet, "/events/1/tickets", nil, "").JSON(t, &ticketTypes)
+
+	if len(ticketTypes) != 1 || ticketTypes[0].Available != 0 {
+		t.Errorf("got ticket types %+v, want none available", ticketTypes)
+	}
+
+	if res := server.Do(http.MethodGet, "/orders/1", nil, other); res.StatusCode != http.StatusNotFound {
+		t.Errorf("someone else's order: got status %d, want 404", res.StatusCode)
+	}
+
+	pending := payOrder(t, server, guest, reserved.ID)
+
+	if pending.Status != models.OrderPending || pending.CheckoutURL == "" {
+		t.Errorf("got order %+v, want pending with a checkout URL", pending)
+	}
+
+	forged := http.Header{payments.SignatureHeader: {payments.SignWebhook("wrong", time.Now(), []byte(`{}`))}}
+
+	if res := server.DoWithHeader(http.MethodPost, "/payments/webhook", []byte(`{"type":"payment.succeeded","sessionId":"cs_fake_1"}`), forged); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("forged webhook: got status %d, want 401", res.StatusCode)
+	}
+
+	if status := getOrderStatus(t, server, guest, reserved.ID); status != models.OrderPending {
+		t.Errorf("after forged webhook: got status %q, want pending", status)
+	}
+
+	// The second call checks that repeated webhooks are harmless.
+	for i := 0; i < 2; i++ {
+		err := provider.Complete(context.Background(), "cs_fake_1")
+
+		if err != nil {
+			t.Fatalf("complete payment: %v", err)
+		}
+	}
+
+	if status := getOrderStatus(t, server, guest, reserved.ID); status != models.OrderPaid {
+		t.Errorf("after payment: got status %q, want paid", status)
+	}
+
+	var attendees []models.Attendee
+	server.Do(http.MethodGet, "/events/1/attendees", nil, owner).JSON(t, &attendees)
+
+	if len(attendees) != 1 || attendees[0].Email != "guest@example.com" {
+		t.Errorf("got attendees %+v, want the guest once", attendees)
+	}
+
+	if res := server.Do(http.MethodDelete, "/events/1", nil, owner); res.StatusCode != http.StatusConflict {
+		t.Errorf("deleting an event with paid tickets: got status %d, want 409", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodDelete, "/events/1/register", nil, guest); res.StatusCode != http.StatusOK {
+		t.Fatalf("cancel: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	refunds := provider.Refunds()
+
+	if len(refunds) != 1 || refunds[0].Amount != 1500 || refunds[0].PaymentID != "pay_cs_fake_1" {
+		t.Errorf("got refunds %+v, want 1500 for pay_cs_fake_1", refunds)
+	}
+
+	if status := getOrderStatus(t, server, guest, reserved.ID); status != models.OrderRefunded {
+		t.Errorf("after cancelling: got status %q, want refunded", status)
+	}
+
+	if res := server.Do(http.MethodDelete, "/events/1", nil, owner); res.StatusCode != http.StatusOK {
+		t.Errorf("deleting after refund: got status %d, want 200", res.StatusCode)
+	}
+}
+
+func TestLatePaymentIsRefundedWhenSoldOut(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 1)
+
+	late := reserveTicket(t, server, guest)
+	payOrder(t, server, guest, late.ID)
+
+	_, err := db.DB.Exec("UPDATE orders SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), late.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := models.ExpireHolds(context.Background())
+
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireHolds: got %d, %v, want 1 expired", expired, err)
+	}
+
+	onTime := reserveTicket(t, server, other)
+	payOrder(t, server, other, onTime.ID)
+
+	if err := provider.Complete(context.Background(), "cs_fake_2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Complete(context.Background(), "cs_fake_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := getOrderStatus(t, server, other, onTime.ID); status != models.OrderPaid {
+		t.Errorf("on time order: got status %q, want paid", status)
+	}
+
+	if status := getOrderStatus(t, server, guest, late.ID); status != models.OrderRefunded {
+		t.Errorf("late order: got status %q, want refunded", status)
+	}
+
+	if refunds := provider.Refunds(); len(refunds) != 1 || refunds[0].PaymentID != "pay_cs_fake_1" {
+		t.Errorf("got refunds %+v, want the late payment refunded", refunds)
+	}
+
+	if res := server.Do(http.MethodPost, "/orders/1/pay", nil, guest); res.StatusCode != http.StatusConflict {
+		t.Errorf("paying a refunded order: got status %d, want 409", res.StatusCode)
+	}
+}
+
+func TestLatePaymentIsRefundedWhenAlreadyRegistered(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 10)
+
+	late := reserveTicket(t, server, guest)
+	payOrder(t, server, guest, late.ID)
+
+	_, err := db.DB.Exec("UPDATE orders SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), late.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the hold is gone the guest can buy another ticket.
+	again := reserveTicket(t, server, guest)
+	payOrder(t, server, guest, again.ID)
+
+	if err := provider.Complete(context.Background(), "cs_fake_2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Complete(context.Background(), "cs_fake_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := getOrderStatus(t, server, guest, late.ID); status != models.OrderRefunded {
+		t.Errorf("late order: got status %q, want refunded", status)
+	}
+
+	if refunds := provider.Refunds(); len(refunds) != 1 || refunds[0].PaymentID != "pay_cs_fake_1" {
+		t.Errorf("got refunds %+v, want the late payment refunded", refunds)
+	}
+
+	var registrations int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM registrations").Scan(&registrations); err != nil || registrations != 1 {
+		t.Errorf("got %d registrations, %v, want 1", registrations, err)
+	}
+}
+
+func TestPaymentOfAnotherAmountIsRefunded(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 10)
+
+	o := reserveTicket(t, server, guest)
+	payOrder(t, server, guest, o.ID)
+
+	body := []byte(`{"type":"` + payments.PaymentSucceeded + `","sessionId":"cs_fake_1","paymentId":"pay_cheap","amount":1,"currency":"eur"}`)
+	header := http.Header{payments.SignatureHeader: {payments.SignWebhook("whsec_test", time.Now(), body)}}
+
+	if res := server.DoWithHeader(http.MethodPost, "/payments/webhook", body, header); res.StatusCode != http.StatusOK {
+		t.Fatalf("webhook: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if status := getOrderStatus(t, server, guest, o.ID); status != models.OrderPending {
+		t.Errorf("order: got status %q, want pending", status)
+	}
+
+	if refunds := provider.Refunds(); len(refunds) != 1 || refunds[0].PaymentID != "pay_cheap" || refunds[0].Amount != 1 {
+		t.Errorf("got refunds %+v, want the payment refunded", refunds)
+	}
+}
+
+func TestFailedRefundIsRetried(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 1)
+
+	late := reserveTicket(t, server, guest)
+	payOrder(t, server, guest, late.ID)
+
+	_, err := db.DB.Exec("UPDATE orders SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), late.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onTime := reserveTicket(t, server, other)
+	payOrder(t, server, other, onTime.ID)
+
+	if err := provider.Complete(context.Background(), "cs_fake_2"); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.FailRefunds(errors.New("provider unavailable"))
+
+	if err := provider.Complete(context.Background(), "cs_fake_1"); err == nil {
+		t.Fatal("webhook succeeded although the refund failed")
+	}
+
+	if status := getOrderStatus(t, server, guest, late.ID); status != models.OrderRefundPending {
+		t.Errorf("after failed refund: got status %q, want refund_pending", status)
+	}
+
+	provider.FailRefunds(nil)
+
+	if err := provider.Complete(context.Background(), "cs_fake_1"); err != nil {
+		t.Fatalf("retried webhook: %v", err)
+	}
+
+	if status := getOrderStatus(t, server, guest, late.ID); status != models.OrderRefunded {
+		t.Errorf("after retry: got status %q, want refunded", status)
+	}
+
+	if refunds := provider.Refunds(); len(refunds) != 1 || refunds[0].PaymentID != "pay_cs_fake_1" {
+		t.Errorf("got refunds %+v, want the late payment refunded", refunds)
+	}
+}
+
+func TestPaymentForDeletedEventIsRefunded(t *testing.T) {
+	server := testutil.NewServer(t)
+	provider := setupPayments(t, server)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEvent(t, server, owner, "Conference")
+	createTicketType(t, server, owner, 1500, 1)
+	payOrder(t, server, guest, reserveTicket(t, server, guest).ID)
+
+	if res := server.Do(http.MethodDelete, "/events/1", nil, owner); res.StatusCode != http.StatusOK {
+		t.Fatalf("delete event: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if err := provider.Complete(context.Background(), "cs_fake_1"); err != nil {
+		t.Fatalf("webhook for deleted order: %v", err)
+	}
+
+	refunds := provider.Refunds()
+
+	if len(refunds) != 1 || refunds[0].PaymentID != "pay_cs_fake_1" || refunds[0].Amount != 1500 || refunds[0].Currency != "EUR" {
+		t.Errorf("got refunds %+v, want 1500 EUR for pay_cs_fake_1", refunds)
+	}
+}
+
+func TestFreeTicketRegistersImmediately(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEvent(t, server, owner, "Meetup")
+	createTicketType(t, server, owner, 0, 10)
+
+	if o := reserveTicket(t, server, guest); o.Status != models.OrderPaid {
+		t.Errorf("got status %q, want paid", o.Status)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders", nil, guest); res.StatusCode != http.StatusConflict {
+		t.Errorf("reserving while registered: got status %d, want 409", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodDelete, "/events/1/register", nil, guest); res.StatusCode != http.StatusOK {
+		t.Errorf("cancel without payment provider: got status %d, want 200", res.StatusCode)
+	}
+
+	if status := getOrderStatus(t, server, guest, 1); status != models.OrderCancelled {
+		t.Errorf("after cancelling: got status %q, want cancelled", status)
+	}
+}
+
+func TestDeleteEventWithFreeTickets(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	guest := server.SignupAndLogin("guest@example.com", "secret")
+
+	createEvent(t, server, owner, "Meetup")
+	createTicketType(t, server, owner, 0, 10)
+	reserveTicket(t, server, guest)
+
+	if res := server.Do(http.MethodDelete, "/events/1", nil, owner); res.StatusCode != http.StatusOK {
+		t.Fatalf("delete: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if res := server.Do(http.MethodGet, "/events/1", nil, owner); res.StatusCode != http.StatusNotFound {
+		t.Errorf("after deleting: got status %d, want 404", res.StatusCode)
+	}
+}
+
+func TestTicketsForPrivateEventUseInvite(t *testing.T) {
+	server := testutil.NewServer(t)
+	owner := server.SignupAndLogin("owner@example.com", "secret")
+	first := server.SignupAndLogin("first@example.com", "secret")
+	second := server.SignupAndLogin("second@example.com", "secret")
+
+	createEventWithVisibility(t, server, owner, "Private", "private")
+	createTicketType(t, server, owner, 0, 10)
+
+	var created struct{ Token string }
+	server.Do(http.MethodPost, "/events/1/invites", gin.H{"maxUses": 1}, owner).JSON(t, &created)
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders", nil, first); res.StatusCode != http.StatusNotFound {
+		t.Errorf("reserve without invite: got status %d, want 404", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders?invite="+created.Token, nil, first); res.StatusCode != http.StatusCreated {
+		t.Fatalf("reserve with invite: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if res := server.Do(http.MethodPost, "/events/1/tickets/1/orders?invite="+created.Token, nil, second); res.StatusCode == http.StatusCreated {
+		t.Errorf("reserve with used up invite: got status %d", res.StatusCode)
+	}
+
+	var invites []struct{ Uses int }
+	server.Do(http.MethodGet, "/events/1/invites", nil, owner).JSON(t, &invites)
+
+	if len(invites) != 1 || invites[0].Uses != 1 {
+		t.Errorf("got invites %+v, want one use", invites)
+	}
+}
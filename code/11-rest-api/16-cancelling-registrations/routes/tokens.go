@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func getMyAPITokens(context *gin.Context) {
+	tokens, err := models.GetAPITokensForUser(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch tokens.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, tokens)
+}
+
+// createAPIToken returns the new token's secret. It is shown this once;
+// only a hash is stored.
+func createAPIToken(context *gin.Context) {
+	var request struct {
+		Name   string         `binding:"required"`
+		Scopes []models.Scope `binding:"required,min=1"`
+		// ExpiresInDays is how long the token works, 0 until revoked.
+		ExpiresInDays int `binding:"min=0"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	token := models.APIToken{
+		UserID: context.GetInt64("userId"),
+		Name:   request.Name,
+		Scopes: request.Scopes,
+	}
+
+	if request.ExpiresInDays > 0 {
+		t := time.Now().UTC().AddDate(0, 0, request.ExpiresInDays)
+		token.ExpiresAt = &t
+	}
+
+	secret, err := token.Create(context.Request.Context())
+
+	if errors.Is(err, models.ErrInvalidScope) {
+		respondWithError(context, http.StatusBadRequest, "Unknown scope.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not create token.", err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Token created! It won't be shown again.", "apiToken": token, "token": secret})
+}
+
+func revokeAPIToken(context *gin.Context) {
+	tokenId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse token id.", err)
+		return
+	}
+
+	err = models.RevokeAPIToken(context.Request.Context(), context.GetInt64("userId"), tokenId)
+
+	if errors.Is(err, models.ErrAPITokenNotFound) {
+		respondWithError(context, http.StatusNotFound, "Token not found.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not revoke token.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Token revoked!"})
+}
@@ -0,0 +1,144 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/rest-api/models"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+type apiToken struct {
+	ID         int64
+	Name       string
+	Scopes     []string
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func createAPIToken(t *testing.T, server *testutil.Server, login string, scopes ...string) string {
+	t.Helper()
+
+	res := server.Do(http.MethodPost, "/me/tokens", gin.H{"name": "script", "scopes": scopes}, login)
+
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create token: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var body struct{ Token string }
+	res.JSON(t, &body)
+	return body.Token
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("owner@example.com", "secret")
+	createEvent(t, server, login, "Meetup")
+
+	token := createAPIToken(t, server, login, "events:read")
+
+	if !strings.HasPrefix(token, "pat_") {
+		t.Fatalf("got token %q, want the pat_ prefix", token)
+	}
+
+	if res := server.Do(http.MethodGet, "/events/1/attendees", nil, token); res.StatusCode != http.StatusOK {
+		t.Errorf("read with events:read: got status %d, want 200", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodDelete, "/events/1", nil, token); res.StatusCode != http.StatusForbidden {
+		t.Errorf("delete with events:read: got status %d, want 403", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, token); res.StatusCode != http.StatusForbidden {
+		t.Errorf("registrations with events:read: got status %d, want 403", res.StatusCode)
+	}
+
+	res := graphql(t, server, token, `mutation { deleteEvent(id: "1") }`, nil)
+
+	if len(res.Errors) != 1 || res.Errors[0].Message != "Token lacks the events:write scope." {
+		t.Errorf("GraphQL mutation with events:read: got %+v", res.Errors)
+	}
+
+	// GraphQL checks scopes per field, so a token without events:read can
+	// still use the mutations it has scopes for.
+	registrar := createAPIToken(t, server, login, "registrations:write")
+
+	if res := graphql(t, server, registrar, `mutation { registerForEvent(eventId: "1") }`, nil); len(res.Errors) > 0 {
+		t.Errorf("GraphQL registration with registrations:write: got %+v", res.Errors)
+	}
+
+	if res := graphql(t, server, registrar, `{ events { id } }`, nil); len(res.Errors) != 1 || res.Errors[0].Message != "Token lacks the events:read scope." {
+		t.Errorf("GraphQL events with registrations:write: got %+v", res.Errors)
+	}
+
+	// Tokens can't manage tokens, so a leaked one can't mint more.
+	if res := server.Do(http.MethodPost, "/me/tokens", gin.H{"name": "more", "scopes": []string{"events:write"}}, token); res.StatusCode != http.StatusForbidden {
+		t.Errorf("creating a token with a token: got status %d, want 403", res.StatusCode)
+	}
+
+	writer := createAPIToken(t, server, login, "events:write")
+
+	if res := server.Do(http.MethodPut, "/events/1", gin.H{"name": "Renamed", "description": "A meetup", "location": "Berlin", "dateTime": "2025-01-01T15:30:00Z"}, writer); res.StatusCode != http.StatusOK {
+		t.Errorf("update with events:write: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if res := server.Do(http.MethodPost, "/me/tokens", gin.H{"name": "bad", "scopes": []string{"everything"}}, login); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown scope: got status %d, want 400", res.StatusCode)
+	}
+}
+
+func TestAPITokenRevocation(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("owner@example.com", "secret")
+	other := server.SignupAndLogin("other@example.com", "secret")
+	token := createAPIToken(t, server, login, "registrations:read")
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, token); res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+
+	var tokens []apiToken
+	server.Do(http.MethodGet, "/me/tokens", nil, login).JSON(t, &tokens)
+
+	if len(tokens) != 1 || tokens[0].Name != "script" || tokens[0].LastUsedAt == nil {
+		t.Fatalf("got tokens %+v, want one used token", tokens)
+	}
+
+	if res := server.Do(http.MethodDelete, "/me/tokens/1", nil, other); res.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking someone else's token: got status %d, want 404", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodDelete, "/me/tokens/1", nil, login); res.StatusCode != http.StatusOK {
+		t.Fatalf("revoke: got status %d, want 200", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, token); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token: got status %d, want 401", res.StatusCode)
+	}
+}
+
+func TestDisabledUserLosesAccess(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("user@example.com", "secret")
+	token := createAPIToken(t, server, login, "registrations:read")
+
+	err := models.DisableUser(context.Background(), "user@example.com")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, credential := range map[string]string{"login token": login, "API token": token} {
+		if res := server.Do(http.MethodGet, "/me/registrations", nil, credential); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s of a disabled user: got status %d, want 401", name, res.StatusCode)
+		}
+	}
+
+	if res := server.Do(http.MethodPost, "/login", gin.H{"email": "user@example.com", "password": "secret"}, ""); res.StatusCode != http.StatusForbidden {
+		t.Errorf("login of a disabled user: got status %d, want 403", res.StatusCode)
+	}
+}
@@ -0,0 +1,177 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/rest-api/metrics"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `binding:"required"`
+}
+
+// enrollTwoFactor starts enabling 2FA and returns the secret to add to an
+// authenticator app, by hand or through the otpauth URL.
+func enrollTwoFactor(context *gin.Context) {
+	user, err := models.GetUserByID(context.Request.Context(), context.GetInt64("userId"))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not start two-factor enrollment.", err)
+		return
+	}
+
+	key, err := models.EnrollTwoFactor(context.Request.Context(), user.ID, user.Email)
+
+	if errors.Is(err, models.ErrTwoFactorEnabled) {
+		respondWithError(context, http.StatusConflict, "Two-factor authentication is already enabled.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not start two-factor enrollment.", err)
+		return
+	}
+
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusOK, gin.H{"secret": key.Secret(), "url": key.URL()})
+}
+
+// getTwoFactorQR renders the pending enrollment's otpauth URL as a QR code
+// PNG for authenticator apps to scan.
+func getTwoFactorQR(context *gin.Context) {
+	key, err := models.PendingTwoFactorKey(context.Request.Context(), context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrTwoFactorNotEnrolled) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "No two-factor enrollment in progress."})
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch two-factor enrollment.", err)
+		return
+	}
+
+	png, err := qrcode.Encode(key.URL(), qrcode.Medium, qrSize)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not render QR code.", err)
+		return
+	}
+
+	context.Header("Cache-Control", "private, no-store")
+	context.Data(http.StatusOK, "image/png", png)
+}
+
+// verifyTwoFactor enables 2FA with a code from the app and returns the
+// recovery codes. They are shown this once; only hashes are stored.
+func verifyTwoFactor(context *gin.Context) {
+	var request twoFactorCodeRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	codes, err := models.ConfirmTwoFactor(context.Request.Context(), context.GetInt64("userId"), request.Code)
+
+	if errors.Is(err, models.ErrTwoFactorNotEnrolled) {
+		respondWithError(context, http.StatusConflict, "No two-factor enrollment in progress.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidTwoFactorCode) {
+		respondWithError(context, http.StatusBadRequest, "Invalid two-factor code.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not enable two-factor authentication.", err)
+		return
+	}
+
+	context.Header("Cache-Control", "no-store")
+	context.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled.", "recoveryCodes": codes})
+}
+
+func disableTwoFactor(context *gin.Context) {
+	var request twoFactorCodeRequest
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	err = models.DisableTwoFactor(context.Request.Context(), context.GetInt64("userId"), request.Code)
+
+	if errors.Is(err, models.ErrTwoFactorLocked) {
+		respondWithError(context, http.StatusTooManyRequests, "Too many wrong two-factor codes, try again later.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrTwoFactorNotEnabled) {
+		respondWithError(context, http.StatusConflict, "Two-factor authentication is not enabled.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidTwoFactorCode) {
+		respondWithError(context, http.StatusBadRequest, "Invalid two-factor code.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not disable two-factor authentication.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled."})
+}
+
+// loginTwoFactor exchanges the challenge from /login or an SSO callback
+// and a TOTP or recovery code for a token.
+func loginTwoFactor(context *gin.Context) {
+	var request struct {
+		Challenge string `binding:"required"`
+		Code      string `binding:"required"`
+	}
+	err := context.ShouldBindJSON(&request)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
+		return
+	}
+
+	userId, err := models.CompleteLoginChallenge(context.Request.Context(), request.Challenge, request.Code)
+
+	if errors.Is(err, models.ErrTwoFactorLocked) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusTooManyRequests, "Too many wrong two-factor codes, try again later.", err)
+		return
+	}
+
+	if errors.Is(err, models.ErrLoginChallengeNotFound) || errors.Is(err, models.ErrInvalidTwoFactorCode) ||
+		errors.Is(err, models.ErrTwoFactorNotEnabled) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusUnauthorized, "Could not authenticate user.", err)
+		return
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	user, err := models.GetUserByID(context.Request.Context(), userId)
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
+		return
+	}
+
+	respondWithLogin(context, user.Email, user.ID)
+}
@@ -0,0 +1,184 @@
+package routes_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+)
+
+// enableTwoFactor enrolls the user and returns the secret and recovery
+// codes. The code used to confirm was for the current time step.
+func enableTwoFactor(t *testing.T, server *testutil.Server, login string) (string, []string) {
+	t.Helper()
+
+	var enrollment struct{ Secret, URL string }
+	res := server.Do(http.MethodPost, "/me/2fa/enroll", nil, login)
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("enroll: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	res.JSON(t, &enrollment)
+
+	if res := server.Do(http.MethodGet, "/me/2fa/qr", nil, login); res.StatusCode != http.StatusOK || !bytes.HasPrefix(res.Body, []byte("\x89PNG")) {
+		t.Errorf("QR code: got status %d, want a PNG", res.StatusCode)
+	}
+
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = server.Do(http.MethodPost, "/me/2fa/verify", gin.H{"code": code}, login)
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("verify: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	var body struct{ RecoveryCodes []string }
+	res.JSON(t, &body)
+	return enrollment.Secret, body.RecoveryCodes
+}
+
+// startLogin logs in with the password and returns the challenge.
+func startLogin(t *testing.T, server *testutil.Server, email, password string) string {
+	t.Helper()
+
+	var body struct{ Challenge, Token string }
+	res := server.Do(http.MethodPost, "/login", gin.H{"email": email, "password": password}, "")
+	res.JSON(t, &body)
+
+	if res.StatusCode != http.StatusOK || body.Challenge == "" || body.Token != "" {
+		t.Fatalf("login: got status %d, body %s, want only a challenge", res.StatusCode, res.Body)
+	}
+
+	return body.Challenge
+}
+
+func finishLogin(server *testutil.Server, challenge, code string) *testutil.Response {
+	return server.Do(http.MethodPost, "/login/2fa", gin.H{"challenge": challenge, "code": code}, "")
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("user@example.com", "secret")
+	secret, recoveryCodes := enableTwoFactor(t, server, login)
+
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("got %d recovery codes, want 10", len(recoveryCodes))
+	}
+
+	if res := server.Do(http.MethodPost, "/me/2fa/enroll", nil, login); res.StatusCode != http.StatusConflict {
+		t.Errorf("enrolling again: got status %d, want 409", res.StatusCode)
+	}
+
+	// The code that enabled 2FA can't be replayed.
+	challenge := startLogin(t, server, "user@example.com", "secret")
+	used, _ := totp.GenerateCode(secret, time.Now())
+
+	if res := finishLogin(server, challenge, used); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("replayed code: got status %d, want 401", res.StatusCode)
+	}
+
+	next, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	res := finishLogin(server, challenge, next)
+
+	var body struct{ Token string }
+	res.JSON(t, &body)
+
+	if res.StatusCode != http.StatusOK || body.Token == "" {
+		t.Fatalf("second step: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if res := server.Do(http.MethodGet, "/me/registrations", nil, body.Token); res.StatusCode != http.StatusOK {
+		t.Errorf("using the token: got status %d, want 200", res.StatusCode)
+	}
+
+	if res := finishLogin(server, challenge, next); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reused challenge: got status %d, want 401", res.StatusCode)
+	}
+
+	// Recovery codes work once each.
+	challenge = startLogin(t, server, "user@example.com", "secret")
+
+	if res := finishLogin(server, challenge, recoveryCodes[0]); res.StatusCode != http.StatusOK {
+		t.Errorf("recovery code: got status %d, want 200", res.StatusCode)
+	}
+
+	challenge = startLogin(t, server, "user@example.com", "secret")
+
+	if res := finishLogin(server, challenge, recoveryCodes[0]); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("used recovery code: got status %d, want 401", res.StatusCode)
+	}
+
+	// Disabling needs a code too, after which the password is enough.
+	if res := server.Do(http.MethodDelete, "/me/2fa", gin.H{"code": "000000"}, login); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("disable with a wrong code: got status %d, want 400", res.StatusCode)
+	}
+
+	if res := server.Do(http.MethodDelete, "/me/2fa", gin.H{"code": recoveryCodes[1]}, login); res.StatusCode != http.StatusOK {
+		t.Fatalf("disable: got status %d, body %s", res.StatusCode, res.Body)
+	}
+
+	if token := server.Login("user@example.com", "secret"); token == "" {
+		t.Error("login after disabling 2FA returned no token")
+	}
+}
+
+func TestTwoFactorChallengeLimitsAttempts(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("user@example.com", "secret")
+	secret, _ := enableTwoFactor(t, server, login)
+
+	challenge := startLogin(t, server, "user@example.com", "secret")
+
+	for i := 0; i < 5; i++ {
+		finishLogin(server, challenge, "000000")
+	}
+
+	next, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+
+	if res := finishLogin(server, challenge, next); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("after too many attempts: got status %d, want 401", res.StatusCode)
+	}
+}
+
+func TestTwoFactorLockoutSpansChallenges(t *testing.T) {
+	server := testutil.NewServer(t)
+	login := server.SignupAndLogin("user@example.com", "secret")
+	secret, _ := enableTwoFactor(t, server, login)
+
+	// Fresh challenges only need the password, so they mustn't reset the
+	// count of wrong codes.
+	for i := 0; i < 5; i++ {
+		challenge := startLogin(t, server, "user@example.com", "secret")
+
+		if res := finishLogin(server, challenge, "000000"); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("wrong code %d: got status %d, want 401", i+1, res.StatusCode)
+		}
+	}
+
+	next, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	challenge := startLogin(t, server, "user@example.com", "secret")
+
+	if res := finishLogin(server, challenge, next); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("right code while locked: got status %d, want 429", res.StatusCode)
+	}
+
+	_, err := db.DB.Exec("UPDATE user_totp SET locked_until = ?", time.Now().UTC().Add(-time.Second))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res := finishLogin(server, challenge, next); res.StatusCode != http.StatusOK {
+		t.Errorf("right code after the lockout: got status %d, body %s", res.StatusCode, res.Body)
+	}
+}
@@ -1,10 +1,11 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
+	"example.com/rest-api/metrics"
 	"example.com/rest-api/models"
-	"example.com/rest-api/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,17 +15,19 @@ func signup(context *gin.Context) {
 	err := context.ShouldBindJSON(&user)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
 		return
 	}
 
-	err = user.Save()
+	err = user.Save(context.Request.Context())
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save user."})
+		respondWithError(context, http.StatusInternalServerError, "Could not save user.", err)
 		return
 	}
 
+	metrics.UserSignedUp()
+
 	context.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
@@ -34,23 +37,28 @@ func login(context *gin.Context) {
 	err := context.ShouldBindJSON(&user)
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		respondWithError(context, http.StatusBadRequest, "Could not parse request data.", err)
 		return
 	}
 
-	err = user.ValidateCredentials()
+	err = user.ValidateCredentials(context.Request.Context())
 
-	if err != nil {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Could not authenticate user."})
+	if errors.Is(err, models.ErrInvalidCredentials) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusUnauthorized, "Could not authenticate user.", err)
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Email, user.ID)
+	if errors.Is(err, models.ErrUserDisabled) {
+		metrics.LoginFailed()
+		respondWithError(context, http.StatusForbidden, "Account disabled.", err)
+		return
+	}
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user."})
+		respondWithError(context, http.StatusInternalServerError, "Could not authenticate user.", err)
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "Login successful!", "token": token})
+	respondWithFirstFactor(context, user.Email, user.ID)
 }
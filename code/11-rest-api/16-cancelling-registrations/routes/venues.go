@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"example.com/rest-api/geo"
+	"example.com/rest-api/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultNearbyRadiusKm = 10
+	maxNearbyRadiusKm     = 500
+)
+
+// geocoder places venues that are sent with an address but without
+// coordinates. Without one such venues are stored as they are.
+var geocoder geo.Geocoder
+
+// SetGeocoder sets the Geocoder used for event venues. nil turns
+// geocoding off.
+func SetGeocoder(g geo.Geocoder) {
+	geocoder = g
+}
+
+// geocodeVenue fills in the coordinates of venue from its address, unless
+// they were sent or there is nothing to look up. It writes the error
+// response and returns false if the address can't be placed.
+func geocodeVenue(context *gin.Context, venue *models.Venue) bool {
+	if geocoder == nil || venue == nil || venue.Address == "" || venue.Lat != nil || venue.Lng != nil {
+		return true
+	}
+
+	point, err := geocoder.Geocode(context.Request.Context(), venue.Address)
+
+	if errors.Is(err, geo.ErrNotFound) {
+		respondWithError(context, http.StatusUnprocessableEntity, "Could not find the venue address.", err)
+		return false
+	}
+
+	if err != nil {
+		respondWithError(context, http.StatusBadGateway, "Could not look up the venue address. Try again later.", err)
+		return false
+	}
+
+	venue.Lat = &point.Lat
+	venue.Lng = &point.Lng
+	return true
+}
+
+// getNearbyEvents lists the events within ?radius= kilometres (10 by
+// default) of ?lat= and ?lng=, nearest first. It takes the same ?tag=
+// filter as GET /events.
+func getNearbyEvents(context *gin.Context) {
+	center, radius, err := parseNearbyQuery(context)
+
+	if err != nil {
+		respondWithError(context, http.StatusBadRequest, "Pass lat, lng and optionally a radius of up to 500 km.", err)
+		return
+	}
+
+	events, err := models.FindEventsNear(context.Request.Context(), center, radius, eventFilter(context))
+
+	if err != nil {
+		respondWithError(context, http.StatusInternalServerError, "Could not fetch events. Try again later.", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, events)
+}
+
+var errBadNearbyQuery = errors.New("coordinates or radius out of range")
+
+func parseNearbyQuery(context *gin.Context) (geo.Point, float64, error) {
+	lat, err := strconv.ParseFloat(context.Query("lat"), 64)
+
+	if err != nil {
+		return geo.Point{}, 0, err
+	}
+
+	lng, err := strconv.ParseFloat(context.Query("lng"), 64)
+
+	if err != nil {
+		return geo.Point{}, 0, err
+	}
+
+	radius := float64(defaultNearbyRadiusKm)
+
+	if value, ok := context.GetQuery("radius"); ok {
+		radius, err = strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return geo.Point{}, 0, err
+		}
+	}
+
+	// ParseFloat accepts "NaN", which fails every comparison below.
+	if math.IsNaN(lat) || math.IsNaN(lng) || lat < -90 || lat > 90 || lng < -180 || lng > 180 || !(radius > 0 && radius <= maxNearbyRadiusKm) {
+		return geo.Point{}, 0, errBadNearbyQuery
+	}
+
+	return geo.Point{Lat: lat, Lng: lng}, radius, nil
+}
@@ -0,0 +1,76 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"example.com/rest-api/geo"
+	"example.com/rest-api/routes"
+	"example.com/rest-api/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func createEventAt(t *testing.T, server *testutil.Server, token, name string, venue gin.H) *testutil.Response {
+	t.Helper()
+
+	return server.Do(http.MethodPost, "/events", gin.H{
+		"name":        name,
+		"description": "A meetup",
+		"location":    "Somewhere",
+		"dateTime":    "2025-01-01T15:30:00Z",
+		"venue":       venue,
+	}, token)
+}
+
+func TestNearbyEvents(t *testing.T) {
+	routes.SetGeocoder(geo.StaticGeocoder{
+		"alexanderplatz, berlin": {Lat: 52.5219, Lng: 13.4132},
+	})
+	t.Cleanup(func() { routes.SetGeocoder(nil) })
+
+	server := testutil.NewServer(t)
+	token := server.SignupAndLogin("owner@example.com", "secret")
+
+	for name, venue := range map[string]gin.H{
+		"Alexanderplatz": {"address": "Alexanderplatz, Berlin"},
+		"Potsdam":        {"lat": 52.3906, "lng": 13.0645},
+		"Paris":          {"lat": 48.8566, "lng": 2.3522},
+	} {
+		res := createEventAt(t, server, token, name, venue)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create %s: got status %d, body %s", name, res.StatusCode, res.Body)
+		}
+	}
+
+	res := createEventAt(t, server, token, "Nowhere", gin.H{"address": "Nowhere"})
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("unknown address: got status %d, want %d", res.StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	res = createEventAt(t, server, token, "Half", gin.H{"lat": 52.5})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("latitude only: got status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	var events []struct {
+		Name       string
+		DistanceKm float64
+	}
+	res = server.Do(http.MethodGet, "/events/nearby?lat=52.52&lng=13.405&radius=50", nil, "")
+	res.JSON(t, &events)
+
+	if len(events) != 2 || events[0].Name != "Alexanderplatz" || events[1].Name != "Potsdam" {
+		t.Fatalf("got events %+v", events)
+	}
+
+	if events[0].DistanceKm > 1 || events[1].DistanceKm < 20 || events[1].DistanceKm > 30 {
+		t.Errorf("unexpected distances %+v", events)
+	}
+
+	for _, query := range []string{"", "?lat=52.52", "?lat=91&lng=0", "?lat=52.52&lng=13.4&radius=0", "?lat=52.52&lng=13.4&radius=1000", "?lat=NaN&lng=13.4", "?lat=52.52&lng=nan"} {
+		res := server.Do(http.MethodGet, "/events/nearby"+query, nil, "")
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: got status %d, want %d", query, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package sso signs users in with OpenID Connect identity providers, using
+// the authorization code flow with PKCE. Turning the identity into a user
+// of the API is up to the caller.
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Config describes one identity provider.
+type Config struct {
+	// Name identifies the provider in URLs, e.g. /auth/{name}/login.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to, the API's
+	// /auth/{name}/callback. It must be registered with the provider.
+	RedirectURL string
+}
+
+// LoadConfig reads a JSON array of Configs from path.
+func LoadConfig(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+	err = json.Unmarshal(data, &configs)
+
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, config := range configs {
+		if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+			return nil, fmt.Errorf("%s: every provider needs a name, issuer, client ID and redirect URL", path)
+		}
+	}
+
+	return configs, nil
+}
+
+// Identity is who the provider says signed in.
+type Identity struct {
+	// Subject is the provider's stable id for the user. Unlike the email
+	// address it never changes.
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+var (
+	ErrMissingIDToken = errors.New("Provider returned no ID token")
+	ErrNonceMismatch  = errors.New("ID token nonce doesn't match")
+	ErrMissingEmail   = errors.New("ID token has no email claim")
+)
+
+// Provider is a configured identity provider.
+type Provider struct {
+	Name     string
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewProvider fetches the provider's discovery document. ctx is also used
+// to fetch the provider's signing keys later on, so it must live as long as
+// the Provider.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+
+	if err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", config.Name, err)
+	}
+
+	return &Provider{
+		Name: config.Name,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  config.RedirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// AuthCodeURL returns the provider's sign-in page. state comes back with
+// the callback; nonce comes back in the ID token; verifier is the PKCE
+// secret that Exchange needs. All three must be random and kept until the
+// callback.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems the code from the callback and verifies the ID token the
+// provider returns.
+func (p *Provider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+
+	if !ok {
+		return nil, ErrMissingIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	err = idToken.Claims(&claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, ErrMissingEmail
+	}
+
+	return &Identity{Subject: idToken.Subject, Email: claims.Email, EmailVerified: claims.EmailVerified}, nil
+}
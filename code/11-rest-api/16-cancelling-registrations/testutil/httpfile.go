@@ -0,0 +1,426 @@
+package testutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// HTTPFile is a parsed .http file as used by the REST Client IDE extensions.
+//
+// On top of the usual syntax (`@var = value` lines, `{{var}}` placeholders,
+// requests separated by `###`), comment directives describe what a request
+// should return and which values later requests need:
+//
+//	# @name login
+//	# @expect status 200
+//	# @expect $.message == "Login successful!"
+//	# @expect $.token exists
+//	# @capture token = $.token
+//
+// The IDE ignores these directives, so the files stay usable by hand.
+type HTTPFile struct {
+	Vars     map[string]string
+	Requests []HTTPRequest
+}
+
+// HTTPRequest is a single request of an HTTPFile. URL, header values and
+// body may still contain {{var}} placeholders.
+type HTTPRequest struct {
+	Name     string
+	Line     int
+	Method   string
+	URL      string
+	Header   http.Header
+	Body     string
+	Expects  []Expectation
+	Captures []Capture
+}
+
+// Expectation is an `@expect` directive. Status is set for status checks,
+// otherwise Path is checked for existence or, if Value is set, equality.
+type Expectation struct {
+	Status int
+	Path   string
+	Value  string
+}
+
+// Capture is an `@capture` directive storing a response value in Var.
+type Capture struct {
+	Var  string
+	Path string
+}
+
+var (
+	varPattern         = regexp.MustCompile(`{{\s*([\w.$\[\]-]+)\s*}}`)
+	fileVarPattern     = regexp.MustCompile(`^@([\w-]+)\s*=\s*(.*)$`)
+	requestLinePattern = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\s+(\S+)`)
+)
+
+// ParseHTTPFile reads and parses the .http file at path.
+func ParseHTTPFile(path string) (*HTTPFile, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	file, err := ParseHTTP(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// ParseHTTP parses the contents of a .http file.
+func ParseHTTP(r io.Reader) (*HTTPFile, error) {
+	file := &HTTPFile{Vars: map[string]string{}}
+	scanner := bufio.NewScanner(r)
+
+	var current *HTTPRequest
+	var pending HTTPRequest
+	var body []string
+	inHeaders := false
+	lineNo := 0
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+		file.Requests = append(file.Requests, *current)
+		current = nil
+		body = nil
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "###") {
+			finish()
+			pending = HTTPRequest{}
+			continue
+		}
+
+		if current != nil && !inHeaders {
+			body = append(body, line)
+			continue
+		}
+
+		if current != nil && inHeaders {
+			if trimmed == "" {
+				inHeaders = false
+				continue
+			}
+			if isComment(trimmed) {
+				continue
+			}
+			name, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed header %q", lineNo, trimmed)
+			}
+			current.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+			continue
+		}
+
+		if trimmed == "" {
+			continue
+		}
+
+		if isComment(trimmed) {
+			err := parseDirective(&pending, trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+
+		if m := fileVarPattern.FindStringSubmatch(trimmed); m != nil {
+			file.Vars[m[1]] = strings.TrimSpace(m[2])
+			continue
+		}
+
+		m := requestLinePattern.FindStringSubmatch(trimmed)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected request line, got %q", lineNo, trimmed)
+		}
+
+		req := pending
+		current = &req
+		current.Line = lineNo
+		current.Method = m[1]
+		current.URL = m[2]
+		current.Header = http.Header{}
+		pending = HTTPRequest{}
+		inHeaders = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	finish()
+	return file, nil
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
+}
+
+func parseDirective(req *HTTPRequest, line string) error {
+	line = strings.TrimSpace(strings.TrimLeft(line, "#/"))
+
+	if !strings.HasPrefix(line, "@") {
+		return nil
+	}
+
+	directive, args, _ := strings.Cut(line[1:], " ")
+	args = strings.TrimSpace(args)
+
+	switch directive {
+	case "name":
+		req.Name = args
+	case "capture":
+		name, path, ok := strings.Cut(args, "=")
+		if !ok {
+			return fmt.Errorf("malformed @capture %q", args)
+		}
+		req.Captures = append(req.Captures, Capture{Var: strings.TrimSpace(name), Path: strings.TrimSpace(path)})
+	case "expect":
+		expectation, err := parseExpectation(args)
+		if err != nil {
+			return err
+		}
+		req.Expects = append(req.Expects, expectation)
+	}
+
+	return nil
+}
+
+func parseExpectation(args string) (Expectation, error) {
+	if rest, ok := strings.CutPrefix(args, "status "); ok {
+		status, err := strconv.Atoi(strings.TrimSpace(rest))
+		if err != nil {
+			return Expectation{}, fmt.Errorf("malformed @expect status %q", rest)
+		}
+		return Expectation{Status: status}, nil
+	}
+
+	if path, ok := strings.CutSuffix(args, " exists"); ok {
+		return Expectation{Path: strings.TrimSpace(path)}, nil
+	}
+
+	path, value, ok := strings.Cut(args, "==")
+	if !ok {
+		return Expectation{}, fmt.Errorf("malformed @expect %q", args)
+	}
+
+	return Expectation{Path: strings.TrimSpace(path), Value: strings.TrimSpace(value)}, nil
+}
+
+// HTTPFileRunner executes .http files against a server. Vars is shared by
+// every file it runs, so values captured in one file can be used by the next.
+// Vars take precedence over `@var` defaults declared in the files.
+type HTTPFileRunner struct {
+	Client *http.Client
+	Vars   map[string]string
+}
+
+// NewHTTPFileRunner returns a runner for s with {{baseUrl}} pointing at it.
+func NewHTTPFileRunner(s *Server) *HTTPFileRunner {
+	return &HTTPFileRunner{
+		Client: s.Client(),
+		Vars:   map[string]string{"baseUrl": s.URL},
+	}
+}
+
+// RunFile runs every request of the file at path as a subtest.
+func (r *HTTPFileRunner) RunFile(t *testing.T, path string) {
+	t.Helper()
+
+	file, err := ParseHTTPFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range file.Requests {
+		name := req.Name
+		if name == "" {
+			name = fmt.Sprintf("%s_line_%d", req.Method, req.Line)
+		}
+
+		ok := t.Run(name, func(t *testing.T) {
+			r.run(t, file, req)
+		})
+
+		if !ok {
+			t.FailNow()
+		}
+	}
+}
+
+func (r *HTTPFileRunner) run(t *testing.T, file *HTTPFile, req HTTPRequest) {
+	expand := func(s string) string {
+		return r.expand(t, file, s, 0)
+	}
+
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(expand(req.Body))
+	}
+
+	httpReq, err := http.NewRequest(req.Method, expand(req.URL), body)
+	if err != nil {
+		t.Fatalf("line %d: %v", req.Line, err)
+	}
+
+	for name, values := range req.Header {
+		for _, value := range values {
+			httpReq.Header.Add(name, expand(value))
+		}
+	}
+
+	res, err := r.Client.Do(httpReq)
+	if err != nil {
+		t.Fatalf("line %d: %v", req.Line, err)
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("line %d: could not read response body: %v", req.Line, err)
+	}
+
+	var decoded any
+	decodeErr := decodeJSON(data, &decoded)
+
+	for _, expectation := range req.Expects {
+		if expectation.Status != 0 {
+			if res.StatusCode != expectation.Status {
+				t.Errorf("line %d: got status %d, want %d (body %s)", req.Line, res.StatusCode, expectation.Status, data)
+			}
+			continue
+		}
+
+		if decodeErr != nil {
+			t.Errorf("line %d: response body is not JSON: %s", req.Line, data)
+			continue
+		}
+
+		got, found := lookupJSON(decoded, expectation.Path)
+		if !found {
+			t.Errorf("line %d: %s not found in %s", req.Line, expectation.Path, data)
+			continue
+		}
+
+		if expectation.Value == "" {
+			continue
+		}
+
+		var want any
+		if err := decodeJSON([]byte(expand(expectation.Value)), &want); err != nil {
+			t.Errorf("line %d: expected value %s is not JSON", req.Line, expectation.Value)
+			continue
+		}
+
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		if !bytes.Equal(gotJSON, wantJSON) {
+			t.Errorf("line %d: %s is %s, want %s", req.Line, expectation.Path, gotJSON, wantJSON)
+		}
+	}
+
+	for _, capture := range req.Captures {
+		if decodeErr != nil {
+			t.Fatalf("line %d: cannot capture %s, response body is not JSON: %s", req.Line, capture.Var, data)
+		}
+
+		value, found := lookupJSON(decoded, capture.Path)
+		if !found {
+			t.Fatalf("line %d: cannot capture %s, %s not found in %s", req.Line, capture.Var, capture.Path, data)
+		}
+
+		if s, ok := value.(string); ok {
+			r.Vars[capture.Var] = s
+		} else {
+			encoded, _ := json.Marshal(value)
+			r.Vars[capture.Var] = string(encoded)
+		}
+	}
+}
+
+func (r *HTTPFileRunner) expand(t *testing.T, file *HTTPFile, s string, depth int) string {
+	if depth > 10 {
+		t.Fatalf("variables nested too deeply in %q", s)
+	}
+
+	return varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := varPattern.FindStringSubmatch(match)[1]
+
+		if value, ok := r.Vars[name]; ok {
+			return value
+		}
+
+		if value, ok := file.Vars[name]; ok {
+			return r.expand(t, file, value, depth+1)
+		}
+
+		t.Fatalf("undefined variable %q", name)
+		return ""
+	})
+}
+
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// lookupJSON resolves a path like `$.event.ID` or `$[0].Name` in a decoded
+// JSON value.
+func lookupJSON(value any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,97 @@
+package testutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHTTP(t *testing.T) {
+	input := `@baseUrl = http://localhost:8080
+
+# @name login
+# @expect status 200
+# @expect $.token exists
+# @capture token = $.token
+POST {{baseUrl}}/login
+content-type: application/json
+
+{
+  "email": "test@example.com"
+}
+
+###
+
+// @expect $.message == "Deleted!"
+DELETE {{baseUrl}}/events/1
+authorization: {{token}}
+`
+
+	file, err := ParseHTTP(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Vars["baseUrl"] != "http://localhost:8080" {
+		t.Errorf("baseUrl = %q", file.Vars["baseUrl"])
+	}
+
+	if len(file.Requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(file.Requests))
+	}
+
+	login := file.Requests[0]
+
+	if login.Name != "login" || login.Method != "POST" || login.URL != "{{baseUrl}}/login" {
+		t.Errorf("unexpected request line: %+v", login)
+	}
+
+	if login.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("content-type = %q", login.Header.Get("Content-Type"))
+	}
+
+	if login.Body != "{\n  \"email\": \"test@example.com\"\n}" {
+		t.Errorf("body = %q", login.Body)
+	}
+
+	if len(login.Expects) != 2 || login.Expects[0].Status != 200 || login.Expects[1].Path != "$.token" {
+		t.Errorf("expects = %+v", login.Expects)
+	}
+
+	if len(login.Captures) != 1 || login.Captures[0] != (Capture{Var: "token", Path: "$.token"}) {
+		t.Errorf("captures = %+v", login.Captures)
+	}
+
+	del := file.Requests[1]
+
+	if del.Method != "DELETE" || del.Body != "" || del.Header.Get("Authorization") != "{{token}}" {
+		t.Errorf("unexpected request: %+v", del)
+	}
+
+	if len(del.Expects) != 1 || del.Expects[0] != (Expectation{Path: "$.message", Value: `"Deleted!"`}) {
+		t.Errorf("expects = %+v", del.Expects)
+	}
+}
+
+func TestLookupJSON(t *testing.T) {
+	var value any
+	err := decodeJSON([]byte(`{"events": [{"ID": 3, "Name": "Meetup"}]}`), &value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := lookupJSON(value, "$.events[0].Name")
+
+	if !ok || got != "Meetup" {
+		t.Errorf("got %v, %v", got, ok)
+	}
+
+	if _, ok := lookupJSON(value, "$.events[1]"); ok {
+		t.Error("out of range index was found")
+	}
+
+	if _, ok := lookupJSON(value, "$.missing"); ok {
+		t.Error("missing key was found")
+	}
+}
@@ -0,0 +1,190 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"example.com/rest-api/db"
+	"example.com/rest-api/middlewares"
+	"example.com/rest-api/routes"
+	"example.com/rest-api/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Server is the REST API running on an httptest.Server against a
+// throwaway database.
+type Server struct {
+	*httptest.Server
+	t testing.TB
+}
+
+// Response is a fully read HTTP response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// JSON decodes the response body into v and fails the test if it can't.
+func (r *Response) JSON(t testing.TB, v any) {
+	t.Helper()
+
+	err := json.Unmarshal(r.Body, v)
+
+	if err != nil {
+		t.Fatalf("could not decode response body %q: %v", r.Body, err)
+	}
+}
+
+// NewServer boots routes.RegisterRoutes on a fresh database in a temporary
+// directory. Everything is torn down when the test finishes.
+//
+// The server replaces package state: db.DB, the signing key and the cookie
+// Secure flag are globals. Tests using it must not call t.Parallel, and
+// only one server can run at a time.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	db.InitDB(filepath.Join(t.TempDir(), "api.db"))
+
+	err := utils.SetSigningKey("test signing key, not for production")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test server speaks plain HTTP, where clients drop Secure cookies.
+	middlewares.SetSecureCookies(false)
+	t.Cleanup(func() { middlewares.SetSecureCookies(true) })
+
+	server := gin.New()
+	err = routes.RegisterRoutes(server)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(server)
+
+	t.Cleanup(func() {
+		ts.Close()
+		db.DB.Close()
+	})
+
+	return &Server{Server: ts, t: t}
+}
+
+// Do sends a request to the server. A non-nil body is encoded as JSON and a
+// non-empty token is sent in the Authorization header.
+func (s *Server) Do(method, path string, body any, token string) *Response {
+	s.t.Helper()
+
+	header := http.Header{}
+
+	if token != "" {
+		header.Set("Authorization", token)
+	}
+
+	return s.DoWithHeader(method, path, body, header)
+}
+
+// DoWithHeader is like Do but sends the given headers. A []byte body is
+// sent as is, with the Content-Type taken from header.
+func (s *Server) DoWithHeader(method, path string, body any, header http.Header) *Response {
+	s.t.Helper()
+
+	var reader io.Reader
+	raw, isRaw := body.([]byte)
+
+	if isRaw {
+		reader = bytes.NewReader(raw)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("could not encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatalf("could not build request: %v", err)
+	}
+
+	if body != nil && !isRaw {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		s.t.Fatalf("could not read response body: %v", err)
+	}
+
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: data}
+}
+
+// Signup creates a user and fails the test if the API doesn't accept it.
+func (s *Server) Signup(email, password string) {
+	s.t.Helper()
+
+	res := s.Do(http.MethodPost, "/signup", gin.H{"email": email, "password": password}, "")
+
+	if res.StatusCode != http.StatusCreated {
+		s.t.Fatalf("signup %s: got status %d, body %s", email, res.StatusCode, res.Body)
+	}
+}
+
+// Login returns a token for an existing user.
+func (s *Server) Login(email, password string) string {
+	s.t.Helper()
+
+	res := s.Do(http.MethodPost, "/login", gin.H{"email": email, "password": password}, "")
+
+	if res.StatusCode != http.StatusOK {
+		s.t.Fatalf("login %s: got status %d, body %s", email, res.StatusCode, res.Body)
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	res.JSON(s.t, &body)
+
+	return body.Token
+}
+
+// SignupAndLogin creates a user and returns a token for it.
+func (s *Server) SignupAndLogin(email, password string) string {
+	s.t.Helper()
+
+	s.Signup(email, password)
+	return s.Login(email, password)
+}
+
+// SetRole changes the role of an existing user directly in the database,
+// since the API can't.
+func (s *Server) SetRole(email, role string) {
+	s.t.Helper()
+
+	_, err := db.DB.Exec("UPDATE users SET role = ? WHERE email = ? COLLATE NOCASE", role, email)
+
+	if err != nil {
+		s.t.Fatalf("set role of %s: %v", email, err)
+	}
+}
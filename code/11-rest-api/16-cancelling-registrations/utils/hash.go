@@ -1,13 +1,228 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/rest-api/metrics"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords with one algorithm and set of parameters.
+// Hashes are encoded in the PHC string format, so they carry everything
+// needed to verify them after the parameters change.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password with a new salt.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded. Hashes made by other
+	// algorithms never match.
+	Verify(password, encoded string) bool
+	// Current reports whether encoded was made by this hasher with the same
+	// parameters.
+	Current(encoded string) bool
+}
+
+// DefaultArgon2id follows the OWASP recommendation for argon2id. Run
+// BenchmarkPasswordHashers to compare other parameters on your hardware.
+var DefaultArgon2id = Argon2id{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// DefaultBcrypt is for deployments that can't spare argon2id's memory.
+var DefaultBcrypt = Bcrypt{Cost: 12}
+
+// passwordHasher hashes new passwords. Older hashes are still verified by
+// their own algorithm and parameters.
+var passwordHasher PasswordHasher = DefaultArgon2id
+
+// SetPasswordHasher changes how new passwords are hashed. Existing hashes
+// keep working and are upgraded when their users log in.
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasher = hasher
+}
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	defer metrics.ObservePasswordHash("hash", time.Now())
+
+	return passwordHasher.Hash(password)
 }
 
 func CheckPasswordHash(password, hashedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	defer metrics.ObservePasswordHash("compare", time.Now())
+
+	for _, hasher := range []PasswordHasher{Argon2id{}, Bcrypt{}} {
+		if hasher.Verify(password, hashedPassword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PasswordNeedsRehash reports whether hashedPassword should be replaced
+// by a hash from the current PasswordHasher.
+func PasswordNeedsRehash(hashedPassword string) bool {
+	return !passwordHasher.Current(hashedPassword)
+}
+
+// ParsePasswordHasher returns the hasher described by spec, an algorithm
+// with optional parameters, e.g. "argon2id", "argon2id:m=65536,t=3,p=2" or
+// "bcrypt:cost=12". Parameters left out keep their defaults.
+func ParsePasswordHasher(spec string) (PasswordHasher, error) {
+	algorithm, params, _ := strings.Cut(spec, ":")
+	values := map[string]int{}
+
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			name, value, _ := strings.Cut(param, "=")
+			n, err := strconv.Atoi(value)
+
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid password hasher parameter %q", param)
+			}
+
+			values[name] = n
+		}
+	}
+
+	var hasher PasswordHasher
+	var known []string
+
+	switch algorithm {
+	case "argon2id":
+		h := DefaultArgon2id
+		setParam(values, "m", &h.Memory)
+		setParam(values, "t", &h.Iterations)
+		setParam(values, "p", &h.Parallelism)
+
+		// argon2 needs at least 8 KiB per lane.
+		if values["p"] > 255 || h.Memory < 8*uint32(h.Parallelism) {
+			return nil, fmt.Errorf("argon2id needs 1 to 255 lanes with at least 8 KiB of memory each")
+		}
+
+		hasher, known = h, []string{"m", "t", "p"}
+	case "bcrypt":
+		h := DefaultBcrypt
+		setParam(values, "cost", &h.Cost)
+
+		if h.Cost < bcrypt.MinCost || h.Cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+
+		hasher, known = h, []string{"cost"}
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", algorithm)
+	}
+
+	for name := range values {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown %s parameter %q", algorithm, name)
+		}
+	}
+
+	return hasher, nil
+}
+
+func setParam[T uint8 | uint32 | int](values map[string]int, name string, param *T) {
+	if value, ok := values[name]; ok {
+		*param = T(value)
+	}
+}
+
+// Argon2id hashes passwords with argon2id, encoded like
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+type Argon2id struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(password, encoded string) bool {
+	params, salt, key, ok := decodeArgon2id(encoded)
+
+	if !ok {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+func (a Argon2id) Current(encoded string) bool {
+	params, salt, key, ok := decodeArgon2id(encoded)
+
+	return ok && params.Memory == a.Memory && params.Iterations == a.Iterations && params.Parallelism == a.Parallelism &&
+		uint32(len(salt)) == a.SaltLength && uint32(len(key)) == a.KeyLength
+}
+
+func decodeArgon2id(encoded string) (params Argon2id, salt, key []byte, ok bool) {
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return params, nil, nil, false
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, false
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return params, nil, nil, false
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, false
+	}
+
+	return params, salt, key, true
+}
+
+// Bcrypt hashes passwords with bcrypt. Hashes keep bcrypt's own
+// $2a$<cost>$... encoding, which predates the PHC format but is told apart
+// from it the same way, by the identifier after the first $.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(bytes), err
+}
+
+func (b Bcrypt) Verify(password, encoded string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
 	return err == nil
 }
+
+func (b Bcrypt) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == b.Cost
+}
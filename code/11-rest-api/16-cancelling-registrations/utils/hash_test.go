@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	for _, hasher := range []PasswordHasher{DefaultArgon2id, Bcrypt{Cost: 4}} {
+		hash, err := hasher.Hash("secret")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !hasher.Verify("secret", hash) || hasher.Verify("wrong", hash) {
+			t.Errorf("%T: verifying %q went wrong", hasher, hash)
+		}
+
+		if !hasher.Current(hash) {
+			t.Errorf("%T: own hash %q is not current", hasher, hash)
+		}
+	}
+
+	hash, _ := DefaultArgon2id.Hash("secret")
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("got %q, want a PHC string", hash)
+	}
+
+	// Verification goes by the parameters in the hash, not the hasher's.
+	stronger := Argon2id{Memory: 32 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+	if !stronger.Verify("secret", hash) || stronger.Current(hash) {
+		t.Errorf("stronger hasher: should verify %q but not consider it current", hash)
+	}
+}
+
+func TestPasswordRehash(t *testing.T) {
+	t.Cleanup(func() { SetPasswordHasher(DefaultArgon2id) })
+
+	SetPasswordHasher(Bcrypt{Cost: 4})
+	old, err := HashPassword("secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetPasswordHasher(DefaultArgon2id)
+
+	if !CheckPasswordHash("secret", old) || !PasswordNeedsRehash(old) {
+		t.Errorf("bcrypt hash %q should still work and need a rehash", old)
+	}
+
+	current, _ := HashPassword("secret")
+
+	if !CheckPasswordHash("secret", current) || PasswordNeedsRehash(current) {
+		t.Errorf("argon2id hash %q should work without a rehash", current)
+	}
+
+	// Users who only sign in through a provider have no password.
+	if CheckPasswordHash("", "") {
+		t.Error("the empty hash matched")
+	}
+}
+
+func TestParsePasswordHasher(t *testing.T) {
+	tests := []struct {
+		spec string
+		want PasswordHasher
+	}{
+		{"argon2id", DefaultArgon2id},
+		{"argon2id:m=65536,t=3,p=4", Argon2id{Memory: 65536, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}},
+		{"bcrypt", DefaultBcrypt},
+		{"bcrypt:cost=14", Bcrypt{Cost: 14}},
+	}
+
+	for _, test := range tests {
+		got, err := ParsePasswordHasher(test.spec)
+
+		if err != nil || got != test.want {
+			t.Errorf("ParsePasswordHasher(%q) = %+v, %v, want %+v", test.spec, got, err, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "scrypt", "argon2id:m=4", "argon2id:p=300", "argon2id:cost=12", "bcrypt:cost=99", "bcrypt:cost=x"} {
+		if _, err := ParsePasswordHasher(spec); err == nil {
+			t.Errorf("ParsePasswordHasher(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// BenchmarkPasswordHashers times a login with each candidate setting.
+// Aim for well under the request timeout with logins coming in at once.
+func BenchmarkPasswordHashers(b *testing.B) {
+	hashers := []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{"argon2id/m=19MiB,t=2,p=1", DefaultArgon2id},
+		{"argon2id/m=46MiB,t=1,p=1", Argon2id{Memory: 46 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{"argon2id/m=64MiB,t=3,p=4", Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}},
+		{"bcrypt/cost=10", Bcrypt{Cost: 10}},
+		{"bcrypt/cost=12", DefaultBcrypt},
+		{"bcrypt/cost=14", Bcrypt{Cost: 14}},
+	}
+
+	for _, h := range hashers {
+		hash, err := h.hasher.Hash("secret")
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(h.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				h.hasher.Verify("secret", hash)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,17 +11,26 @@ import (
 
 const secretKey = "supersecret"
 
+// TokenTTL is how long a token from GenerateToken is valid.
+const TokenTTL = 2 * time.Hour
+
 func GenerateToken(email string, userId int64) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"email":  email,
 		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
+		// Fractional, so tokens issued right after a revocation aren't
+		// mistaken for older ones.
+		"iat": float64(time.Now().UnixMicro()) / 1e6,
+		"exp": time.Now().Add(TokenTTL).Unix(),
 	})
 
 	return token.SignedString([]byte(secretKey))
 }
 
-func VerifyToken(token string) (int64, error) {
+// VerifyToken returns the user a token from GenerateToken was issued to and
+// when it was issued. Tokens from before issue times were recorded have a
+// zero issuedAt.
+func VerifyToken(token string) (userId int64, issuedAt time.Time, err error) {
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 
@@ -32,23 +42,31 @@ func VerifyToken(token string) (int64, error) {
 	})
 
 	if err != nil {
-		fmt.Println("Could not parse token")
-		return 0, errors.New("Could not parse token.")
+		return 0, time.Time{}, fmt.Errorf("Could not parse token: %w", err)
 	}
 
 	tokenIsValid := parsedToken.Valid
 
 	if !tokenIsValid {
-		return 0, errors.New("Invalid token!")
+		return 0, time.Time{}, errors.New("Invalid token!")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 
 	if !ok {
-		return 0, errors.New("Invalid token claims.")
+		return 0, time.Time{}, errors.New("Invalid token claims.")
 	}
 
 	// email := claims["email"].(string)
-	userId := int64(claims["userId"].(float64))
-	return userId, nil
+	id, ok := claims["userId"].(float64)
+
+	if !ok || id <= 0 {
+		return 0, time.Time{}, errors.New("Invalid token claims.")
+	}
+
+	if iat, ok := claims["iat"].(float64); ok {
+		issuedAt = time.UnixMicro(int64(math.Round(iat * 1e6)))
+	}
+
+	return int64(id), issuedAt, nil
 }
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyToken(t *testing.T) {
+	token, err := GenerateToken("user@example.com", 7)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userId, issuedAt, err := VerifyToken(token)
+	if err != nil || userId != 7 {
+		t.Errorf("got %d, %v, want user 7", userId, err)
+	}
+
+	if time.Since(issuedAt) > time.Minute {
+		t.Errorf("got issued at %v, want about now", issuedAt)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"no user id":     {"email": "user@example.com", "exp": exp},
+		"string user id": {"userId": "7", "exp": exp},
+		"zero user id":   {"userId": 0, "exp": exp},
+	} {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := VerifyToken(signed); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRandomToken returns a random URL-safe token for links and API
+// keys, together with the hash to store in its place.
+func GenerateRandomToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRandomToken(token), nil
+}
+
+// HashRandomToken hashes a token from GenerateRandomToken for lookup. The
+// tokens are random enough that a fast hash is fine.
+func HashRandomToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}